@@ -6,24 +6,62 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	config "github.com/ashr-tech/csv-migration-tools/config"
 	types "github.com/ashr-tech/csv-migration-tools/types"
 )
 
+// CallAI sends prompt to the AI of mode and returns its answer. Requests
+// wait in a queue shared by all callers, within the limits set by SetLimits.
 func CallAI(prompt string, mode *string) (string, error) {
-	switch *mode {
-	case "local":
-		return callLocalOllama(prompt)
-	default:
-		return callCloudOllama(prompt)
+	return CallModel(prompt, mode, "")
+}
+
+// CallModel is CallAI asking model instead of the model configured for mode,
+// or the configured one when model is ""
+func CallModel(prompt string, mode *string, model string) (string, error) {
+	local := *mode == "local"
+	if model == "" {
+		model = config.CLOUD_AI_MODEL
+		if local {
+			model = config.LOCAL_AI_MODEL
+		}
+	}
+
+	if audit == nil {
+		return call(prompt, local, model)
+	}
+	auditMode := "cloud"
+	if local {
+		auditMode = "local"
+	}
+	n, err := audit.sent(auditMode, model, prompt)
+	if err != nil {
+		return "", err
+	}
+	resp, err := call(prompt, local, model)
+	if auditErr := audit.received(n, resp, err); auditErr != nil && err == nil {
+		return "", auditErr
 	}
+	return resp, err
 }
 
-func callLocalOllama(prompt string) (string, error) {
+func call(prompt string, local bool, model string) (string, error) {
+	return queue.do(func() (string, error) {
+		if local {
+			return callLocalOllama(prompt, model)
+		}
+		return callCloudOllama(prompt, model)
+	})
+}
+
+func callLocalOllama(prompt, model string) (string, error) {
 	reqBody := types.OllamaRequest{
-		Model:  config.LOCAL_AI_MODEL,
+		Model:  model,
 		Prompt: prompt,
 		Stream: false,
 	}
@@ -52,14 +90,14 @@ func callLocalOllama(prompt string) (string, error) {
 	return ollamaResp.Response, nil
 }
 
-func callCloudOllama(prompt string) (string, error) {
+func callCloudOllama(prompt, model string) (string, error) {
 	apiKey := os.Getenv("OLLAMA_API_KEY")
 	if apiKey == "" {
 		return "", fmt.Errorf("OLLAMA_API_KEY is not set")
 	}
 
 	reqBody := types.OllamaCloudRequest{
-		Model: config.CLOUD_AI_MODEL,
+		Model: model,
 		Messages: []types.OllamaCloudMessage{
 			{
 				Role:    "user",
@@ -99,11 +137,11 @@ func callCloudOllama(prompt string) (string, error) {
 	}
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf(
-			"ollama cloud http %d:\n%s",
-			resp.StatusCode,
-			string(body),
-		)
+		return "", &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	var ollamaResp types.OllamaCloudResponse
@@ -117,3 +155,54 @@ func callCloudOllama(prompt string) (string, error) {
 
 	return ollamaResp.Message.Content, nil
 }
+
+// healthTimeout bounds the wait for the AI service to answer Health
+const healthTimeout = 10 * time.Second
+
+// Health checks that the AI of mode answers and offers its configured
+// model, before a run that depends on it
+func Health(mode string) error {
+	endpoint, model := config.CLOUD_AI_ENDPOINT, config.CLOUD_AI_MODEL
+	local := strings.ToLower(mode) == "local"
+	if local {
+		endpoint, model = config.LOCAL_AI_ENDPOINT, config.LOCAL_AI_MODEL
+	}
+	tags, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	tags.Path = "/api/tags"
+
+	req, err := http.NewRequest("GET", tags.String(), nil)
+	if err != nil {
+		return err
+	}
+	if !local {
+		apiKey := os.Getenv("OLLAMA_API_KEY")
+		if apiKey == "" {
+			return fmt.Errorf("OLLAMA_API_KEY is not set")
+		}
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	client := &http.Client{Timeout: healthTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s answered http %d", tags.Host, resp.StatusCode)
+	}
+
+	var list types.OllamaModels
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return fmt.Errorf("reading the models of %s: %v", tags.Host, err)
+	}
+	for _, m := range list.Models {
+		if m.Name == model || m.Name == model+":latest" {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s is not available at %s", model, tags.Host)
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// auditTimeLayout stamps the run directories and files of an audit
+const auditTimeLayout = "20060102T150405.000Z"
+
+// audit keeps the prompts and responses of the run once StartAudit is called
+var audit *auditLog
+
+type auditLog struct {
+	mu       sync.Mutex
+	dir      string
+	redact   bool
+	manifest types.AuditManifest
+}
+
+// StartAudit saves every prompt sent to the AI and its response as
+// timestamped files in a new run directory under dir, listed by the
+// manifest.json of the directory, which it returns. Unless raw is set, the
+// files are redacted with utils.RedactText. A prompt that cannot be saved is
+// not sent.
+func StartAudit(dir string, raw bool) (string, error) {
+	started := time.Now().UTC()
+	runDir := filepath.Join(dir, "run_"+started.Format(auditTimeLayout))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(runDir, 0700); err != nil {
+		return "", err
+	}
+
+	command := make([]string, len(os.Args))
+	for i, arg := range os.Args {
+		command[i] = utils.RedactURL(arg)
+		if !raw {
+			command[i] = utils.RedactText(command[i])
+		}
+	}
+	log := &auditLog{
+		dir:    runDir,
+		redact: !raw,
+		manifest: types.AuditManifest{
+			Command:   command,
+			StartedAt: started.Format(time.RFC3339),
+			Redacted:  !raw,
+			Exchanges: []types.AIExchange{},
+		},
+	}
+	if err := log.save(); err != nil {
+		return "", err
+	}
+	audit = log
+	return runDir, nil
+}
+
+// sent saves prompt and returns the index of its exchange
+func (a *auditLog) sent(mode, model, prompt string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	n := len(a.manifest.Exchanges)
+	name := fmt.Sprintf("%04d_%s_prompt.txt", n+1, now.Format(auditTimeLayout))
+	if err := a.write(name, prompt); err != nil {
+		return 0, fmt.Errorf("saving the prompt for the audit: %w", err)
+	}
+	a.manifest.Exchanges = append(a.manifest.Exchanges, types.AIExchange{
+		Mode:   mode,
+		Model:  model,
+		SentAt: now.Format(time.RFC3339),
+		Prompt: name,
+	})
+	return n, a.save()
+}
+
+// received saves the response, or the error, of exchange n
+func (a *auditLog) received(n int, response string, callErr error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	exchange := &a.manifest.Exchanges[n]
+	exchange.ReceivedAt = now.Format(time.RFC3339)
+	if callErr != nil {
+		exchange.Error = callErr.Error()
+		if a.redact {
+			exchange.Error = utils.RedactText(exchange.Error)
+		}
+	} else {
+		name := fmt.Sprintf("%04d_%s_response.txt", n+1, now.Format(auditTimeLayout))
+		if err := a.write(name, response); err != nil {
+			return fmt.Errorf("saving the AI response for the audit: %w", err)
+		}
+		exchange.Response = name
+	}
+	return a.save()
+}
+
+func (a *auditLog) write(name, text string) error {
+	if a.redact {
+		text = utils.RedactText(text)
+	}
+	return os.WriteFile(filepath.Join(a.dir, name), []byte(text), 0600)
+}
+
+// save rewrites the manifest, so that it lists the exchanges so far if the
+// run stops early
+func (a *auditLog) save() error {
+	return utils.SaveJSON(filepath.Join(a.dir, "manifest.json"), a.manifest)
+}
@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits apply to the requests of all callers of CallAI together, so that
+// concurrent jobs stay within the rate limits of the AI service
+type Limits struct {
+	// Concurrency is the most requests in flight at once, 0 for no limit
+	Concurrency int
+	// RequestsPerMinute is the most requests started per minute, 0 for no limit
+	RequestsPerMinute int
+}
+
+// DefaultLimits are the limits until SetLimits is called
+var DefaultLimits = Limits{Concurrency: 4}
+
+// maxRetries is how often a request the AI service is too busy for is retried
+const maxRetries = 3
+
+var queue = newRequestQueue(DefaultLimits)
+
+// SetLimits changes the limits of the AI requests. It is meant to be called
+// before the first request.
+func SetLimits(limits Limits) {
+	queue = newRequestQueue(limits)
+}
+
+// StatusError is an HTTP error answer of the AI service
+type StatusError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the wait asked for by the service, if any
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ollama cloud http %d:\n%s", e.StatusCode, e.Body)
+}
+
+// retryable reports whether the service was only too busy for the request
+func (e *StatusError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as a date
+func parseRetryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// requestQueue admits requests within the concurrency and the rate of its
+// limits, in the order they arrive
+type requestQueue struct {
+	slots chan struct{}
+	// interval separates the start of two requests
+	interval time.Duration
+
+	mu sync.Mutex
+	// next is the earliest start of the next request
+	next time.Time
+}
+
+func newRequestQueue(limits Limits) *requestQueue {
+	q := &requestQueue{}
+	if limits.Concurrency > 0 {
+		q.slots = make(chan struct{}, limits.Concurrency)
+	}
+	if limits.RequestsPerMinute > 0 {
+		q.interval = time.Minute / time.Duration(limits.RequestsPerMinute)
+	}
+	return q
+}
+
+// do runs call once the limits admit it, and again after a pause when the
+// service answers that it is too busy
+func (q *requestQueue) do(call func() (string, error)) (string, error) {
+	for attempt := 0; ; attempt++ {
+		q.acquire()
+		resp, err := call()
+		q.release()
+
+		var statusErr *StatusError
+		if err == nil || attempt == maxRetries || !errors.As(err, &statusErr) || !statusErr.retryable() {
+			return resp, err
+		}
+
+		delay := statusErr.RetryAfter
+		if delay == 0 {
+			delay = time.Second << attempt
+		}
+		fmt.Printf("⚠ AI service busy (http %d), retrying in %s\n", statusErr.StatusCode, delay)
+		q.pause(delay)
+	}
+}
+
+func (q *requestQueue) acquire() {
+	if q.slots != nil {
+		q.slots <- struct{}{}
+	}
+
+	q.mu.Lock()
+	now := time.Now()
+	start := q.next
+	if start.Before(now) {
+		start = now
+	}
+	q.next = start.Add(q.interval)
+	q.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+}
+
+func (q *requestQueue) release() {
+	if q.slots != nil {
+		<-q.slots
+	}
+}
+
+// pause holds back all requests for delay, since a busy answer to one
+// request applies to the others too
+func (q *requestQueue) pause(delay time.Duration) {
+	q.mu.Lock()
+	if resume := time.Now().Add(delay); resume.After(q.next) {
+		q.next = resume
+	}
+	q.mu.Unlock()
+}
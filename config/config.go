@@ -5,3 +5,20 @@ const CLOUD_AI_MODEL = "gpt-oss:120b"
 
 const LOCAL_AI_ENDPOINT = "http://localhost:11434/api/generate"
 const CLOUD_AI_ENDPOINT = "https://ollama.com/api/chat"
+
+const GOOGLE_SHEETS_ENDPOINT = "https://sheets.googleapis.com/v4/spreadsheets"
+const GOOGLE_SHEETS_SCOPE = "https://www.googleapis.com/auth/spreadsheets"
+
+const GOOGLE_TOKEN_ENDPOINT = "https://oauth2.googleapis.com/token"
+const GOOGLE_METADATA_ENDPOINT = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+const GOOGLE_STORAGE_ENDPOINT = "https://storage.googleapis.com"
+const GOOGLE_STORAGE_SCOPE = "https://www.googleapis.com/auth/devstorage.read_write"
+
+const AZURE_STORAGE_API_VERSION = "2021-08-06"
+const AZURE_STORAGE_RESOURCE = "https://storage.azure.com/"
+const AZURE_IMDS_ENDPOINT = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+const AWS_IMDS_ENDPOINT = "http://169.254.169.254"
+const AWS_ECS_ENDPOINT = "http://169.254.170.2"
+const AWS_SSO_ENDPOINT = "https://portal.sso.%s.amazonaws.com/federation/credentials"
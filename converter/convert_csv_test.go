@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ashr-tech/csv-migration-tools/fixture"
+	"github.com/ashr-tech/csv-migration-tools/transform"
+	"github.com/ashr-tech/csv-migration-tools/types"
+	"github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+func BenchmarkConvertData(b *testing.B) {
+	spec := fixture.Spec{Rows: 100000, Columns: 5, Mapped: 5}
+	records := fixture.Records(spec)
+	sourceSchema, targetSchema := fixture.Schemas(spec)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := convertData(records, sourceSchema, targetSchema, convertOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The benchmarks below convert files of a million rows, written once into
+// the temporary directory and kept there for later runs:
+//
+//	go test ./converter -run '^$' -bench 'Narrow|Wide' -benchtime 1x
+
+// fixtureDir keeps the fixtures between runs, as writing them takes longer
+// than converting them
+var fixtureDir = filepath.Join(os.TempDir(), "csv-migration-tools-fixtures")
+
+func BenchmarkConvertNarrow(b *testing.B) {
+	benchmarkConvert(b, fixture.Narrow, utils.ParserStandard)
+}
+
+func BenchmarkConvertNarrowFastParser(b *testing.B) {
+	benchmarkConvert(b, fixture.Narrow, utils.ParserFast)
+}
+
+func BenchmarkConvertWide(b *testing.B) {
+	benchmarkConvert(b, fixture.Wide, utils.ParserStandard)
+}
+
+func BenchmarkStreamNarrow(b *testing.B) {
+	benchmarkStream(b, fixture.Narrow)
+}
+
+func BenchmarkStreamWide(b *testing.B) {
+	benchmarkStream(b, fixture.Wide)
+}
+
+// BenchmarkStreamHuge streams 10 million rows, whose fixture takes about
+// 360 MB, so it is skipped with -short:
+//
+//	go test ./converter -run '^$' -bench StreamHuge -benchtime 1x
+func BenchmarkStreamHuge(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping the 10M-row fixture in short mode")
+	}
+	benchmarkStream(b, fixture.Huge)
+}
+
+// benchmarkConvert reads, converts and writes a fixture in memory
+func benchmarkConvert(b *testing.B, spec fixture.Spec, parser string) {
+	files, targetSchema, opts := setupBenchmark(b, spec)
+	opts.CSV.Parser = parser
+	source := types.MergeSource{SourceData: files.Data, SourceSchema: files.SourceSchema}
+	output := filepath.Join(b.TempDir(), "converted.csv")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		records, _, err := convertSource(nil, source, targetSchema, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := utils.WriteCSVWithOptions(output, records, utils.WriteOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(spec.Rows)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+// benchmarkStream converts a fixture row by row
+func benchmarkStream(b *testing.B, spec fixture.Spec) {
+	files, targetSchema, opts := setupBenchmark(b, spec)
+	source := types.MergeSource{SourceData: files.Data, SourceSchema: files.SourceSchema}
+	out := outputOptions{Extension: "csv"}
+
+	// The output goes to output/ under the working directory, and the
+	// statistics to stdout
+	dir, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	tmp := b.TempDir()
+	if err := os.Mkdir(filepath.Join(tmp, "output"), 0755); err != nil {
+		b.Fatal(err)
+	}
+	os.Chdir(tmp)
+	defer os.Chdir(dir)
+	discardStdout(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	peak := sampleHeap()
+	for i := 0; i < b.N; i++ {
+		if err := convertStream(source, targetSchema, "bench", opts, out); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(spec.Rows)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+	b.ReportMetric(float64(peak())/(1<<20), "peak-heap-MB")
+}
+
+// sampleHeap watches the heap in use until the returned function is called,
+// which returns its peak. A stream keeps it about the same for any file size.
+func sampleHeap() func() uint64 {
+	var peak uint64
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapInuse)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		<-stopped
+		return peak
+	}
+}
+
+func setupBenchmark(b *testing.B, spec fixture.Spec) (fixture.Files, []types.ColumnSchema, convertOptions) {
+	files, err := fixture.Write(fixtureDir, spec)
+	if err != nil {
+		b.Fatal(err)
+	}
+	targetSchema, err := utils.LoadSchemaJSON(files.TargetSchema)
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts := convertOptions{
+		CSV:         utils.CSVOptions{LazyQuotes: true, FieldsPerRecord: -1},
+		NullSource:  "warn",
+		IDCrosswalk: transform.NewIDCrosswalk(),
+		quiet:       true,
+	}
+	return files, targetSchema, opts
+}
+
+// discardStdout silences the printed statistics until the benchmark ends
+func discardStdout(b *testing.B) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	b.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
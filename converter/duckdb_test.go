@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ashr-tech/csv-migration-tools/fixture"
+	"github.com/ashr-tech/csv-migration-tools/transform"
+	"github.com/ashr-tech/csv-migration-tools/types"
+	"github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// TestDuckDBMatchesGo converts the sample schemas, a fixture and values that
+// stress the cleaning rules with both engines, since the SQL macros of the
+// DuckDB engine re-implement the Go conversion and must not drift from it.
+// It needs the duckdb CLI.
+func TestDuckDBMatchesGo(t *testing.T) {
+	if _, err := exec.LookPath("duckdb"); err != nil {
+		t.Skip("duckdb CLI not installed")
+	}
+
+	for i := 1; i <= 4; i++ {
+		t.Run(fmt.Sprintf("sample_%d", i), func(t *testing.T) {
+			sourceSchema, err := utils.LoadSchemaJSON(fmt.Sprintf("../output/schemas/source_schema_%d.json", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			targetSchema, err := utils.LoadSchemaJSON(fmt.Sprintf("../output/schemas/target_schema_%d.json", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareEngines(t, fmt.Sprintf("../input/source_data_%d.csv", i), sourceSchema, targetSchema)
+		})
+	}
+
+	t.Run("fixture", func(t *testing.T) {
+		spec := fixture.Spec{Rows: 5000, Columns: 10, Mapped: 10}
+		files, err := fixture.Write(t.TempDir(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sourceSchema, targetSchema := fixture.Schemas(spec)
+		compareEngines(t, files.Data, sourceSchema, targetSchema)
+	})
+
+	t.Run("cleaning", func(t *testing.T) {
+		two := 2
+		sourceSchema := []types.ColumnSchema{
+			{Column: "amount", TargetColumn: "amount"},
+			{Column: "amount_comma", TargetColumn: "amount_comma", DecimalSeparator: ","},
+			{Column: "cents", TargetColumn: "cents", Scale: 0.01},
+			{Column: "price", TargetColumn: "price"},
+			{Column: "quantity", TargetColumn: "quantity"},
+			{Column: "created", TargetColumn: "created", Format: "DD/MM/YYYY"},
+			{Column: "detected", TargetColumn: "detected"},
+			{Column: "serial", TargetColumn: "serial", Format: transform.ExcelSerialFormat},
+			{Column: "status", TargetColumn: "status", ValuesMapping: types.NewValueMap(map[string]string{"A": "active", "I": "inactive"})},
+		}
+		targetSchema := []types.ColumnSchema{
+			{Column: "amount", Type: "number"},
+			{Column: "amount_comma", Type: "number"},
+			{Column: "cents", Type: "number"},
+			{Column: "price", Type: "number", Decimals: &two},
+			{Column: "quantity", Type: "integer"},
+			{Column: "created", Type: "date"},
+			{Column: "detected", Type: "date"},
+			{Column: "serial", Type: "date"},
+			{Column: "status"},
+		}
+		records := [][]string{
+			{"amount", "amount_comma", "cents", "price", "quantity", "created", "detected", "serial", "status"},
+			{"1,234.56", "1.234,56", "12345", "2.345", "1,000", "31/12/2023", "2023-01-05", "45292", "A"},
+			{"1.234,56", "12,5", "-250", "2.355", "12.0", "01/02/2024", "2023-02-28", "1", "I"},
+			{"(12.50)", "(3,75)", "7", "-0.005", "12.5", "2024-01-01", "2023-13-01", "60", "X"},
+			{"$ 1 234,5", "€ 1 234,50", "", "10", "-7", "", "", "61", ""},
+			{"-7", "-0,5", "abc", "1e3", "x", "32/01/2024", "2023-12-31", "abc", " A "},
+			{".5", ",5", "0", "0.1", "0", "29/02/2023", "", "-1", "a"},
+			{"12-", "1.000", "1", "99.995", "1 000", "1/2/2024", "2024-02-29", "2958465", "A"},
+			{"abc", "", "100", "", "", " 05/06/2024 ", "2024-06-05", "", "I"},
+		}
+		source := filepath.Join(t.TempDir(), "cleaning.csv")
+		if err := utils.WriteCSVWithOptions(source, records, utils.WriteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		compareEngines(t, source, sourceSchema, targetSchema)
+	})
+}
+
+// compareEngines converts source with both engines and reports every cell
+// in which their outputs differ
+func compareEngines(t *testing.T, source string, sourceSchema, targetSchema []types.ColumnSchema) {
+	t.Helper()
+	records, _, err := utils.ReadCSVFileWithOptions(source, utils.CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := convertOptions{DayFirst: true, NullSource: "warn", IDCrosswalk: transform.NewIDCrosswalk(), quiet: true}
+	want, _, err := convertData(records, sourceSchema, targetSchema, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "converted.csv")
+	conversion := transform.DuckDBConversion{
+		Source:       source,
+		SourceSchema: sourceSchema,
+		TargetSchema: targetSchema,
+		DayFirst:     true,
+		NullSource:   "warn",
+		Output:       output,
+	}
+	if _, err := conversion.Run(); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := utils.ReadCSVFileWithOptions(output, utils.CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("DuckDB wrote %d rows, Go %d", len(got)-1, len(want)-1)
+	}
+	differences := 0
+	for r := range want {
+		for c := range want[r] {
+			gotValue := ""
+			if c < len(got[r]) {
+				gotValue = got[r][c]
+			}
+			if gotValue == want[r][c] {
+				continue
+			}
+			if differences++; differences <= 20 {
+				t.Errorf("row %d, column %s (source %q): DuckDB %q, Go %q", r+1, want[0][c], sourceValue(records, sourceSchema, want[0][c], r), gotValue, want[r][c])
+			}
+		}
+	}
+	if differences > 20 {
+		t.Errorf("%d differences in all", differences)
+	}
+}
+
+// sourceValue returns the source value of row r feeding target column
+// column, for messages
+func sourceValue(records [][]string, sourceSchema []types.ColumnSchema, column string, r int) string {
+	if r == 0 || r >= len(records) {
+		return ""
+	}
+	for _, sourceCol := range sourceSchema {
+		if sourceCol.TargetColumn != column {
+			continue
+		}
+		for i, name := range records[0] {
+			if name == sourceCol.Column && i < len(records[r]) {
+				return records[r][i]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+// Package coverage checks how completely a source schema maps onto its target
+// schema, as a quick check of schema quality before converting.
+package coverage
+
+import (
+	"slices"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// Schemas reports the target columns no source column maps to, the source
+// columns mapped nowhere, and for each categorical target column the target
+// values that no mapping produces. A source value without a values_mapping
+// entry passes through unchanged, so it covers the target value it equals.
+func Schemas(source, target []types.ColumnSchema) *types.CoverageReport {
+	report := &types.CoverageReport{Values: []types.ValueCoverage{}}
+
+	targetColumns := make(map[string]bool, len(target))
+	for _, col := range target {
+		targetColumns[col.Column] = true
+	}
+	sources := make(map[string][]types.ColumnSchema)
+	for _, col := range source {
+		switch {
+		case col.Column == "":
+			// "column": null marks a target column without a source
+		case col.TargetColumn == "":
+			report.UnusedSources = append(report.UnusedSources, col.Column)
+		case !targetColumns[col.TargetColumn]:
+			report.UnknownTargets = append(report.UnknownTargets, col.TargetColumn)
+		default:
+			sources[col.TargetColumn] = append(sources[col.TargetColumn], col)
+		}
+	}
+
+	for _, col := range target {
+		mapped := sources[col.Column]
+		if len(mapped) == 0 {
+			report.UnmappedTargets = append(report.UnmappedTargets, col.Column)
+		}
+		if len(col.Values) > 0 && len(mapped) > 0 {
+			report.Values = append(report.Values, values(col, mapped))
+		}
+	}
+
+	report.Complete = len(report.UnmappedTargets) == 0 && len(report.UnusedSources) == 0 && len(report.UnknownTargets) == 0
+	for _, coverage := range report.Values {
+		if len(coverage.Uncovered) > 0 || len(coverage.UnmappedValues) > 0 || len(coverage.InvalidTargets) > 0 {
+			report.Complete = false
+		}
+	}
+	return report
+}
+
+// values checks the values a categorical target column gets from the source
+// columns mapped to it
+func values(target types.ColumnSchema, sources []types.ColumnSchema) types.ValueCoverage {
+	coverage := types.ValueCoverage{Column: target.Column, Values: len(target.Values)}
+	produced := make(map[string]bool)
+	for _, col := range sources {
+		coverage.SourceColumns = append(coverage.SourceColumns, col.Column)
+		coverage.Lookup = coverage.Lookup || col.Lookup != nil
+
+		for _, mapped := range col.ValuesMapping.All() {
+			produced[mapped] = true
+			if !slices.Contains(target.Values, mapped) && !slices.Contains(coverage.InvalidTargets, mapped) {
+				coverage.InvalidTargets = append(coverage.InvalidTargets, mapped)
+			}
+		}
+		for _, value := range col.Values {
+			if _, found := col.ValuesMapping.Lookup(value); found {
+				continue
+			}
+			if slices.Contains(target.Values, value) {
+				produced[value] = true
+			} else if col.Lookup == nil && !slices.Contains(coverage.UnmappedValues, value) {
+				coverage.UnmappedValues = append(coverage.UnmappedValues, value)
+			}
+		}
+	}
+
+	for _, value := range target.Values {
+		if produced[value] {
+			coverage.Covered++
+		} else if !coverage.Lookup {
+			coverage.Uncovered = append(coverage.Uncovered, value)
+		}
+	}
+	return coverage
+}
@@ -0,0 +1,927 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/ashr-tech/csv-migration-tools/coverage"
+	"github.com/ashr-tech/csv-migration-tools/duplicates"
+	"github.com/ashr-tech/csv-migration-tools/manifest"
+	"github.com/ashr-tech/csv-migration-tools/preflight"
+	"github.com/ashr-tech/csv-migration-tools/profile"
+	"github.com/ashr-tech/csv-migration-tools/synthetic"
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+	"github.com/ashr-tech/csv-migration-tools/validate"
+)
+
+func main() {
+	// Usage: go run csvmigrate/csvmigrate.go profile <file> [options]
+	//        go run csvmigrate/csvmigrate.go validate <file> --target-schema <schema> [options]
+	//        go run csvmigrate/csvmigrate.go synth --target-schema <schema> --rows <n> [options]
+	//        go run csvmigrate/csvmigrate.go coverage --source-schema <schema> --target-schema <schema> [options]
+	//        go run csvmigrate/csvmigrate.go duplicates <file> [--key <columns>] [options]
+	//        go run csvmigrate/csvmigrate.go verify <manifest> [options]
+	//        go run csvmigrate/csvmigrate.go check --source-data <file> --source-schema <schema> --target-schema <schema> [options]
+	//        go run csvmigrate/csvmigrate.go from-db --dsn <url> --table <table> [options]
+	// Run a subcommand with --help to list its options
+
+	if len(os.Args) < 2 {
+		log.Fatalf("Usage: csvmigrate profile|validate|synth|coverage|duplicates|verify|check|from-db [options]")
+	}
+
+	switch os.Args[1] {
+	case "profile":
+		profileCommand(os.Args[2:])
+	case "validate":
+		validateCommand(os.Args[2:])
+	case "synth":
+		synthCommand(os.Args[2:])
+	case "coverage":
+		coverageCommand(os.Args[2:])
+	case "duplicates":
+		duplicatesCommand(os.Args[2:])
+	case "verify":
+		verifyCommand(os.Args[2:])
+	case "check":
+		checkCommand(os.Args[2:])
+	case "from-db":
+		fromDBCommand(os.Args[2:])
+	default:
+		log.Fatalf("Unknown subcommand %q: must be profile, validate, synth, coverage, duplicates, verify, check or from-db", os.Args[1])
+	}
+}
+
+// profileCommand reports per-column statistics of a source file, to check it
+// before writing its schemas
+func profileCommand(args []string) {
+	flags := flag.NewFlagSet("profile", flag.ExitOnError)
+	name := flags.String("name", "", "Name for the profile file (default: the source file name)")
+	format := flags.String("format", "csv", "File format of the source: csv or tsv (.xlsx sources are always read as workbooks)")
+	encoding := flags.String("encoding", "", "Character encoding of the source, e.g. windows-1252 (default: detected)")
+	inDelimiter := flags.String("in-delimiter", "", "Source field delimiter, e.g. ';', tab or '~|~' (default: detected from the file)")
+	sheet := flags.String("sheet", "", "Worksheet of .xlsx sources, by name or 1-based index (default: the first)")
+	topValues := flags.Int("top", profile.DefaultOptions.TopValues, "Number of most frequent values reported per column")
+	maxDistinct := flags.Int("max-distinct", profile.DefaultOptions.MaxDistinct, "Distinct values counted per column before counting stops, bounding memory")
+	examples := flags.Int("examples", profile.DefaultOptions.Examples, "Example values kept of each kind of anomaly")
+	dateOrder := flags.String("date-order", "dmy", "Order of ambiguous dates like 01/02/2024: dmy or mdy")
+	asJSON := flags.Bool("json", false, "Print the profile as JSON instead of a table")
+	dataPath := parseWithFile(flags, args)
+
+	if dataPath == "" {
+		log.Fatalf("Usage: csvmigrate profile <file> [options]")
+	}
+	if *topValues < 0 || *maxDistinct <= 0 || *examples < 0 {
+		log.Fatalf("--top and --examples must not be negative, and --max-distinct must be positive")
+	}
+	if *dateOrder != "dmy" && *dateOrder != "mdy" {
+		log.Fatalf("Invalid --date-order %q: must be dmy or mdy", *dateOrder)
+	}
+	csvOptions := sourceOptions(*format, *encoding, *inDelimiter, *sheet)
+	if *name == "" {
+		base := filepath.Base(dataPath)
+		*name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	options := profile.Options{
+		TopValues:   *topValues,
+		MaxDistinct: *maxDistinct,
+		Examples:    *examples,
+		DayFirst:    *dateOrder == "dmy",
+	}
+	result, err := profile.File(dataPath, csvOptions, options)
+	if err != nil {
+		log.Fatalf("Error profiling %s: %v", utils.RedactURL(dataPath), err)
+	}
+
+	profileFile := fmt.Sprintf("output/profiles/profile_%s.json", *name)
+	if err := os.MkdirAll(filepath.Dir(profileFile), 0755); err != nil {
+		log.Fatalf("Error creating profile directory: %v", err)
+	}
+	if err := utils.SaveJSON(profileFile, result); err != nil {
+		log.Fatalf("Error saving profile: %v", err)
+	}
+
+	if *asJSON {
+		report, err := json.Marshal(result)
+		if err != nil {
+			log.Fatalf("Error encoding profile: %v", err)
+		}
+		fmt.Println(string(report))
+		return
+	}
+	printProfile(result)
+	fmt.Printf("\n✓ %s generated successfully\n", profileFile)
+}
+
+// validateCommand checks a converted file against its target schema, or a
+// source file against the rules of its source schema, and exits with status
+// 1 when it does not conform
+func validateCommand(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	targetSchemaPath := flags.String("target-schema", "", "Target schema JSON the converted file must conform to")
+	sourceSchemaPath := flags.String("source-schema", "", "Source schema JSON whose rules the source file must follow, instead of --target-schema")
+	format := flags.String("format", "csv", "File format of the file: csv or tsv (.xlsx, .json and .jsonl files are read as such)")
+	encoding := flags.String("encoding", "", "Character encoding of the file, e.g. windows-1252 (default: detected)")
+	inDelimiter := flags.String("in-delimiter", "", "Field delimiter of the file, e.g. ';', tab or '~|~' (default: detected from the file)")
+	sheet := flags.String("sheet", "", "Worksheet of .xlsx files, by name or 1-based index (default: the first)")
+	examples := flags.Int("examples", validate.DefaultOptions.Examples, "Failing values reported of each check")
+	dateOrder := flags.String("date-order", "dmy", "Order of ambiguous dates like 01/02/2024 checked against a date range without a format: dmy or mdy")
+	reportPath := flags.String("report", "", "Also save the report as JSON to this file")
+	asJSON := flags.Bool("json", false, "Print the report as JSON instead of text")
+	path := parseWithFile(flags, args)
+
+	if path == "" {
+		log.Fatalf("Usage: csvmigrate validate <file> --target-schema <schema> [options]")
+	}
+	if (*targetSchemaPath == "") == (*sourceSchemaPath == "") {
+		log.Fatalf("Either --target-schema or --source-schema is required")
+	}
+	if *examples < 0 {
+		log.Fatalf("--examples must not be negative")
+	}
+	if *dateOrder != "dmy" && *dateOrder != "mdy" {
+		log.Fatalf("Invalid --date-order %q: must be dmy or mdy", *dateOrder)
+	}
+	csvOptions := sourceOptions(*format, *encoding, *inDelimiter, *sheet)
+
+	options := validate.Options{Examples: *examples, DayFirst: *dateOrder == "dmy"}
+	schemaPath := *targetSchemaPath
+	if *sourceSchemaPath != "" {
+		options.Source, schemaPath = true, *sourceSchemaPath
+	}
+	report, err := validate.File(path, schemaPath, csvOptions, options)
+	if err != nil {
+		log.Fatalf("Error validating %s: %v", utils.RedactURL(path), err)
+	}
+	if *reportPath != "" {
+		if err := utils.SaveJSON(*reportPath, report); err != nil {
+			log.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if *asJSON {
+		encoded, err := json.Marshal(report)
+		if err != nil {
+			log.Fatalf("Error encoding report: %v", err)
+		}
+		fmt.Println(string(encoded))
+	} else {
+		printValidation(report)
+	}
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
+
+// synthCommand writes fake rows conforming to a target schema, to test the
+// target's import before the converted data is ready
+func synthCommand(args []string) {
+	flags := flag.NewFlagSet("synth", flag.ExitOnError)
+	targetSchemaPath := flags.String("target-schema", "", "Target schema JSON the rows conform to")
+	rows := flags.Int("rows", 1000, "Number of rows to generate")
+	seed := flags.Int64("seed", synthetic.DefaultOptions.Seed, "Random seed; the same seed gives the same rows")
+	nullRate := flags.Float64("null-rate", synthetic.DefaultOptions.NullRate, "Share of empty values in columns that are not required, from 0 to 1")
+	outputFormat := flags.String("output-format", "csv", "File format: csv, tsv or jsonl")
+	name := flags.String("name", "", "Name for the output file (default: the target schema file name)")
+	flags.Parse(args)
+
+	if *targetSchemaPath == "" {
+		log.Fatalf("--target-schema is required")
+	}
+	if *rows < 0 {
+		log.Fatalf("--rows must not be negative")
+	}
+	if *nullRate < 0 || *nullRate > 1 {
+		log.Fatalf("Invalid --null-rate %v: must be from 0 to 1", *nullRate)
+	}
+	if *outputFormat != "csv" && *outputFormat != "tsv" && *outputFormat != "jsonl" {
+		log.Fatalf("Invalid --output-format %q: must be csv, tsv or jsonl", *outputFormat)
+	}
+	if *name == "" {
+		base := filepath.Base(*targetSchemaPath)
+		*name = strings.TrimPrefix(strings.TrimSuffix(base, filepath.Ext(base)), "target_schema_")
+	}
+
+	targetSchema, err := utils.LoadSchemaJSON(*targetSchemaPath)
+	if err != nil {
+		log.Fatalf("Error loading target schema: %v", err)
+	}
+
+	outputFile := fmt.Sprintf("output/synthetic_%s.%s", *name, *outputFormat)
+	options := utils.WriteOptions{ColumnTypes: make(map[string]string)}
+	for _, col := range targetSchema {
+		options.ColumnTypes[col.Column] = col.Type
+	}
+	writer, err := utils.CreateRecordWriter(outputFile, options)
+	if err != nil {
+		log.Fatalf("Error creating %s: %v", outputFile, err)
+	}
+	generator := synthetic.New(targetSchema, synthetic.Options{Seed: *seed, NullRate: *nullRate})
+	err = writer.Write(generator.Header())
+	for i := 0; i < *rows && err == nil; i++ {
+		err = writer.Write(generator.Next())
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Error writing %s: %v", outputFile, err)
+	}
+	fmt.Printf("✓ %s generated successfully with %d rows\n", outputFile, *rows)
+}
+
+// coverageCommand reports how completely a source schema maps onto its target
+// schema and exits with status 1 when it does not
+func coverageCommand(args []string) {
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	sourceSchemaPath := flags.String("source-schema", "", "Source schema JSON mapping source columns to the target")
+	targetSchemaPath := flags.String("target-schema", "", "Target schema JSON the source schema maps onto")
+	reportPath := flags.String("report", "", "Also save the report as JSON to this file")
+	asJSON := flags.Bool("json", false, "Print the report as JSON instead of text")
+	flags.Parse(args)
+
+	if *sourceSchemaPath == "" || *targetSchemaPath == "" {
+		log.Fatalf("--source-schema and --target-schema are required")
+	}
+	sourceSchema, err := utils.LoadSchemaJSON(*sourceSchemaPath)
+	if err != nil {
+		log.Fatalf("Error loading source schema: %v", err)
+	}
+	targetSchema, err := utils.LoadSchemaJSON(*targetSchemaPath)
+	if err != nil {
+		log.Fatalf("Error loading target schema: %v", err)
+	}
+
+	report := coverage.Schemas(sourceSchema, targetSchema)
+	report.SourceSchema, report.TargetSchema = *sourceSchemaPath, *targetSchemaPath
+	if *reportPath != "" {
+		if err := utils.SaveJSON(*reportPath, report); err != nil {
+			log.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if *asJSON {
+		encoded, err := json.Marshal(report)
+		if err != nil {
+			log.Fatalf("Error encoding report: %v", err)
+		}
+		fmt.Println(string(encoded))
+	} else {
+		printCoverage(report)
+	}
+	if !report.Complete {
+		os.Exit(1)
+	}
+}
+
+// duplicatesCommand reports the repeated rows of a source file, and with key
+// columns the records repeated with differing values
+func duplicatesCommand(args []string) {
+	flags := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	key := flags.String("key", "", "Comma-separated source columns identifying a record, to find near duplicates: rows sharing a key but differing in other columns")
+	format := flags.String("format", "csv", "File format of the source: csv or tsv (.xlsx sources are always read as workbooks)")
+	encoding := flags.String("encoding", "", "Character encoding of the source, e.g. windows-1252 (default: detected)")
+	inDelimiter := flags.String("in-delimiter", "", "Source field delimiter, e.g. ';', tab or '~|~' (default: detected from the file)")
+	sheet := flags.String("sheet", "", "Worksheet of .xlsx sources, by name or 1-based index (default: the first)")
+	examples := flags.Int("examples", duplicates.DefaultExamples, "Repeated rows and keys listed, each with its first rows")
+	reportPath := flags.String("report", "", "Also save the report as JSON to this file")
+	asJSON := flags.Bool("json", false, "Print the report as JSON instead of text")
+	path := parseWithFile(flags, args)
+
+	if path == "" {
+		log.Fatalf("Usage: csvmigrate duplicates <file> [--key <columns>] [options]")
+	}
+	if *examples < 0 {
+		log.Fatalf("--examples must not be negative")
+	}
+	var keyColumns []string
+	if *key != "" {
+		for _, column := range strings.Split(*key, ",") {
+			keyColumns = append(keyColumns, strings.TrimSpace(column))
+		}
+	}
+	csvOptions := sourceOptions(*format, *encoding, *inDelimiter, *sheet)
+
+	report, err := duplicates.File(path, keyColumns, csvOptions, *examples)
+	if err != nil {
+		log.Fatalf("Error checking %s for duplicates: %v", utils.RedactURL(path), err)
+	}
+	if *reportPath != "" {
+		if err := utils.SaveJSON(*reportPath, report); err != nil {
+			log.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if *asJSON {
+		encoded, err := json.Marshal(report)
+		if err != nil {
+			log.Fatalf("Error encoding report: %v", err)
+		}
+		fmt.Println(string(encoded))
+		return
+	}
+	printDuplicates(report)
+}
+
+// verifyCommand checks the files of a run against the checksums of its
+// --run-manifest and exits with status 1 when any changed
+func verifyCommand(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	outputsOnly := flags.Bool("outputs-only", false, "Only check the output files, not the inputs and schemas")
+	asJSON := flags.Bool("json", false, "Print the report as JSON instead of text")
+	path := parseWithFile(flags, args)
+
+	if path == "" {
+		log.Fatalf("Usage: csvmigrate verify <manifest> [options]")
+	}
+	report, err := manifest.Verify(path, *outputsOnly)
+	if err != nil {
+		log.Fatalf("Error verifying %s: %v", path, err)
+	}
+
+	if *asJSON {
+		encoded, err := json.Marshal(report)
+		if err != nil {
+			log.Fatalf("Error encoding report: %v", err)
+		}
+		fmt.Println(string(encoded))
+	} else {
+		printVerification(report)
+	}
+	if report.Failed > 0 || report.Signature == "invalid" {
+		os.Exit(1)
+	}
+}
+
+// checkCommand runs the pre-flight checks of a conversion and exits with
+// status 1 when any found a blocker
+func checkCommand(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	sourceData := flags.String("source-data", "", "Source file the conversion reads (default: none, skipping the header and disk checks)")
+	sourceSchemaPath := flags.String("source-schema", "", "Source schema JSON of the conversion")
+	targetSchemaPath := flags.String("target-schema", "", "Target schema JSON of the conversion")
+	aiMode := flags.String("ai-mode", "", "AI mode (CLOUD/LOCAL) the conversion uses, e.g. with --ai-unmapped, to check that it answers (default: no AI)")
+	outputDir := flags.String("output-dir", "output", "Directory the conversion writes to, checked for write access and free space")
+	format := flags.String("format", "csv", "File format of the source: csv or tsv (.xlsx sources are always read as workbooks)")
+	encoding := flags.String("encoding", "", "Character encoding of the source, e.g. windows-1252 (default: detected)")
+	inDelimiter := flags.String("in-delimiter", "", "Source field delimiter, e.g. ';', tab or '~|~' (default: detected from the file)")
+	sheet := flags.String("sheet", "", "Worksheet of .xlsx sources, by name or 1-based index (default: the first)")
+	reportPath := flags.String("report", "", "Also save the report as JSON to this file")
+	asJSON := flags.Bool("json", false, "Print the report as JSON instead of text")
+	flags.Parse(args)
+
+	if *sourceSchemaPath == "" || *targetSchemaPath == "" {
+		log.Fatalf("--source-schema and --target-schema are required")
+	}
+	if mode := strings.ToUpper(*aiMode); mode != "" && mode != "CLOUD" && mode != "LOCAL" {
+		log.Fatalf("Invalid --ai-mode %q: must be CLOUD or LOCAL", *aiMode)
+	}
+
+	report := preflight.Run(preflight.Config{
+		SourceData:   *sourceData,
+		SourceSchema: *sourceSchemaPath,
+		TargetSchema: *targetSchemaPath,
+		CSV:          sourceOptions(*format, *encoding, *inDelimiter, *sheet),
+		AIMode:       *aiMode,
+		OutputDir:    *outputDir,
+	})
+	if *reportPath != "" {
+		if err := utils.SaveJSON(*reportPath, report); err != nil {
+			log.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if *asJSON {
+		encoded, err := json.Marshal(report)
+		if err != nil {
+			log.Fatalf("Error encoding report: %v", err)
+		}
+		fmt.Println(string(encoded))
+	} else {
+		printPreflight(report)
+	}
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
+
+// fromDBCommand builds a target or source schema from the metadata and a
+// sample of a database table, without an AI call or sample CSV
+func fromDBCommand(args []string) {
+	flags := flag.NewFlagSet("from-db", flag.ExitOnError)
+	dsn := flags.String("dsn", "", "Database URL, postgres://... or mysql://...")
+	table := flags.String("table", "", "Table to describe, optionally schema-qualified like sales.customers")
+	role := flags.String("role", "target", "Schema to build: target, or source to map the table onto --target-schema")
+	targetSchemaPath := flags.String("target-schema", "", "Target schema JSON the source columns are mapped onto, for --role source")
+	sampleRows := flags.Int("sample", 1000, "Number of rows sampled for categorical values")
+	maxValues := flags.Int("max-values", 10, "Text columns with at most this many distinct sampled values become categorical in target schemas; 0 uses enum types only")
+	name := flags.String("name", "", "Name for the schema file (default: the table name)")
+	flags.Parse(args)
+
+	if !utils.IsDatabaseURL(*dsn) {
+		log.Fatalf("Invalid --dsn %q: must be a postgres:// or mysql:// URL", *dsn)
+	}
+	if *table == "" {
+		log.Fatalf("--table is required")
+	}
+	if *role != "target" && *role != "source" {
+		log.Fatalf("Invalid --role %q: must be target or source", *role)
+	}
+	if (*role == "source") != (*targetSchemaPath != "") {
+		log.Fatalf("--target-schema is required with --role source, and only there")
+	}
+	if *sampleRows < 0 || *maxValues < 0 {
+		log.Fatalf("--sample and --max-values must not be negative")
+	}
+	if *name == "" {
+		*name = (*table)[strings.LastIndex(*table, ".")+1:]
+	}
+
+	fmt.Printf("Reading the columns of %s...\n", *table)
+	described, err := utils.DescribeTable(*dsn, *table)
+	if err != nil {
+		log.Fatalf("Error describing %s: %v", *table, err)
+	}
+
+	var sample [][]string
+	if *sampleRows > 0 {
+		if sample, err = utils.SampleTable(*dsn, *table, *sampleRows); err != nil {
+			fmt.Printf("⚠ Could not sample %s, using the metadata only: %v\n", *table, err)
+		}
+	}
+
+	entry := types.ProjectTable{Name: *name}
+	var schema []types.ColumnSchema
+	var schemaFile string
+	if *role == "target" {
+		schema = targetSchemaFromDB(described, sample, *maxValues)
+		schemaFile = fmt.Sprintf("output/schemas/target_schema_%s.json", *name)
+		entry.TargetSchema = schemaFile
+		entry.References = described.References
+		if described.PrimaryKey != "" {
+			entry.Key = &types.TableKey{Column: described.PrimaryKey}
+		}
+	} else {
+		targetSchema, err := utils.LoadSchemaJSON(*targetSchemaPath)
+		if err != nil {
+			log.Fatalf("Error loading target schema: %v", err)
+		}
+		schema = sourceSchemaFromDB(described, sample, targetSchema)
+		schemaFile = fmt.Sprintf("output/schemas/source_schema_%s.json", *name)
+		entry.SourceData = utils.StripPassword(*dsn)
+		entry.Query = "SELECT * FROM " + utils.QuoteTable(*dsn, *table)
+		entry.SourceSchema = schemaFile
+		entry.TargetSchema = *targetSchemaPath
+	}
+
+	if err := utils.SaveJSON(schemaFile, schema); err != nil {
+		log.Fatalf("Error saving schema: %v", err)
+	}
+	fmt.Printf("✓ %s generated successfully\n", schemaFile)
+
+	// Foreign keys have no place in the schema itself
+	entryJSON, _ := json.MarshalIndent(entry, "", "  ")
+	fmt.Printf("\nProject table entry:\n%s\n", entryJSON)
+}
+
+// targetSchemaFromDB types the columns from the metadata. Enum labels are the
+// categorical values, and so are the sampled values of text columns with few
+// distinct values, unless they look like keys.
+func targetSchemaFromDB(described utils.DBTable, sample [][]string, maxValues int) []types.ColumnSchema {
+	schema := make([]types.ColumnSchema, len(described.Columns))
+	for i, column := range described.Columns {
+		schema[i] = types.ColumnSchema{
+			Column:   column.Name,
+			Values:   []string{},
+			Required: column.Required,
+			Unique:   column.Name == described.PrimaryKey,
+			Type:     column.Type,
+			Decimals: column.Decimals,
+		}
+
+		if len(column.Values) > 0 {
+			schema[i].Values = column.Values
+			continue
+		}
+
+		isReference := slices.ContainsFunc(described.References, func(ref types.TableReference) bool { return ref.Column == column.Name })
+		if column.Type != "" || maxValues == 0 || isReference || column.Name == described.PrimaryKey ||
+			strings.HasSuffix(strings.ToLower(column.Name), "_id") {
+			continue
+		}
+		values, filled := sampledValues(sample, column.Name)
+		// Each value must repeat, or the column is rather free text
+		if len(values) > 0 && len(values) <= maxValues && filled >= 2*len(values) {
+			schema[i].Values = values
+		}
+	}
+	return schema
+}
+
+// sourceSchemaFromDB maps the columns onto the target columns of the same
+// name, ignoring case and punctuation. For categorical target columns the
+// sampled values are mapped onto the target values that match them.
+func sourceSchemaFromDB(described utils.DBTable, sample [][]string, targetSchema []types.ColumnSchema) []types.ColumnSchema {
+	mapped := make(map[string]bool)
+	schema := make([]types.ColumnSchema, len(described.Columns))
+	for i, column := range described.Columns {
+		schema[i] = types.ColumnSchema{Column: column.Name, Values: []string{}}
+
+		var target *types.ColumnSchema
+		for t := range targetSchema {
+			if !mapped[targetSchema[t].Column] && normalizeName(targetSchema[t].Column) == normalizeName(column.Name) {
+				target = &targetSchema[t]
+				break
+			}
+		}
+		if target == nil {
+			continue
+		}
+		mapped[target.Column] = true
+		schema[i].TargetColumn = target.Column
+		if len(target.Values) == 0 {
+			continue
+		}
+
+		values, _ := sampledValues(sample, column.Name)
+		for _, label := range column.Values {
+			if !slices.Contains(values, label) {
+				values = append(values, label)
+			}
+		}
+		schema[i].Values = values
+		mapping := make(map[string]string)
+		for _, value := range values {
+			for _, targetValue := range target.Values {
+				if strings.EqualFold(value, targetValue) {
+					mapping[value] = targetValue
+					break
+				}
+			}
+			if _, exists := mapping[value]; !exists {
+				fmt.Printf("⚠ %s: no target value for %q, add it to values_mapping\n", column.Name, value)
+			}
+		}
+		if len(mapping) > 0 {
+			schema[i].ValuesMapping = types.NewValueMap(mapping)
+		}
+	}
+
+	for _, targetCol := range targetSchema {
+		if !mapped[targetCol.Column] {
+			fmt.Printf("⚠ Target column %s has no source column of the same name\n", targetCol.Column)
+		}
+	}
+	return schema
+}
+
+// sampledValues returns the distinct non-empty values of a sampled column and
+// the number of rows that have a value
+func sampledValues(sample [][]string, column string) ([]string, int) {
+	if len(sample) == 0 {
+		return nil, 0
+	}
+	index := slices.Index(sample[0], column)
+	if index < 0 {
+		return nil, 0
+	}
+
+	var values []string
+	filled := 0
+	for _, row := range sample[1:] {
+		if index >= len(row) || row[index] == "" {
+			continue
+		}
+		filled++
+		if !slices.Contains(values, row[index]) {
+			values = append(values, row[index])
+		}
+	}
+	slices.Sort(values)
+	return values, filled
+}
+
+// normalizeName lowercases a column name and drops everything but letters
+// and digits, so that CustomerID matches customer_id
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// parseWithFile parses flags given before or after a file argument, at
+// which the flag package would otherwise stop, and returns the file
+func parseWithFile(flags *flag.FlagSet, args []string) string {
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return ""
+	}
+	path := flags.Arg(0)
+	flags.Parse(flags.Args()[1:])
+	if flags.NArg() > 0 {
+		log.Fatalf("Unexpected argument %q", flags.Arg(0))
+	}
+	return path
+}
+
+// sourceOptions reads files like the converter reads sources given the same
+// options, exiting on invalid ones
+func sourceOptions(format, encoding, inDelimiter, sheet string) utils.CSVOptions {
+	if format != "csv" && format != "tsv" {
+		log.Fatalf("Invalid --format %q: must be csv or tsv", format)
+	}
+	if _, err := utils.LookupEncoding(encoding); encoding != "" && err != nil {
+		log.Fatalf("Invalid encoding: %v", err)
+	}
+
+	csvOptions := utils.DefaultCSVOptions
+	csvOptions.Encoding = encoding
+	csvOptions.Sheet = sheet
+	if format == "tsv" {
+		csvOptions.Delimiter = '\t'
+	}
+	if inDelimiter != "" {
+		var err error
+		if csvOptions.Delimiter, csvOptions.MultiDelimiter, err = utils.ParseInputDelimiter(inDelimiter); err != nil {
+			log.Fatalf("Invalid --in-delimiter: %v", err)
+		}
+	}
+	return csvOptions
+}
+
+func printValidation(report *types.ValidationReport) {
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("VALIDATION OF %s AGAINST %s:\n", report.File, report.Schema)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("Rows: %d\n", report.Rows)
+	for _, headerError := range report.HeaderErrors {
+		fmt.Printf("⚠ Header: %s\n", headerError)
+	}
+	if report.RaggedRows > 0 {
+		fmt.Printf("⚠ Rows with more or fewer fields than the header: %d\n", report.RaggedRows)
+	}
+	fmt.Println()
+	fmt.Printf("%-30s %-8s %s\n", "COLUMN", "RESULT", "CHECKS")
+
+	for _, col := range report.Columns {
+		result := "ok"
+		switch {
+		case len(col.Checks) == 0 && slices.Contains(report.HeaderErrors, fmt.Sprintf("missing column %q", col.Column)):
+			result = "missing"
+		case len(col.Failures) > 0:
+			result = "FAILED"
+		case len(col.Suspicious) > 0:
+			result = "suspect"
+		}
+		fmt.Printf("%-30s %-8s %s\n", col.Column, result, strings.Join(col.Checks, ", "))
+		printAnomalies(col.Failures)
+		printAnomalies(col.Suspicious)
+	}
+
+	if len(report.Keys) > 0 {
+		fmt.Println()
+		fmt.Printf("%-30s %-8s %s\n", "UNIQUE KEY", "RESULT", "DUPLICATES")
+	}
+	for _, key := range report.Keys {
+		result := "ok"
+		if key.Duplicates > 0 {
+			result = "FAILED"
+		}
+		fmt.Printf("%-30s %-8s %d\n", strings.Join(key.Columns, ", "), result, key.Duplicates)
+		for _, duplicate := range key.Examples {
+			rows := make([]string, len(duplicate.Rows))
+			for i, row := range duplicate.Rows {
+				rows[i] = fmt.Sprint(row)
+			}
+			fmt.Printf("    ⚠ %q in rows %s\n", duplicate.Key, strings.Join(rows, ", "))
+		}
+	}
+
+	fmt.Println()
+	if report.Passed {
+		fmt.Printf("✓ %s conforms to %s\n", report.File, report.Schema)
+	} else {
+		fmt.Printf("⚠ %s does not conform to %s\n", report.File, report.Schema)
+	}
+}
+
+func printCoverage(report *types.CoverageReport) {
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("COVERAGE OF %s BY %s:\n", report.TargetSchema, report.SourceSchema)
+	fmt.Println(strings.Repeat("-", 80))
+	if len(report.UnmappedTargets) > 0 {
+		fmt.Printf("⚠ Target columns without a source column: %s\n", strings.Join(report.UnmappedTargets, ", "))
+	}
+	if len(report.UnusedSources) > 0 {
+		fmt.Printf("⚠ Source columns mapped to no target column: %s\n", strings.Join(report.UnusedSources, ", "))
+	}
+	if len(report.UnknownTargets) > 0 {
+		fmt.Printf("⚠ Target columns missing from the target schema: %s\n", strings.Join(report.UnknownTargets, ", "))
+	}
+
+	if len(report.Values) > 0 {
+		fmt.Println()
+		fmt.Printf("%-30s %-30s %s\n", "CATEGORICAL COLUMN", "SOURCE COLUMNS", "VALUES COVERED")
+	}
+	for _, col := range report.Values {
+		covered := fmt.Sprintf("%d of %d", col.Covered, col.Values)
+		if col.Lookup {
+			covered += " and lookup"
+		}
+		fmt.Printf("%-30s %-30s %s\n", col.Column, strings.Join(col.SourceColumns, ", "), covered)
+		if len(col.Uncovered) > 0 {
+			fmt.Printf("    ⚠ target values no mapping produces: %s\n", quoteList(col.Uncovered))
+		}
+		if len(col.UnmappedValues) > 0 {
+			fmt.Printf("    ⚠ source values without a mapping: %s\n", quoteList(col.UnmappedValues))
+		}
+		if len(col.InvalidTargets) > 0 {
+			fmt.Printf("    ⚠ mapped values that are no target value: %s\n", quoteList(col.InvalidTargets))
+		}
+	}
+
+	fmt.Println()
+	if report.Complete {
+		fmt.Printf("✓ %s covers %s completely\n", report.SourceSchema, report.TargetSchema)
+	} else {
+		fmt.Printf("⚠ %s does not cover %s completely\n", report.SourceSchema, report.TargetSchema)
+	}
+}
+
+func printDuplicates(report *types.DuplicateReport) {
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("DUPLICATES IN %s:\n", report.Source)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("Rows: %d\n", report.Rows)
+	fmt.Printf("Rows equal to an earlier row: %d\n", report.ExactRows)
+	for _, group := range report.Exact {
+		fmt.Printf("    ⚠ rows %s\n", rowList(group.Rows))
+	}
+	if len(report.Key) > 0 {
+		fmt.Printf("Keys (%s) of rows differing in other columns: %d, with %d rows after the first\n",
+			strings.Join(report.Key, ", "), report.NearKeys, report.NearRows)
+		for _, group := range report.Near {
+			fmt.Printf("    ⚠ %q in rows %s, differing in %s\n", group.Key, rowList(group.Rows), strings.Join(group.Columns, ", "))
+		}
+		if len(report.DifferingColumns) > 0 {
+			fmt.Println()
+			fmt.Printf("%-30s %s\n", "COLUMN", "NEAR DUPLICATES DIFFERING")
+		}
+		for _, col := range report.DifferingColumns {
+			fmt.Printf("%-30s %d\n", col.Column, col.Count)
+		}
+	}
+
+	fmt.Println()
+	if report.ExactRows == 0 && report.NearRows == 0 {
+		fmt.Printf("✓ No duplicates in %s\n", report.Source)
+	} else {
+		fmt.Printf("⚠ %s holds duplicates; decide how to handle them before migrating\n", report.Source)
+	}
+}
+
+func printVerification(report *types.ManifestVerification) {
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("VERIFICATION OF %s:\n", report.Manifest)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-10s %-10s %s\n", "ROLE", "STATUS", "FILE")
+	for _, file := range report.Files {
+		fmt.Printf("%-10s %-10s %s\n", file.Role, file.Status, file.Path)
+		if file.Status == "changed" {
+			fmt.Printf("    ⚠ expected sha256 %s, found %s\n", file.Expected, file.Actual)
+		}
+	}
+
+	fmt.Println()
+	switch report.Signature {
+	case "invalid":
+		fmt.Println("⚠ The manifest was edited after the run: its signature does not match")
+	case "unverified":
+		fmt.Printf("⚠ The manifest signature was not checked: set %s to the key it was signed with\n", manifest.KeyEnv)
+	}
+	if report.Failed > 0 {
+		fmt.Printf("⚠ %d files changed or missing since the run\n", report.Failed)
+	} else if report.Signature != "invalid" {
+		fmt.Println("✓ All files match the manifest")
+	}
+}
+
+func printPreflight(report *types.PreflightReport) {
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Println("PRE-FLIGHT CHECKS:")
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-15s %-10s %s\n", "CHECK", "STATUS", "NOTE")
+	for _, check := range report.Checks {
+		fmt.Printf("%-15s %-10s %s\n", check.Name, check.Status, check.Note)
+		for _, blocker := range check.Blockers {
+			fmt.Printf("    ⚠ blocker: %s\n", blocker)
+		}
+		for _, warning := range check.Warnings {
+			fmt.Printf("    ⚠ %s\n", warning)
+		}
+	}
+
+	fmt.Println()
+	if report.Passed {
+		fmt.Printf("✓ Ready to convert, with %d warnings\n", report.Warnings)
+	} else {
+		fmt.Printf("⚠ %d blockers and %d warnings; fix the blockers before converting\n", report.Blockers, report.Warnings)
+	}
+}
+
+func rowList(rows []int) string {
+	list := make([]string, len(rows))
+	for i, row := range rows {
+		list[i] = fmt.Sprint(row)
+	}
+	return strings.Join(list, ", ")
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// printAnomalies lists anomalies or failures with their example values
+func printAnomalies(anomalies []types.Anomaly) {
+	for _, anomaly := range anomalies {
+		examples := make([]string, len(anomaly.Examples))
+		for i, example := range anomaly.Examples {
+			examples[i] = fmt.Sprintf("row %d %q", example.Row, example.Value)
+		}
+		if len(examples) == 0 {
+			fmt.Printf("    ⚠ %s: %d\n", anomaly.Reason, anomaly.Count)
+			continue
+		}
+		fmt.Printf("    ⚠ %s: %d (%s)\n", anomaly.Reason, anomaly.Count, strings.Join(examples, ", "))
+	}
+}
+
+func printProfile(p *types.Profile) {
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("PROFILE OF %s:\n", p.Source)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("Rows: %d\n\n", p.Rows)
+	fmt.Printf("%-30s %-9s %9s %9s  %s\n", "COLUMN", "TYPE", "NULLS", "DISTINCT", "MIN .. MAX")
+
+	for _, col := range p.Columns {
+		distinct := fmt.Sprint(col.Distinct)
+		if col.DistinctCapped {
+			distinct = ">=" + distinct
+		}
+		typeName := col.Type
+		if typeName == "" {
+			typeName = "empty"
+		}
+		fmt.Printf("%-30s %-9s %9d %9s  %s\n", col.Column, typeName, col.Nulls, distinct, valueRange(col))
+
+		if col.Format != "" {
+			fmt.Printf("    format: %s\n", col.Format)
+		}
+		if len(col.TopValues) > 0 {
+			top := make([]string, len(col.TopValues))
+			for i, value := range col.TopValues {
+				top[i] = fmt.Sprintf("%q (%d)", value.Value, value.Count)
+			}
+			fmt.Printf("    top values: %s\n", strings.Join(top, ", "))
+		}
+		if len(col.Lengths.Histogram) > 0 {
+			buckets := make([]string, len(col.Lengths.Histogram))
+			for i, bucket := range col.Lengths.Histogram {
+				buckets[i] = fmt.Sprintf("%s: %d", bucket.Range, bucket.Count)
+			}
+			fmt.Printf("    lengths: %d..%d, mean %.1f (%s)\n", col.Lengths.Min, col.Lengths.Max, col.Lengths.Mean, strings.Join(buckets, ", "))
+		}
+		printAnomalies(col.Anomalies)
+	}
+}
+
+func valueRange(col types.ColumnProfile) string {
+	if col.Min == "" && col.Max == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s .. %s", truncate(col.Min), truncate(col.Max))
+}
+
+// truncate shortens long text values so the table stays readable
+func truncate(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 24 {
+		return value
+	}
+	return string(runes[:23]) + "…"
+}
@@ -0,0 +1,214 @@
+// Package duplicates finds repeated records in a source file before it is
+// migrated, since deciding what to do with them usually takes a business
+// conversation that should happen early.
+package duplicates
+
+import (
+	"fmt"
+	"hash/maphash"
+	"slices"
+	"strings"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// DefaultExamples is how many repeated rows and keys are listed
+const DefaultExamples = 5
+
+// File finds the duplicates of every row of a source file. Local CSV and TSV
+// files are read one record at a time; rows are remembered by hashes of their
+// values, so memory grows with the distinct rows rather than their size.
+func File(path string, key []string, csvOptions utils.CSVOptions, examples int) (*types.DuplicateReport, error) {
+	var f *Finder
+	err := utils.EachRecord(path, csvOptions, func(record []string) error {
+		if f != nil {
+			f.Add(record)
+			return nil
+		}
+		var err error
+		f, err = New(record, key, examples)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, fmt.Errorf("%s is empty", utils.RedactURL(path))
+	}
+
+	report := f.Report()
+	report.Source = utils.RedactURL(path)
+	return report, nil
+}
+
+// Finder finds the duplicates among rows added one at a time
+type Finder struct {
+	header   []string
+	key      []string
+	keyIdx   []int
+	examples int
+	seed     maphash.Seed
+	rows     int
+
+	// exact holds the first row of every distinct row, by hash
+	exact     map[uint64]int
+	exactRows int
+	// exactGroups indexes the examples of exact duplicates by row hash
+	exactGroups map[uint64]int
+	exactFound  []types.DuplicateGroup
+
+	// keys holds the first row of every key
+	keys      map[string]*keyRow
+	nearKeys  int
+	nearRows  int
+	differing []int
+	nearFound []types.DuplicateGroup
+}
+
+// keyRow is the first row of a key, by the hashes of its values
+type keyRow struct {
+	row    int
+	fields []uint64
+	// example is the index of the key among the examples, or -1
+	example int
+	near    bool
+}
+
+// New returns a finder of the duplicates among the rows under header. Near
+// duplicates are only found with key columns.
+func New(header, key []string, examples int) (*Finder, error) {
+	f := &Finder{
+		header:      make([]string, len(header)),
+		key:         key,
+		examples:    examples,
+		seed:        maphash.MakeSeed(),
+		exact:       make(map[uint64]int),
+		exactGroups: make(map[uint64]int),
+		keys:        make(map[string]*keyRow),
+		differing:   make([]int, len(header)),
+	}
+	for i, name := range header {
+		f.header[i] = strings.TrimSpace(name)
+	}
+	for _, name := range key {
+		index := slices.Index(f.header, name)
+		if index < 0 {
+			return nil, fmt.Errorf("key column %q not found", name)
+		}
+		f.keyIdx = append(f.keyIdx, index)
+	}
+	return f, nil
+}
+
+// Add checks a data row against the rows before it
+func (f *Finder) Add(row []string) {
+	f.rows++
+	rowNumber := f.rows + 1
+
+	fields := make([]uint64, len(f.header))
+	var h maphash.Hash
+	h.SetSeed(f.seed)
+	for i := range fields {
+		value := field(row, i)
+		fields[i] = maphash.String(f.seed, value)
+		h.WriteString(value)
+		// Unit separator keeps ("a,b", "c") and ("a", "b,c") apart
+		h.WriteByte(0x1f)
+	}
+	sum := h.Sum64()
+
+	if first, seen := f.exact[sum]; seen {
+		f.exactRows++
+		f.addExact(sum, first, rowNumber)
+		return
+	}
+	f.exact[sum] = rowNumber
+
+	if len(f.keyIdx) == 0 {
+		return
+	}
+	values := make([]string, len(f.keyIdx))
+	for i, index := range f.keyIdx {
+		if values[i] = field(row, index); values[i] == "" {
+			// Rows without a complete key are not records of a known entity
+			return
+		}
+	}
+	key := strings.Join(values, "|")
+	first, seen := f.keys[key]
+	if !seen {
+		f.keys[strings.Clone(key)] = &keyRow{row: rowNumber, fields: fields, example: -1}
+		return
+	}
+
+	var columns []string
+	for i, hash := range fields {
+		if hash != first.fields[i] {
+			f.differing[i]++
+			columns = append(columns, f.header[i])
+		}
+	}
+	f.nearRows++
+	if !first.near {
+		first.near = true
+		f.nearKeys++
+	}
+	switch {
+	case first.example >= 0:
+		group := &f.nearFound[first.example]
+		if len(group.Rows) < f.examples {
+			group.Rows = append(group.Rows, rowNumber)
+		}
+		for _, column := range columns {
+			if !slices.Contains(group.Columns, column) {
+				group.Columns = append(group.Columns, column)
+			}
+		}
+	case len(f.nearFound) < f.examples:
+		first.example = len(f.nearFound)
+		f.nearFound = append(f.nearFound, types.DuplicateGroup{Key: strings.Clone(key), Rows: []int{first.row, rowNumber}, Columns: columns})
+	}
+}
+
+// addExact adds a row to the example of the row it repeats, or a new example
+// while there is room
+func (f *Finder) addExact(sum uint64, first, rowNumber int) {
+	if i, exists := f.exactGroups[sum]; exists {
+		if rows := &f.exactFound[i].Rows; len(*rows) < f.examples {
+			*rows = append(*rows, rowNumber)
+		}
+		return
+	}
+	if len(f.exactFound) < f.examples {
+		f.exactGroups[sum] = len(f.exactFound)
+		f.exactFound = append(f.exactFound, types.DuplicateGroup{Rows: []int{first, rowNumber}})
+	}
+}
+
+// Report returns the duplicates among the rows added
+func (f *Finder) Report() *types.DuplicateReport {
+	report := &types.DuplicateReport{
+		Rows:      f.rows,
+		Key:       f.key,
+		ExactRows: f.exactRows,
+		Exact:     f.exactFound,
+		NearKeys:  f.nearKeys,
+		NearRows:  f.nearRows,
+		Near:      f.nearFound,
+	}
+	for i, count := range f.differing {
+		if count > 0 {
+			report.DifferingColumns = append(report.DifferingColumns, types.ColumnCount{Column: f.header[i], Count: count})
+		}
+	}
+	slices.SortStableFunc(report.DifferingColumns, func(a, b types.ColumnCount) int { return b.Count - a.Count })
+	return report
+}
+
+func field(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+// Package evaluate scores AI-generated schemas against schemas reviewed by
+// hand, so that models can be compared on the same samples.
+package evaluate
+
+import (
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// DefaultExamples is how many mistakes are kept of each case
+const DefaultExamples = 10
+
+// Schemas scores generated target and source schemas against the reviewed
+// ones: the classification of every reviewed target column, the source
+// column mapped to it, and every entry of its values_mapping. Reviewed parts
+// missing from the generated schemas count as wrong.
+func Schemas(target, source, expectedTarget, expectedSource []types.ColumnSchema, examples int) types.SchemaScore {
+	var score types.SchemaScore
+	mistake := func(m types.SchemaMistake) {
+		if len(score.Mistakes) < examples {
+			score.Mistakes = append(score.Mistakes, m)
+		}
+	}
+
+	generatedTarget := make(map[string]types.ColumnSchema, len(target))
+	for _, col := range target {
+		generatedTarget[col.Column] = col
+	}
+	for _, expected := range expectedTarget {
+		actual := "missing"
+		if col, ok := generatedTarget[expected.Column]; ok {
+			actual = category(col)
+		}
+		if count(&score.Categories, actual == category(expected)) {
+			continue
+		}
+		mistake(types.SchemaMistake{Kind: "category", TargetColumn: expected.Column, Expected: category(expected), Actual: actual})
+	}
+
+	generatedSource := make(map[string]types.ColumnSchema, len(source))
+	for _, col := range source {
+		if _, seen := generatedSource[col.TargetColumn]; !seen {
+			generatedSource[col.TargetColumn] = col
+		}
+	}
+	for _, expected := range expectedSource {
+		col, ok := generatedSource[expected.TargetColumn]
+		actual := "missing"
+		if ok {
+			actual = columnName(col)
+		}
+		sameColumn := ok && col.Column == expected.Column
+		if !count(&score.Columns, sameColumn) {
+			mistake(types.SchemaMistake{Kind: "column", TargetColumn: expected.TargetColumn, Expected: columnName(expected), Actual: actual})
+		}
+
+		for value, target := range expected.ValuesMapping.All() {
+			mapped, found := "", false
+			if sameColumn {
+				mapped, found = col.ValuesMapping.Lookup(value)
+			}
+			if count(&score.Values, found && mapped == target) {
+				continue
+			}
+			if !found {
+				mapped = "unmapped"
+			}
+			mistake(types.SchemaMistake{Kind: "value", TargetColumn: expected.TargetColumn, Value: value, Expected: target, Actual: mapped})
+		}
+	}
+
+	finish(&score)
+	return score
+}
+
+// Sum adds up the counts of scores
+func Sum(scores []types.SchemaScore) types.SchemaScore {
+	var total types.SchemaScore
+	for _, score := range scores {
+		total.Categories.Correct += score.Categories.Correct
+		total.Categories.Total += score.Categories.Total
+		total.Columns.Correct += score.Columns.Correct
+		total.Columns.Total += score.Columns.Total
+		total.Values.Correct += score.Values.Correct
+		total.Values.Total += score.Values.Total
+	}
+	finish(&total)
+	return total
+}
+
+// count adds an answer to score and returns whether it was correct
+func count(score *types.Score, correct bool) bool {
+	score.Total++
+	if correct {
+		score.Correct++
+	}
+	return correct
+}
+
+func finish(score *types.SchemaScore) {
+	for _, s := range []*types.Score{&score.Categories, &score.Columns, &score.Values} {
+		s.Accuracy = 0
+		if s.Total > 0 {
+			s.Accuracy = float64(s.Correct) / float64(s.Total)
+		}
+	}
+}
+
+func category(col types.ColumnSchema) string {
+	if len(col.Values) > 0 {
+		return "categorical"
+	}
+	return "dynamic"
+}
+
+// columnName names the source column of a source schema entry, which is
+// null when no source column maps to the target column
+func columnName(col types.ColumnSchema) string {
+	if col.Column == "" {
+		return "null"
+	}
+	return col.Column
+}
@@ -0,0 +1,203 @@
+// Package fixture writes large synthetic source files and their schemas, so
+// that the performance of the conversion can be measured on realistic data.
+package fixture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// Spec describes a synthetic source file. Its columns cycle through the kinds
+// of a customer export: unique IDs, status codes mapped through
+// values_mapping, country codes, dates and amounts with a decimal comma.
+type Spec struct {
+	Rows    int
+	Columns int
+	// Mapped is how many leading columns feed a target column
+	Mapped int
+}
+
+// Narrow and Wide are the benchmark files: a lean export whose columns are
+// all converted, and a wide one of which only a quarter are. Huge is Narrow
+// at ten times the rows, for checking that streaming keeps memory bounded.
+var (
+	Narrow = Spec{Rows: 1_000_000, Columns: 5, Mapped: 5}
+	Wide   = Spec{Rows: 1_000_000, Columns: 60, Mapped: 15}
+	Huge   = Spec{Rows: 10_000_000, Columns: 5, Mapped: 5}
+)
+
+// Files are the paths of a written fixture
+type Files struct {
+	Data         string
+	SourceSchema string
+	TargetSchema string
+}
+
+var (
+	statuses  = []string{"A", "I", "P", "X"}
+	countries = []string{"US", "DE", "FR", "GB", "JP", "BR", "IN", "MX"}
+)
+
+// Write writes the source file and schemas of spec into dir, unless an
+// earlier call already did. The same spec always gives the same files.
+func Write(dir string, spec Spec) (Files, error) {
+	base := filepath.Join(dir, fmt.Sprintf("fixture_%dx%d_%d", spec.Rows, spec.Columns, spec.Mapped))
+	files := Files{
+		Data:         base + ".csv",
+		SourceSchema: base + "_source.json",
+		TargetSchema: base + "_target.json",
+	}
+	if _, err := os.Stat(files.Data); err == nil {
+		return files, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Files{}, err
+	}
+	sourceSchema, targetSchema := Schemas(spec)
+	if err := saveJSON(files.SourceSchema, sourceSchema); err != nil {
+		return Files{}, err
+	}
+	if err := saveJSON(files.TargetSchema, targetSchema); err != nil {
+		return Files{}, err
+	}
+
+	// The data file is written last and renamed into place, so that an
+	// interrupted run is not taken for a complete fixture
+	if err := writeData(files.Data+".tmp", spec); err != nil {
+		os.Remove(files.Data + ".tmp")
+		return Files{}, err
+	}
+	return files, os.Rename(files.Data+".tmp", files.Data)
+}
+
+// Schemas returns the source and target schemas of spec
+func Schemas(spec Spec) (sourceSchema, targetSchema []types.ColumnSchema) {
+	for i := 0; i < spec.Columns; i++ {
+		source := types.ColumnSchema{Column: columnName(i), Values: []string{}}
+		if i >= spec.Mapped {
+			sourceSchema = append(sourceSchema, source)
+			continue
+		}
+
+		target := types.ColumnSchema{Column: "target_" + columnName(i), Values: []string{}}
+		source.TargetColumn = target.Column
+		switch i % 5 {
+		case 1:
+			source.Values = statuses
+			source.ValuesMapping = types.NewValueMap(map[string]string{"A": "active", "I": "inactive", "P": "pending", "X": "closed"})
+			target.Values = []string{"active", "inactive", "pending", "closed"}
+		case 3:
+			source.Format = "DD/MM/YYYY"
+			target.Type = "date"
+		case 4:
+			source.DecimalSeparator = ","
+			target.Type = "number"
+		}
+		sourceSchema = append(sourceSchema, source)
+		targetSchema = append(targetSchema, target)
+	}
+	return sourceSchema, targetSchema
+}
+
+func columnName(i int) string {
+	name := []string{"id", "status", "country", "created", "amount"}[i%5]
+	if i >= 5 {
+		name += "_" + strconv.Itoa(i/5)
+	}
+	return name
+}
+
+// Records returns the header and rows of spec in memory, the same as the
+// data file Write writes
+func Records(spec Spec) [][]string {
+	header := make([]string, spec.Columns)
+	for i := range header {
+		header[i] = columnName(i)
+	}
+	records := [][]string{header}
+
+	rng := rand.New(rand.NewSource(1))
+	for row := 0; row < spec.Rows; row++ {
+		record := make([]string, spec.Columns)
+		for i := range record {
+			record[i] = string(appendField(nil, rng, row, i))
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// appendField appends the value of column i of row to field. Values are drawn
+// from rng, so the same sequence of calls gives the same values.
+func appendField(field []byte, rng *rand.Rand, row, i int) []byte {
+	switch i % 5 {
+	case 0:
+		field = fmt.Appendf(field, "C%08d", row)
+	case 1:
+		field = append(field, statuses[rng.Intn(len(statuses))]...)
+	case 2:
+		field = append(field, countries[rng.Intn(len(countries))]...)
+	case 3:
+		field = fmt.Appendf(field, "%02d/%02d/%d", rng.Intn(28)+1, rng.Intn(12)+1, 2015+rng.Intn(10))
+	case 4:
+		field = fmt.Appendf(field, "%d,%02d", rng.Intn(100000), rng.Intn(100))
+	}
+	return field
+}
+
+func writeData(path string, spec Spec) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriterSize(file, 1<<20)
+	for i := 0; i < spec.Columns; i++ {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(columnName(i))
+	}
+	w.WriteByte('\n')
+
+	rng := rand.New(rand.NewSource(1))
+	var field []byte
+	for row := 0; row < spec.Rows; row++ {
+		for i := 0; i < spec.Columns; i++ {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			field = appendField(field[:0], rng, row, i)
+			// Amounts with a decimal comma are quoted
+			if i%5 == 4 {
+				w.WriteByte('"')
+				w.Write(field)
+				w.WriteByte('"')
+			} else {
+				w.Write(field)
+			}
+		}
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func saveJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -3,51 +3,169 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	ai "github.com/ashr-tech/csv-migration-tools/ai"
+	config "github.com/ashr-tech/csv-migration-tools/config"
+	evaluate "github.com/ashr-tech/csv-migration-tools/evaluate"
+	manifest "github.com/ashr-tech/csv-migration-tools/manifest"
 	types "github.com/ashr-tech/csv-migration-tools/types"
 	utils "github.com/ashr-tech/csv-migration-tools/utils"
 )
 
 func main() {
-	// Usage: go run generator\generate_schemas.go
+	// Usage: go run generator\generate_schemas.go [options]
+	// Run with --help to list the options
 
 	// NOTE! Set your Ollama cloud api key first if want to use CLOUD mode
 	// $env:OLLAMA_API_KEY="your-api-key-here" (Windows)
 	// export OLLAMA_API_KEY="your-api-key-here" (macOS)
 	// Get api key: https://ollama.com/settings/keys
 
+	inDelimiter := flag.String("in-delimiter", "", "Field delimiter of the sample CSVs, e.g. ';', tab or '~|~' (default: detected from the file)")
+	format := flag.String("format", "csv", "File format of the sample files: csv or tsv")
+	encoding := flag.String("encoding", "", "Character encoding of the sample files, e.g. windows-1252 (default: detected)")
+	sheet := flag.String("sheet", "", "Worksheet of .xlsx samples, by name or 1-based index (default: the first)")
+	headerRows := flag.Int("header-rows", 1, "Number of leading .xlsx rows combined into the header, for grouped headers")
+	quirks := flag.String("quirks", "", "Strip the preamble, footer and repeated headers of report exports: salesforce, or a JSON profile file")
+	jsonSeparator := flag.String("json-separator", utils.DefaultJSONSeparator, "Separator joining nested keys of .json and .jsonl samples into column names")
+	sourceQuery := flag.String("source-query", "", "SQL query whose result is the source sample when its path is a postgres:// or mysql:// URL")
+	var httpHeaders listFlag
+	flag.Var(&httpHeaders, "http-header", "Header sent when reading http:// and https:// samples, e.g. 'Authorization: Bearer $EXPORT_TOKEN' (repeatable)")
+	targetQuery := flag.String("target-query", "", "SQL query whose result is the target sample when its path is a postgres:// or mysql:// URL")
+	sourceSampleFlag := flag.String("source-sample", "", "Source sample path, instead of prompting for it; - reads the data piped into stdin")
+	targetSampleFlag := flag.String("target-sample", "", "Target sample path, instead of prompting for it (- reads stdin)")
+	aiModeFlag := flag.String("ai-mode", "", "AI mode (CLOUD/LOCAL), instead of prompting for it")
+	aiConcurrency := flag.Int("ai-concurrency", ai.DefaultLimits.Concurrency, "Most AI requests in flight at once (0 = no limit)")
+	aiRPM := flag.Int("ai-rpm", ai.DefaultLimits.RequestsPerMinute, "Most AI requests started per minute, to stay within the service's rate limit (0 = no limit)")
+	auditDir := flag.String("audit-dir", "", "Save every prompt sent to the AI and its response, redacted, in a timestamped run directory under this directory with a manifest.json listing them")
+	runManifest := flag.String("run-manifest", "", "Write the SHA-256 of the samples and generated schemas to this JSON file, for csvmigrate verify")
+	auditRaw := flag.Bool("audit-raw", false, "Keep the --audit-dir files unredacted")
+	nameFlag := flag.String("name", "", "Name for the schemas, instead of prompting for it")
+	sampleRows := flag.Int("sample-rows", 500, "Rows of each sample sent to the AI, picked at random from larger samples (0 = all)")
+	distinctValues := flag.Int("distinct-values", 100, "Most distinct values per column listed to the AI when a sample is cut down to --sample-rows")
+	evaluatePath := flag.String("evaluate", "", "JSON file of samples with reviewed schemas; generates schemas for each with every --models model and reports their accuracy")
+	models := flag.String("models", "CLOUD,LOCAL", "Comma-separated models compared by --evaluate, each a mode optionally followed by a model, e.g. 'CLOUD:gpt-oss:120b' (default: the configured model of the mode)")
+	reportPath := flag.String("report", "", "With --evaluate, also save the report as JSON to this file")
+	flag.Parse()
+
+	// Data piped into stdin leaves none for the prompts
+	if utils.IsStdin(*sourceSampleFlag) || utils.IsStdin(*targetSampleFlag) {
+		if *sourceSampleFlag == *targetSampleFlag {
+			log.Fatalf("Only one sample can read stdin (-)")
+		}
+		if *sourceSampleFlag == "" || *targetSampleFlag == "" || *aiModeFlag == "" || *nameFlag == "" {
+			log.Fatalf("Reading stdin (-) requires --source-sample, --target-sample, --ai-mode and --name instead of the prompts")
+		}
+	}
+
+	for _, header := range httpHeaders {
+		if err := utils.AddHTTPHeader(header); err != nil {
+			log.Fatalf("Invalid --http-header: %v", err)
+		}
+	}
+
+	if *sampleRows < 0 {
+		log.Fatalf("Invalid --sample-rows %d: must be 0 or more", *sampleRows)
+	}
+	if *distinctValues < 0 {
+		log.Fatalf("Invalid --distinct-values %d: must be 0 or more", *distinctValues)
+	}
+	sampling := samplingOptions{rows: *sampleRows, distinct: *distinctValues}
+	if *aiConcurrency < 0 || *aiRPM < 0 {
+		log.Fatalf("Invalid --ai-concurrency or --ai-rpm: must be 0 or more")
+	}
+	ai.SetLimits(ai.Limits{Concurrency: *aiConcurrency, RequestsPerMinute: *aiRPM})
+	var run *manifest.Run
+	if *runManifest != "" {
+		if *evaluatePath != "" {
+			log.Fatalf("--run-manifest cannot be combined with --evaluate")
+		}
+		run = manifest.New("generator")
+	}
+	if *auditDir != "" {
+		runDir, err := ai.StartAudit(*auditDir, *auditRaw)
+		if err != nil {
+			log.Fatalf("Invalid --audit-dir: %v", err)
+		}
+		fmt.Printf("✓ Saving AI prompts and responses to %s\n", runDir)
+		run.AIAudit(filepath.Join(runDir, "manifest.json"))
+	}
+
+	csvOptions := utils.DefaultCSVOptions
+	csvOptions.Sheet = *sheet
+	csvOptions.HeaderRows = *headerRows
+	csvOptions.JSONSeparator = *jsonSeparator
+	if *quirks != "" {
+		profile, err := utils.LoadQuirks(*quirks)
+		if err != nil {
+			log.Fatalf("Invalid --quirks: %v", err)
+		}
+		csvOptions.Quirks = profile
+	}
+	if *format != "csv" && *format != "tsv" {
+		log.Fatalf("Invalid --format %q: must be csv or tsv", *format)
+	}
+	if *format == "tsv" {
+		csvOptions.Delimiter = '\t'
+	}
+	if *encoding != "" {
+		if _, err := utils.LookupEncoding(*encoding); err != nil {
+			log.Fatalf("Invalid --encoding: %v", err)
+		}
+		csvOptions.Encoding = *encoding
+	}
+	if *inDelimiter != "" {
+		delimiter, multiDelimiter, err := utils.ParseInputDelimiter(*inDelimiter)
+		if err != nil {
+			log.Fatalf("Invalid --in-delimiter: %v", err)
+		}
+		csvOptions.Delimiter, csvOptions.MultiDelimiter = delimiter, multiDelimiter
+	}
+
+	// Evaluation mode scores the schemas of several models against reviewed ones
+	if *evaluatePath != "" {
+		if *sourceSampleFlag != "" || *targetSampleFlag != "" || *aiModeFlag != "" || *nameFlag != "" {
+			log.Fatalf("--source-sample, --target-sample, --ai-mode and --name cannot be combined with --evaluate")
+		}
+		aiModels, err := parseModels(*models)
+		if err != nil {
+			log.Fatalf("Invalid --models: %v", err)
+		}
+		if err := runEvaluation(*evaluatePath, aiModels, csvOptions, sampling, *reportPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *reportPath != "" {
+		log.Fatalf("--report requires --evaluate")
+	}
+
 	var targetSampleDataPath, sourceSampleDataPath, aiMode, schemaName string
 
 	// Ask for input interactively
 	reader := bufio.NewReader(os.Stdin)
 
-	fmt.Print("Please enter the source sample CSV path: ")
-	sourceSampleDataPath, _ = reader.ReadString('\n')
-	sourceSampleDataPath = strings.TrimSpace(sourceSampleDataPath)
-
-	fmt.Print("Please enter the target sample CSV path: ")
-	targetSampleDataPath, _ = reader.ReadString('\n')
-	targetSampleDataPath = strings.TrimSpace(targetSampleDataPath)
+	sourceSampleDataPath = promptValue(reader, *sourceSampleFlag, "Please enter the source sample CSV path: ")
+	targetSampleDataPath = promptValue(reader, *targetSampleFlag, "Please enter the target sample CSV path: ")
 
-	fmt.Print("Please enter AI mode (CLOUD/LOCAL) [default: CLOUD]: ")
-	aiMode, _ = reader.ReadString('\n')
-	aiMode = strings.TrimSpace(aiMode)
+	aiMode = promptValue(reader, *aiModeFlag, "Please enter AI mode (CLOUD/LOCAL) [default: CLOUD]: ")
 	if aiMode == "" {
 		aiMode = "CLOUD"
 	}
 
-	fmt.Print("Please enter a name for the schemas: ")
-	schemaName, _ = reader.ReadString('\n')
-	schemaName = strings.TrimSpace(schemaName)
+	schemaName = promptValue(reader, *nameFlag, "Please enter a name for the schemas: ")
 
 	// Generate target schema from target sample data
 	fmt.Println("Generating target_schema.json from sample data...")
-	targetSchema, err := generateTargetSchema(targetSampleDataPath, &aiMode)
+	targetOptions := csvOptions
+	targetOptions.Query = *targetQuery
+	targetSchema, err := generateTargetSchema(targetSampleDataPath, targetOptions, sampling, aiModel{mode: aiMode})
 	if err != nil {
 		log.Fatalf("Error generating target schema: %v", err)
 	}
@@ -61,7 +179,9 @@ func main() {
 
 	// Generate source schema from source sample data and target schema
 	fmt.Println("\nGenerating source_schema.json...")
-	sourceSchema, err := generateSourceSchema(sourceSampleDataPath, targetSchema, &aiMode)
+	sourceOptions := csvOptions
+	sourceOptions.Query = *sourceQuery
+	sourceSchema, err := generateSourceSchema(sourceSampleDataPath, sourceOptions, sampling, targetSchema, aiModel{mode: aiMode})
 	if err != nil {
 		log.Fatalf("Error generating source schema: %v", err)
 	}
@@ -72,10 +192,77 @@ func main() {
 		log.Fatalf("Error saving source schema: %v", err)
 	}
 	fmt.Printf("✓ %s generated successfully", sourceSchemaFile)
+
+	if run != nil {
+		run.Input(sourceSampleDataPath, targetSampleDataPath)
+		run.Output(targetSchemaFile, sourceSchemaFile)
+		if err := run.Save(*runManifest); err != nil {
+			log.Fatalf("\nError writing run manifest: %v", err)
+		}
+		fmt.Printf("\n✓ Wrote run manifest to %s\n", *runManifest)
+	}
+}
+
+// samplingOptions limit the rows of a sample sent to the AI
+type samplingOptions struct {
+	rows     int
+	distinct int
+}
+
+// aiModel is the AI generating the schemas: a mode and a model of it, or
+// the configured model of the mode when name is ""
+type aiModel struct {
+	mode string
+	name string
+}
+
+func (m aiModel) String() string {
+	if m.name == "" {
+		return strings.ToUpper(m.mode)
+	}
+	return strings.ToUpper(m.mode) + ":" + m.name
 }
 
-func generateTargetSchema(csvPath string, mode *string) ([]types.ColumnSchema, error) {
-	csv, err := utils.ReadCSVFile(csvPath)
+// quiet leaves out the prompts and AI responses, which are too many to read
+// when evaluating models
+var quiet bool
+
+// readSample reads the rows of a sample sent to the AI
+func readSample(csvPath string, csvOptions utils.CSVOptions, sampling samplingOptions) (*utils.Sample, error) {
+	sample, err := utils.SampleCSVFile(csvPath, csvOptions, sampling.rows, sampling.distinct)
+	if err != nil {
+		return nil, err
+	}
+	if sample.Sampled() {
+		fmt.Printf("✓ Sampled %d of %d rows of %s\n", len(sample.Records)-1, sample.Rows, utils.RedactURL(csvPath))
+	}
+	return sample, nil
+}
+
+// distinctValuesPrompt lists the distinct values of each column over the
+// whole sample file when rows were left out, so that categorical values
+// missing from the sampled rows still reach the AI
+func distinctValuesPrompt(sample *utils.Sample) string {
+	if !sample.Sampled() {
+		return ""
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "\nThe CSV above is a random sample of %d of %d rows. DISTINCT VALUES of each column in all %d rows:\n",
+		len(sample.Records)-1, sample.Rows, sample.Rows)
+	for i, column := range sample.Records[0] {
+		if values := sample.Distinct[i]; values != nil {
+			valuesJson, _ := json.Marshal(values)
+			fmt.Fprintf(&prompt, "- %s: %s\n", column, valuesJson)
+		} else {
+			fmt.Fprintf(&prompt, "- %s: more than %d distinct values\n", column, sample.MaxDistinct)
+		}
+	}
+	return prompt.String()
+}
+
+func generateTargetSchema(csvPath string, csvOptions utils.CSVOptions, sampling samplingOptions, model aiModel) ([]types.ColumnSchema, error) {
+	sample, err := readSample(csvPath, csvOptions, sampling)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +273,7 @@ You are a strict data schema (JSON) generator for tabular data analysis.
 Analyze ALL columns from the CSV below. The CSV contains complete data - all categorical values that exist are present in the dataset.
 
 CSV DATA:
-%s
+%s%s
 
 Return ONLY valid JSON in this format:
 [
@@ -147,23 +334,27 @@ EXAMPLE:
   {"column": "permissions", "values": ["read", "write", "delete", "read,write", "read,write,delete"]},
   {"column": "created_at", "values": []}
 ]
-`, *csv)
-
-	fmt.Println("\n" + strings.Repeat("-", 80))
-	fmt.Println("GENERATE TARGET SCHEMA PROMPT:")
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Println(prompt)
-	fmt.Println(strings.Repeat("-", 80))
+`, utils.FormatCSV(sample.Records), distinctValuesPrompt(sample))
+
+	if !quiet {
+		fmt.Println("\n" + strings.Repeat("-", 80))
+		fmt.Println("GENERATE TARGET SCHEMA PROMPT:")
+		fmt.Println(strings.Repeat("-", 80))
+		fmt.Println(prompt)
+		fmt.Println(strings.Repeat("-", 80))
+	}
 
-	resp, err := ai.CallAI(prompt, mode)
+	resp, err := ai.CallModel(prompt, &model.mode, model.name)
 	if err != nil {
 		return nil, fmt.Errorf("AI call failed: %v", err)
 	}
 
-	fmt.Println("\nGENERATE TARGET SCHEMA AI RESPONSE:")
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Println(resp)
-	fmt.Println(strings.Repeat("-", 80))
+	if !quiet {
+		fmt.Println("\nGENERATE TARGET SCHEMA AI RESPONSE:")
+		fmt.Println(strings.Repeat("-", 80))
+		fmt.Println(resp)
+		fmt.Println(strings.Repeat("-", 80))
+	}
 
 	// Parse AI response
 	schema, err := utils.ParseAIResponse(resp)
@@ -176,10 +367,12 @@ EXAMPLE:
 
 func generateSourceSchema(
 	csvPath string,
+	csvOptions utils.CSVOptions,
+	sampling samplingOptions,
 	targetSchema []types.ColumnSchema,
-	mode *string,
+	model aiModel,
 ) ([]types.ColumnSchema, error) {
-	rawCSV, err := utils.ReadCSVFile(csvPath)
+	sample, err := readSample(csvPath, csvOptions, sampling)
 	if err != nil {
 		return nil, err
 	}
@@ -192,7 +385,7 @@ You are a strict data mapping schema (JSON) generator for tabular data analysis.
 Analyze ALL columns from the CSV below and map them to the target schema. The CSV contains complete data - all categorical values that exist are present in the dataset.
 
 CSV DATA:
-%s
+%s%s
 
 TARGET SCHEMA JSON:
 %s
@@ -338,23 +531,27 @@ TARGET SCHEMA: id, name, age, is_active, role, permissions, store_id, store_name
     "values_mapping": null
   }
 ]
-`, *rawCSV, targetSchemaJson)
-
-	fmt.Println("\n" + strings.Repeat("-", 80))
-	fmt.Println("GENERATE SOURCE SCHEMA PROMPT:")
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Println(prompt)
-	fmt.Println(strings.Repeat("-", 80))
+`, utils.FormatCSV(sample.Records), distinctValuesPrompt(sample), targetSchemaJson)
+
+	if !quiet {
+		fmt.Println("\n" + strings.Repeat("-", 80))
+		fmt.Println("GENERATE SOURCE SCHEMA PROMPT:")
+		fmt.Println(strings.Repeat("-", 80))
+		fmt.Println(prompt)
+		fmt.Println(strings.Repeat("-", 80))
+	}
 
-	resp, err := ai.CallAI(prompt, mode)
+	resp, err := ai.CallModel(prompt, &model.mode, model.name)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println("\nGENERATE SOURCE SCHEMA AI RESPONSE:")
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Println(resp)
-	fmt.Println(strings.Repeat("-", 80))
+	if !quiet {
+		fmt.Println("\nGENERATE SOURCE SCHEMA AI RESPONSE:")
+		fmt.Println(strings.Repeat("-", 80))
+		fmt.Println(resp)
+		fmt.Println(strings.Repeat("-", 80))
+	}
 
 	// Parse AI response
 	schema, err := utils.ParseAIResponse(resp)
@@ -364,3 +561,150 @@ TARGET SCHEMA: id, name, age, is_active, role, permissions, store_id, store_name
 
 	return schema, nil
 }
+
+// parseModels reads the --models list of MODE or MODE:model entries
+func parseModels(list string) ([]aiModel, error) {
+	var models []aiModel
+	for _, entry := range strings.Split(list, ",") {
+		mode, name, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		mode = strings.ToLower(mode)
+		switch mode {
+		case "cloud", "local":
+		default:
+			return nil, fmt.Errorf("%q must start with CLOUD or LOCAL", entry)
+		}
+		models = append(models, aiModel{mode: mode, name: name})
+	}
+	return models, nil
+}
+
+// runEvaluation generates the schemas of every case of an evaluation file
+// with each model, and reports how much of them agrees with the reviewed
+// schemas of the case. A case whose generation fails scores nothing.
+func runEvaluation(path string, models []aiModel, csvOptions utils.CSVOptions, sampling samplingOptions, reportPath string) error {
+	var cases []types.EvaluationCase
+	if err := utils.LoadJSON(path, &cases); err != nil {
+		return fmt.Errorf("error loading evaluation file: %v", err)
+	}
+	if len(cases) == 0 {
+		return fmt.Errorf("evaluation file %s lists no cases", path)
+	}
+
+	// The reviewed schemas are loaded up front, so that a broken case fails
+	// before any AI call
+	expectedTargets := make([][]types.ColumnSchema, len(cases))
+	expectedSources := make([][]types.ColumnSchema, len(cases))
+	for i, c := range cases {
+		if cases[i].Name == "" {
+			cases[i].Name = c.TargetSchema
+		}
+		var err error
+		if expectedTargets[i], err = utils.LoadSchemaJSON(c.TargetSchema); err != nil {
+			return fmt.Errorf("%s: error loading target schema: %v", cases[i].Name, err)
+		}
+		if expectedSources[i], err = utils.LoadSchemaJSON(c.SourceSchema); err != nil {
+			return fmt.Errorf("%s: error loading source schema: %v", cases[i].Name, err)
+		}
+	}
+
+	quiet = true
+	evaluations := make([]types.ModelEvaluation, 0, len(models))
+	for _, model := range models {
+		evaluation := types.ModelEvaluation{Mode: strings.ToUpper(model.mode), Model: model.name, Cases: []types.SchemaScore{}}
+		if evaluation.Model == "" {
+			evaluation.Model = config.CLOUD_AI_MODEL
+			if model.mode == "local" {
+				evaluation.Model = config.LOCAL_AI_MODEL
+			}
+		}
+
+		for i, c := range cases {
+			fmt.Printf("Generating the schemas of %s with %s:%s...\n", c.Name, evaluation.Mode, evaluation.Model)
+			targetSchema, err := generateTargetSchema(c.TargetSample, csvOptions, sampling, model)
+			var sourceSchema []types.ColumnSchema
+			if err == nil {
+				sourceSchema, err = generateSourceSchema(c.SourceSample, csvOptions, sampling, targetSchema, model)
+			}
+
+			score := evaluate.Schemas(targetSchema, sourceSchema, expectedTargets[i], expectedSources[i], evaluate.DefaultExamples)
+			score.Case = c.Name
+			if err != nil {
+				evaluation.Failed++
+				score.Error = err.Error()
+				score.Mistakes = nil
+			}
+			printScore(score)
+			evaluation.Cases = append(evaluation.Cases, score)
+		}
+		evaluation.Total = evaluate.Sum(evaluation.Cases)
+		evaluations = append(evaluations, evaluation)
+	}
+
+	if reportPath != "" {
+		if err := utils.SaveJSON(reportPath, evaluations); err != nil {
+			return fmt.Errorf("error saving report: %v", err)
+		}
+	}
+	printEvaluation(path, evaluations)
+	return nil
+}
+
+func printScore(score types.SchemaScore) {
+	if score.Error != "" {
+		fmt.Printf("⚠ %s: generation failed: %s\n", score.Case, score.Error)
+		return
+	}
+	fmt.Printf("✓ %s: categories %s, columns %s, values %s\n", score.Case,
+		formatScore(score.Categories), formatScore(score.Columns), formatScore(score.Values))
+	for _, mistake := range score.Mistakes {
+		switch mistake.Kind {
+		case "value":
+			fmt.Printf("    ⚠ value %q of %s: expected %q, got %q\n", mistake.Value, mistake.TargetColumn, mistake.Expected, mistake.Actual)
+		default:
+			fmt.Printf("    ⚠ %s of %s: expected %s, got %s\n", mistake.Kind, mistake.TargetColumn, mistake.Expected, mistake.Actual)
+		}
+	}
+}
+
+func printEvaluation(path string, evaluations []types.ModelEvaluation) {
+	fmt.Println("\n" + strings.Repeat("-", 80))
+	fmt.Printf("SCHEMA GENERATION ACCURACY ON %s:\n", path)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-30s %-14s %-14s %-14s %s\n", "MODEL", "CATEGORIES", "COLUMNS", "VALUES", "FAILED")
+	for _, evaluation := range evaluations {
+		fmt.Printf("%-30s %-14s %-14s %-14s %d/%d\n", evaluation.Mode+":"+evaluation.Model,
+			formatScore(evaluation.Total.Categories), formatScore(evaluation.Total.Columns),
+			formatScore(evaluation.Total.Values), evaluation.Failed, len(evaluation.Cases))
+	}
+}
+
+// formatScore writes a score as its counts and accuracy, e.g. "9/10 (90%)"
+func formatScore(score types.Score) string {
+	if score.Total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d (%.0f%%)", score.Correct, score.Total, score.Accuracy*100)
+}
+
+// listFlag collects the values of a repeatable flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// promptValue returns value when it was given as a flag, and asks for it
+// otherwise
+func promptValue(reader *bufio.Reader, value, question string) string {
+	if value != "" {
+		return value
+	}
+	fmt.Print(question)
+	answer, _ := reader.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
@@ -1,3 +1,5 @@
 module github.com/ashr-tech/csv-migration-tools
 
-go 1.23.2
\ No newline at end of file
+go 1.23.2
+
+require golang.org/x/text v0.21.0
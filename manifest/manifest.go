@@ -0,0 +1,261 @@
+// Package manifest records the files a run read and wrote with their
+// SHA-256 checksums, and checks them again later, so that an output can be
+// shown to be the one the run produced from those inputs.
+package manifest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// KeyEnv names the environment variable holding the key the manifests are
+// signed with. Without it a manifest only carries its own SHA-256, which
+// shows accidental edits but not deliberate ones.
+const KeyEnv = "MANIFEST_SIGNING_KEY"
+
+// Run collects the files of a run. Its methods do nothing on a nil Run, so
+// callers need not check whether a manifest was asked for.
+type Run struct {
+	mu      sync.Mutex
+	tool    string
+	started time.Time
+	inputs  []string
+	schemas []string
+	outputs []string
+	aiAudit string
+}
+
+// New starts the manifest of a run of tool
+func New(tool string) *Run {
+	return &Run{tool: tool, started: time.Now().UTC()}
+}
+
+// Input records data files read by the run
+func (r *Run) Input(paths ...string) {
+	if r != nil {
+		r.add(&r.inputs, paths)
+	}
+}
+
+// Schema records schema files used by the run
+func (r *Run) Schema(paths ...string) {
+	if r != nil {
+		r.add(&r.schemas, paths)
+	}
+}
+
+// Output records files written by the run
+func (r *Run) Output(paths ...string) {
+	if r != nil {
+		r.add(&r.outputs, paths)
+	}
+}
+
+// AIAudit records the manifest of the prompts and responses of the run
+func (r *Run) AIAudit(path string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.aiAudit = path
+	r.mu.Unlock()
+}
+
+func (r *Run) add(list *[]string, paths []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, path := range paths {
+		if path != "" && !slices.Contains(*list, path) {
+			*list = append(*list, path)
+		}
+	}
+}
+
+// Save checksums the recorded files as they are now, at the end of the run,
+// and writes the signed manifest to path
+func (r *Run) Save(path string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	command := make([]string, len(os.Args))
+	for i, arg := range os.Args {
+		command[i] = utils.RedactURL(arg)
+	}
+	m := types.RunManifest{
+		Tool:       r.tool,
+		Command:    command,
+		StartedAt:  r.started.Format(time.RFC3339),
+		FinishedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	var err error
+	if m.Inputs, err = checksums(r.inputs); err != nil {
+		return err
+	}
+	if m.Schemas, err = checksums(r.schemas); err != nil {
+		return err
+	}
+	if m.Outputs, err = checksums(r.outputs); err != nil {
+		return err
+	}
+	if r.aiAudit != "" {
+		audit, err := checksum(r.aiAudit)
+		if err != nil {
+			return err
+		}
+		m.AIAudit = &audit
+	}
+
+	key := os.Getenv(KeyEnv)
+	m.SignatureAlgorithm = algorithm(key)
+	if m.Signature, err = sign(m, key); err != nil {
+		return err
+	}
+	return utils.SaveJSON(path, m)
+}
+
+func checksums(paths []string) ([]types.FileChecksum, error) {
+	files := make([]types.FileChecksum, 0, len(paths))
+	for _, path := range paths {
+		file, err := checksum(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func checksum(path string) (types.FileChecksum, error) {
+	file := types.FileChecksum{Path: utils.RedactURL(path)}
+	if reason := unchecked(path); reason != "" {
+		file.Unchecked = reason
+		return file, nil
+	}
+	hash, err := utils.FileSHA256(path)
+	if err != nil {
+		return file, fmt.Errorf("error checksumming %s: %v", file.Path, err)
+	}
+	file.SHA256 = hash
+	return file, nil
+}
+
+// unchecked says why the data at path cannot be read again to checksum it
+func unchecked(path string) string {
+	switch {
+	case utils.IsStdin(path):
+		return "standard input"
+	case utils.IsDatabaseURL(path):
+		return "database query"
+	}
+	return ""
+}
+
+func algorithm(key string) string {
+	if key != "" {
+		return "hmac-sha256"
+	}
+	return "sha256"
+}
+
+// sign hashes m without its signature
+func sign(m types.RunManifest, key string) (string, error) {
+	m.Signature = ""
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		hash := sha256.Sum256(data)
+		return hex.EncodeToString(hash[:]), nil
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify checks the signature of the manifest at path and compares every
+// file it lists with its current contents. Relative paths are read from the
+// working directory, like the run that wrote them. With outputsOnly the
+// inputs and schemas, which may since have been moved, are not compared.
+func Verify(path string, outputsOnly bool) (*types.ManifestVerification, error) {
+	var m types.RunManifest
+	if err := utils.LoadJSON(path, &m); err != nil {
+		return nil, fmt.Errorf("error loading manifest: %v", err)
+	}
+	if m.Signature == "" {
+		return nil, fmt.Errorf("%s is not a run manifest: it has no signature", path)
+	}
+
+	report := &types.ManifestVerification{Manifest: path, Signature: "invalid"}
+	key := os.Getenv(KeyEnv)
+	if m.SignatureAlgorithm == "hmac-sha256" && key == "" {
+		report.Signature = "unverified"
+	} else if m.SignatureAlgorithm == algorithm(key) {
+		expected, err := sign(m, key)
+		if err != nil {
+			return nil, err
+		}
+		if hmac.Equal([]byte(expected), []byte(m.Signature)) {
+			report.Signature = "valid"
+		}
+	}
+
+	type group struct {
+		role  string
+		files []types.FileChecksum
+	}
+	groups := []group{{"output", m.Outputs}}
+	if !outputsOnly {
+		groups = []group{{"input", m.Inputs}, {"schema", m.Schemas}, {"output", m.Outputs}}
+	}
+	if m.AIAudit != nil {
+		groups = append(groups, group{"ai audit", []types.FileChecksum{*m.AIAudit}})
+	}
+	for _, g := range groups {
+		for _, file := range g.files {
+			result := verifyFile(file)
+			result.Role = g.role
+			if result.Status == "changed" || result.Status == "missing" {
+				report.Failed++
+			}
+			report.Files = append(report.Files, result)
+		}
+	}
+	return report, nil
+}
+
+func verifyFile(file types.FileChecksum) types.FileVerification {
+	result := types.FileVerification{Path: file.Path, Expected: file.SHA256}
+	if file.SHA256 == "" {
+		result.Status = "unchecked"
+		return result
+	}
+	hash, err := utils.FileSHA256(file.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		result.Status = "missing"
+	case err != nil:
+		result.Status = "missing"
+		result.Actual = err.Error()
+	case hash != file.SHA256:
+		result.Status = "changed"
+		result.Actual = hash
+	default:
+		result.Status = "ok"
+	}
+	return result
+}
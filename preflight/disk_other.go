@@ -0,0 +1,10 @@
+//go:build !(linux || darwin)
+
+package preflight
+
+import "errors"
+
+// freeSpace is not known on this system
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
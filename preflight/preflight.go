@@ -0,0 +1,373 @@
+// Package preflight checks before a migration run that its files exist, its
+// schemas are sound and fit the source, the AI it needs answers and the
+// output fits on disk, so that problems show up before hours of converting.
+package preflight
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ashr-tech/csv-migration-tools/ai"
+	"github.com/ashr-tech/csv-migration-tools/coverage"
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+	"github.com/ashr-tech/csv-migration-tools/validate"
+)
+
+// diskMargin is how many times the estimated output size should be free for
+// the disk check to pass without a warning
+const diskMargin = 2
+
+// Config names what a migration run would use
+type Config struct {
+	SourceData   string
+	SourceSchema string
+	TargetSchema string
+	CSV          utils.CSVOptions
+	// AIMode is the AI mode the run asks, "" when it asks no AI
+	AIMode string
+	// OutputDir is where the run writes its outputs
+	OutputDir string
+}
+
+// preflight holds what the checks found for the later ones
+type preflight struct {
+	config       Config
+	report       *types.PreflightReport
+	sourceSchema []types.ColumnSchema
+	targetSchema []types.ColumnSchema
+	header       []string
+}
+
+// Run makes every check of config and reports them together
+func Run(config Config) *types.PreflightReport {
+	p := &preflight{config: config, report: &types.PreflightReport{}}
+	p.add(p.paths())
+	p.add(p.schemas())
+	p.add(p.compatibility())
+	p.add(p.aiHealth())
+	p.add(p.disk())
+	p.report.Passed = p.report.Blockers == 0
+	return p.report
+}
+
+func (p *preflight) add(check types.PreflightCheck) {
+	switch {
+	case len(check.Blockers) > 0:
+		check.Status = "blocker"
+	case len(check.Warnings) > 0:
+		check.Status = "warning"
+	case check.Status == "":
+		check.Status = "ok"
+	}
+	p.report.Blockers += len(check.Blockers)
+	p.report.Warnings += len(check.Warnings)
+	p.report.Checks = append(p.report.Checks, check)
+}
+
+func skipped(name, note string) types.PreflightCheck {
+	return types.PreflightCheck{Name: name, Status: "skipped", Note: note}
+}
+
+// isLocal reports whether path is a file of this machine, which can be
+// checked without reading it
+func isLocal(path string) bool {
+	return path != "" && !utils.IsStdin(path) && !strings.Contains(path, "://")
+}
+
+func (p *preflight) paths() types.PreflightCheck {
+	check := types.PreflightCheck{Name: "paths"}
+	files := []struct{ role, path string }{
+		{"source data", p.config.SourceData},
+		{"source schema", p.config.SourceSchema},
+		{"target schema", p.config.TargetSchema},
+	}
+	checked := 0
+	for _, file := range files {
+		if !isLocal(file.path) {
+			continue
+		}
+		checked++
+		if info, err := os.Stat(file.path); err != nil {
+			check.Blockers = append(check.Blockers, fmt.Sprintf("%s %s: %v", file.role, file.path, unwrapPathError(err)))
+		} else if info.IsDir() {
+			check.Blockers = append(check.Blockers, fmt.Sprintf("%s %s is a directory", file.role, file.path))
+		}
+	}
+
+	if dir := p.config.OutputDir; dir != "" {
+		checked++
+		if err := writable(dir); err != nil {
+			check.Blockers = append(check.Blockers, fmt.Sprintf("output directory %s: %v", dir, err))
+		}
+	}
+	check.Note = fmt.Sprintf("%d local paths checked", checked)
+	return check
+}
+
+// writable makes sure files can be created in dir
+func writable(dir string) error {
+	file, err := os.CreateTemp(dir, ".preflight-*")
+	if err != nil {
+		return unwrapPathError(err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+func unwrapPathError(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+func (p *preflight) schemas() types.PreflightCheck {
+	check := types.PreflightCheck{Name: "schemas"}
+	var err error
+	if p.sourceSchema, err = utils.LoadSchemaJSON(p.config.SourceSchema); err != nil {
+		check.Blockers = append(check.Blockers, fmt.Sprintf("source schema: %v", err))
+	}
+	if p.targetSchema, err = utils.LoadSchemaJSON(p.config.TargetSchema); err != nil {
+		check.Blockers = append(check.Blockers, fmt.Sprintf("target schema: %v", err))
+	}
+	if len(check.Blockers) > 0 {
+		p.sourceSchema, p.targetSchema = nil, nil
+		return check
+	}
+
+	if err := validate.CheckSchema(p.sourceSchema); err != nil {
+		check.Blockers = append(check.Blockers, fmt.Sprintf("source schema: %v", err))
+	}
+	if err := validate.CheckSchema(p.targetSchema); err != nil {
+		check.Blockers = append(check.Blockers, fmt.Sprintf("target schema: %v", err))
+	}
+	var seen []string
+	for _, col := range p.targetSchema {
+		if slices.Contains(seen, col.Column) {
+			check.Blockers = append(check.Blockers, fmt.Sprintf("target schema lists column %q twice", col.Column))
+		}
+		seen = append(seen, col.Column)
+	}
+	for _, col := range p.sourceSchema {
+		if col.Lookup != nil && isLocal(col.Lookup.File) {
+			if _, err := os.Stat(col.Lookup.File); err != nil {
+				check.Blockers = append(check.Blockers, fmt.Sprintf("lookup file of column %q: %v", col.Column, unwrapPathError(err)))
+			}
+		}
+	}
+
+	cov := coverage.Schemas(p.sourceSchema, p.targetSchema)
+	for _, column := range cov.UnmappedTargets {
+		target := findColumn(p.targetSchema, column)
+		if target != nil && target.Required {
+			check.Blockers = append(check.Blockers, fmt.Sprintf("required target column %q has no source column", column))
+		} else {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("target column %q has no source column", column))
+		}
+	}
+	if len(cov.UnknownTargets) > 0 {
+		check.Warnings = append(check.Warnings, fmt.Sprintf("source columns map to target columns missing from the target schema: %s", strings.Join(cov.UnknownTargets, ", ")))
+	}
+	for _, col := range cov.Values {
+		if len(col.UnmappedValues) > 0 {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("%d source values of %q have no mapping", len(col.UnmappedValues), col.Column))
+		}
+		if len(col.InvalidTargets) > 0 {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("%d mapped values of %q are no target value", len(col.InvalidTargets), col.Column))
+		}
+	}
+	check.Note = fmt.Sprintf("%d source and %d target columns", len(p.sourceSchema), len(p.targetSchema))
+	return check
+}
+
+func findColumn(schema []types.ColumnSchema, column string) *types.ColumnSchema {
+	for i := range schema {
+		if schema[i].Column == column {
+			return &schema[i]
+		}
+	}
+	return nil
+}
+
+func (p *preflight) compatibility() types.PreflightCheck {
+	const name = "compatibility"
+	switch {
+	case p.config.SourceData == "":
+		return skipped(name, "no source data given")
+	case utils.IsStdin(p.config.SourceData):
+		return skipped(name, "standard input is read by the run only")
+	case p.sourceSchema == nil:
+		return skipped(name, "the schemas could not be loaded")
+	}
+
+	check := types.PreflightCheck{Name: name}
+	header, err := utils.ReadHeader(p.config.SourceData, p.config.CSV)
+	if err != nil {
+		check.Blockers = append(check.Blockers, fmt.Sprintf("reading the header of %s: %v", utils.RedactURL(p.config.SourceData), err))
+		return check
+	}
+	p.header = header
+
+	report := validate.Header(header, p.sourceSchema)
+	renamed := make(map[string]string, len(report.Renames))
+	for _, rename := range report.Renames {
+		renamed[rename.Column] = rename.Header
+	}
+	for _, column := range report.Missing {
+		message := fmt.Sprintf("source column %q is missing from the header", column)
+		if to, ok := renamed[column]; ok {
+			message += fmt.Sprintf(", likely renamed to %q", to)
+		}
+		if feedsTarget(p.sourceSchema, p.targetSchema, column) {
+			check.Blockers = append(check.Blockers, message)
+		} else {
+			check.Warnings = append(check.Warnings, message)
+		}
+	}
+	if len(report.Extra) > 0 {
+		check.Warnings = append(check.Warnings, fmt.Sprintf("header columns not in the source schema: %s", strings.Join(report.Extra, ", ")))
+	}
+	check.Note = fmt.Sprintf("%d header columns", len(header))
+	return check
+}
+
+// feedsTarget reports whether the source column named column maps to a
+// column of the target schema
+func feedsTarget(sourceSchema, targetSchema []types.ColumnSchema, column string) bool {
+	for _, col := range sourceSchema {
+		if col.Column == column && col.TargetColumn != "" && findColumn(targetSchema, col.TargetColumn) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *preflight) aiHealth() types.PreflightCheck {
+	if p.config.AIMode == "" {
+		return skipped("ai", "the run asks no AI")
+	}
+	check := types.PreflightCheck{Name: "ai", Note: strings.ToUpper(p.config.AIMode)}
+	if err := ai.Health(p.config.AIMode); err != nil {
+		check.Blockers = append(check.Blockers, fmt.Sprintf("%s AI: %v", strings.ToUpper(p.config.AIMode), err))
+	}
+	return check
+}
+
+// disk estimates the size of the output from the size of the source and the
+// share of its columns the target keeps, and compares it with the free space
+// of the output directory
+func (p *preflight) disk() types.PreflightCheck {
+	const name = "disk"
+	switch {
+	case !isLocal(p.config.SourceData):
+		return skipped(name, "only the size of local source files is known")
+	case p.config.OutputDir == "":
+		return skipped(name, "no output directory")
+	}
+	estimate, err := sourceSize(p.config.SourceData)
+	if errors.Is(err, errSizeUnknown) {
+		return skipped(name, "the output size of sources of this format is unknown")
+	}
+	if err != nil {
+		return skipped(name, fmt.Sprintf("the source data is unreadable: %v", unwrapPathError(err)))
+	}
+
+	if len(p.header) > 0 && len(p.targetSchema) > 0 {
+		estimate = estimate * uint64(len(p.targetSchema)) / uint64(len(p.header))
+	}
+	free, err := freeSpace(filepath.Clean(p.config.OutputDir))
+	if err != nil {
+		return skipped(name, fmt.Sprintf("free space unknown: %v", err))
+	}
+
+	check := types.PreflightCheck{Name: name, Note: fmt.Sprintf("output of about %s, %s free", formatBytes(estimate), formatBytes(free))}
+	switch {
+	case free < estimate:
+		check.Blockers = append(check.Blockers, fmt.Sprintf("the output needs about %s but %s has %s free", formatBytes(estimate), p.config.OutputDir, formatBytes(free)))
+	case free < estimate*diskMargin:
+		check.Warnings = append(check.Warnings, fmt.Sprintf("%s has %s free, less than twice the output of about %s", p.config.OutputDir, formatBytes(free), formatBytes(estimate)))
+	}
+	return check
+}
+
+var errSizeUnknown = errors.New("size unknown")
+
+// sourceSize returns the size of the data of a local source once
+// uncompressed, which is what the output size follows: zip archives record it
+// and gzip files are read through to count it. Workbooks and Parquet files
+// encode their values too differently from CSV for their size to tell, and
+// give errSizeUnknown.
+func sourceSize(path string) (uint64, error) {
+	archive, entry, _ := strings.Cut(path, "!")
+	switch ext := strings.ToLower(filepath.Ext(archive)); {
+	case utils.IsXLSX(archive) || utils.IsParquet(archive):
+		return 0, errSizeUnknown
+	case ext == ".zip":
+		return zipEntrySize(archive, entry)
+	case ext == ".gz":
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		reader, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return 0, err
+		}
+		size, err := io.Copy(io.Discard, reader)
+		return uint64(size), err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(info.Size()), nil
+}
+
+// zipEntrySize returns the uncompressed size of the entry of archive the
+// converter reads: the one named, or else its only CSV, TSV or JSON file
+func zipEntrySize(archive, entry string) (uint64, error) {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	var size uint64
+	found := 0
+	for _, file := range reader.File {
+		ext := strings.ToLower(filepath.Ext(file.Name))
+		if entry == file.Name || (entry == "" && !file.FileInfo().IsDir() && (ext == ".csv" || ext == ".tsv" || utils.IsJSON(file.Name))) {
+			size = file.UncompressedSize64
+			found++
+		}
+	}
+	if found != 1 {
+		return 0, errSizeUnknown
+	}
+	return size, nil
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGTP"[exp])
+}
@@ -0,0 +1,610 @@
+// Package profile computes per-column statistics of source files, to check a
+// source before writing its schemas and to describe it without sharing rows.
+package profile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ashr-tech/csv-migration-tools/transform"
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// Options tune what a profile keeps of each column
+type Options struct {
+	// TopValues is how many of the most frequent values are reported
+	TopValues int
+	// MaxDistinct caps the distinct values counted per column
+	MaxDistinct int
+	// Examples is how many values are kept of each kind of anomaly
+	Examples int
+	// DayFirst prefers DD/MM over MM/DD when detecting ambiguous dates
+	DayFirst bool
+}
+
+// DefaultOptions are the options of the profile command
+var DefaultOptions = Options{TopValues: 10, MaxDistinct: 100000, Examples: 5, DayFirst: true}
+
+// typeShare is the share of non-empty values that must parse as a type for
+// the column to be inferred as that type; the others are anomalies
+const typeShare = 0.95
+
+// sampleRows is how many leading rows date formats are detected from, like
+// streamed conversions do
+const sampleRows = 10000
+
+// maxDateCandidates is how many distinct values date detection tries
+const maxDateCandidates = 1000
+
+// distinctWindow is how many rows the new distinct values of a column are
+// counted over, to notice where their number explodes
+const distinctWindow = 1000
+
+// A window explodes when it brings at least explosionMin new distinct values
+// and explosionFactor times as many as the windows before did on average
+const (
+	explosionMin    = 20
+	explosionFactor = 10
+)
+
+// File profiles every row of a source file. Local CSV and TSV files are read
+// one record at a time, so files of any size can be profiled; other sources
+// are read whole.
+func File(path string, csvOptions utils.CSVOptions, options Options) (*types.Profile, error) {
+	source := utils.RedactURL(path)
+	var p *Profiler
+	err := utils.EachRecord(path, csvOptions, func(record []string) error {
+		if p == nil {
+			p = New(source, record, options)
+		} else {
+			p.Add(record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%s is empty", source)
+	}
+	return p.Profile(), nil
+}
+
+// Profiler collects the statistics of rows added one at a time
+type Profiler struct {
+	source  string
+	options Options
+	columns []*column
+	rows    int
+	// held are the leading rows, kept until the date formats are detected
+	held     [][]string
+	detected bool
+}
+
+// New returns a profiler of the rows under header, read from source
+func New(source string, header []string, options Options) *Profiler {
+	p := &Profiler{source: source, options: options}
+	for _, name := range header {
+		p.columns = append(p.columns, &column{
+			name:     strings.TrimSpace(name),
+			options:  &p.options,
+			distinct: make(map[string]int),
+		})
+	}
+	return p
+}
+
+// Add adds a data row. Fields beyond the header are ignored.
+func (p *Profiler) Add(row []string) {
+	p.rows++
+	if p.detected {
+		p.add(row, p.rows+1)
+		return
+	}
+
+	p.held = append(p.held, row)
+	if len(p.held) == sampleRows {
+		p.detect()
+	}
+}
+
+// detect detects the date formats from the held rows, then adds them
+func (p *Profiler) detect() {
+	for i, col := range p.columns {
+		col.detectDate(p.held, i, p.options.DayFirst)
+	}
+	for j, row := range p.held {
+		p.add(row, j+2)
+	}
+	p.held, p.detected = nil, true
+}
+
+func (p *Profiler) add(row []string, rowNumber int) {
+	for i, col := range p.columns {
+		value := ""
+		if i < len(row) {
+			value = row[i]
+		}
+		col.add(value, rowNumber)
+	}
+	// Data rows start at row 2, after the header
+	if (rowNumber-1)%distinctWindow == 0 {
+		for _, col := range p.columns {
+			col.closeWindow(rowNumber - distinctWindow + 1)
+		}
+	}
+}
+
+// Profile returns the statistics of the rows added
+func (p *Profiler) Profile() *types.Profile {
+	if !p.detected {
+		p.detect()
+	}
+
+	if p.rows%distinctWindow != 0 {
+		for _, col := range p.columns {
+			col.closeWindow(p.rows - p.rows%distinctWindow + 2)
+		}
+	}
+
+	profile := &types.Profile{Source: p.source, Rows: p.rows, Columns: []types.ColumnProfile{}}
+	for _, col := range p.columns {
+		profile.Columns = append(profile.Columns, col.profile())
+	}
+	return profile
+}
+
+// The types a column is checked for, from the most to the least specific
+const (
+	kindBoolean = iota
+	kindInteger
+	kindNumber
+	kindDate
+	kinds
+)
+
+// The kinds of anomalies, in the order they are reported after type mismatches
+const (
+	anomalyWhitespace = iota
+	anomalyInvisible
+	anomalyEncoding
+	anomalyNullMarker
+	// Sentinel dates are reported in date columns and negative numbers in
+	// numeric columns counting things only
+	anomalySentinelDate
+	anomalyNegative
+	anomalies
+)
+
+var anomalyReasons = [anomalies]string{
+	"leading or trailing whitespace",
+	"invisible or control characters",
+	"invalid encoding (U+FFFD replacement characters)",
+	"null marker instead of an empty value",
+	"placeholder date (year 1900 or before, or 2099 or after)",
+	"negative quantity",
+}
+
+// nullMarkers are values that exports write for missing values
+var nullMarkers = map[string]bool{"null": true, "nil": true, "none": true, "n/a": true, "na": true, "#n/a": true, "-": true}
+
+// lengthBuckets are the upper bounds of the length histogram buckets
+var lengthBuckets = []int{1, 4, 8, 16, 32, 64, 128, 256, math.MaxInt}
+
+type column struct {
+	name    string
+	options *Options
+	// layout is the date layout detected from the held rows, if any
+	layout string
+
+	nulls, values int
+	// markers are null markers, which are left out of type inference
+	markers int
+	// matches counts the values of each kind, and misses are the first
+	// values that are not of that kind
+	matches [kinds]int
+	misses  [kinds][]types.AnomalyExample
+
+	distinct map[string]int
+	capped   bool
+	// windowNew counts the new distinct values of the current window, with
+	// the first of them; windowsNew those of the windows before
+	windowNew      int
+	windowExamples []types.AnomalyExample
+	windows        int
+	windowsNew     int
+	explosion      *types.Anomaly
+
+	integers, numbers valueRange
+	minDate, maxDate  time.Time
+	minDateValue      string
+	maxDateValue      string
+	minText, maxText  string
+
+	lengths              []int
+	minLength, maxLength int
+	totalLength          int
+	anomalies            [anomalies]types.Anomaly
+}
+
+// detectDate detects the date layout of column i from the held rows. Numbers
+// are left out, as DetectDateLayout would take them for Excel serials. The
+// layout must read most values, and typeShare of all values to make the
+// column a date column.
+func (c *column) detectDate(rows [][]string, i int, dayFirst bool) {
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, row := range rows {
+		if i >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[i])
+		if value == "" || seen[value] || nullMarkers[strings.ToLower(value)] {
+			continue
+		}
+		seen[value] = true
+		if _, isNumber := parseNumber(value); isNumber {
+			continue
+		}
+		if candidates = append(candidates, value); len(candidates) == maxDateCandidates {
+			break
+		}
+	}
+
+	layout, found := transform.DetectDateLayout(candidates, dayFirst)
+	if !found || layout == transform.ExcelSerialFormat {
+		return
+	}
+	parsed := 0
+	for _, value := range candidates {
+		if _, err := time.Parse(layout, value); err == nil {
+			parsed++
+		}
+	}
+	if 2*parsed > len(candidates) {
+		c.layout = layout
+	}
+}
+
+func (c *column) add(raw string, rowNumber int) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		c.nulls++
+		return
+	}
+	c.values++
+
+	if value != raw {
+		c.anomaly(anomalyWhitespace, rowNumber, raw)
+	}
+	if strings.ContainsRune(value, utf8.RuneError) {
+		c.anomaly(anomalyEncoding, rowNumber, raw)
+	} else if strings.IndexFunc(value, isInvisible) >= 0 {
+		c.anomaly(anomalyInvisible, rowNumber, raw)
+	}
+
+	c.countDistinct(value, rowNumber)
+	c.measure(value)
+
+	if nullMarkers[strings.ToLower(value)] {
+		c.markers++
+		c.anomaly(anomalyNullMarker, rowNumber, raw)
+		return
+	}
+	c.inferTypes(value, rowNumber)
+}
+
+func (c *column) countDistinct(value string, rowNumber int) {
+	if _, counted := c.distinct[value]; counted {
+		c.distinct[value]++
+		return
+	}
+	if len(c.distinct) >= c.options.MaxDistinct {
+		c.capped = true
+		return
+	}
+	// Values are cloned, as they share their memory with the whole record
+	value = strings.Clone(value)
+	c.distinct[value] = 1
+	c.windowNew++
+	if len(c.windowExamples) < c.options.Examples {
+		c.windowExamples = append(c.windowExamples, types.AnomalyExample{Row: rowNumber, Value: value})
+	}
+}
+
+// closeWindow ends the window of rows from start, comparing its new distinct
+// values with those of the windows before. A column whose values suddenly
+// vary, like a status column after a shift of the fields, is reported at its
+// first explosion.
+func (c *column) closeWindow(start int) {
+	if c.windows > 0 && c.explosion == nil && !c.capped {
+		before := max(float64(c.windowsNew)/float64(c.windows), 1)
+		if c.windowNew >= explosionMin && float64(c.windowNew) >= explosionFactor*before {
+			c.explosion = &types.Anomaly{
+				Reason:   fmt.Sprintf("new distinct values explode from row %d (%.0f per %d rows before)", start, before, distinctWindow),
+				Count:    c.windowNew,
+				Examples: c.windowExamples,
+			}
+		}
+	}
+	c.windowsNew += c.windowNew
+	c.windows++
+	c.windowNew, c.windowExamples = 0, nil
+}
+
+// measure updates the length histogram and the text range
+func (c *column) measure(value string) {
+	length := utf8.RuneCountInString(value)
+	if c.lengths == nil {
+		c.lengths = make([]int, len(lengthBuckets))
+		c.minLength, c.maxLength = length, length
+		c.minText, c.maxText = strings.Clone(value), strings.Clone(value)
+	}
+	for b, bound := range lengthBuckets {
+		if length <= bound {
+			c.lengths[b]++
+			break
+		}
+	}
+	c.minLength = min(c.minLength, length)
+	c.maxLength = max(c.maxLength, length)
+	c.totalLength += length
+
+	if value < c.minText {
+		c.minText = strings.Clone(value)
+	}
+	if value > c.maxText {
+		c.maxText = strings.Clone(value)
+	}
+}
+
+// inferTypes checks value against every type, keeping the ranges of numbers
+// and dates
+func (c *column) inferTypes(value string, rowNumber int) {
+	c.match(kindBoolean, isBoolean(value), rowNumber, value)
+	c.match(kindInteger, isInteger(value), rowNumber, value)
+
+	number, isNumber := parseNumber(value)
+	c.match(kindNumber, isNumber, rowNumber, value)
+	if isNumber {
+		c.numbers.add(number, value)
+		if isInteger(value) {
+			c.integers.add(number, value)
+		}
+	}
+
+	var date time.Time
+	isDate := false
+	if c.layout != "" {
+		var err error
+		date, err = time.Parse(c.layout, value)
+		isDate = err == nil
+	}
+	c.match(kindDate, isDate, rowNumber, value)
+	if isNumber && number < 0 {
+		c.anomaly(anomalyNegative, rowNumber, value)
+	}
+	if isDate {
+		if transform.SentinelDate(date) {
+			c.anomaly(anomalySentinelDate, rowNumber, value)
+		}
+		if c.matches[kindDate] == 1 || date.Before(c.minDate) {
+			c.minDate, c.minDateValue = date, strings.Clone(value)
+		}
+		if c.matches[kindDate] == 1 || date.After(c.maxDate) {
+			c.maxDate, c.maxDateValue = date, strings.Clone(value)
+		}
+	}
+}
+
+// valueRange keeps the smallest and largest number seen, as written
+type valueRange struct {
+	seen               bool
+	min, max           float64
+	minValue, maxValue string
+}
+
+func (r *valueRange) add(number float64, value string) {
+	if !r.seen || number < r.min {
+		r.min, r.minValue = number, strings.Clone(value)
+	}
+	if !r.seen || number > r.max {
+		r.max, r.maxValue = number, strings.Clone(value)
+	}
+	r.seen = true
+}
+
+func (c *column) match(kind int, matched bool, rowNumber int, value string) {
+	if matched {
+		c.matches[kind]++
+	} else if len(c.misses[kind]) < c.options.Examples {
+		c.misses[kind] = append(c.misses[kind], types.AnomalyExample{Row: rowNumber, Value: strings.Clone(value)})
+	}
+}
+
+func (c *column) anomaly(kind int, rowNumber int, value string) {
+	anomaly := &c.anomalies[kind]
+	anomaly.Count++
+	if len(anomaly.Examples) < c.options.Examples {
+		anomaly.Examples = append(anomaly.Examples, types.AnomalyExample{Row: rowNumber, Value: strings.Clone(value)})
+	}
+}
+
+func (c *column) profile() types.ColumnProfile {
+	profile := types.ColumnProfile{
+		Column:         c.name,
+		Nulls:          c.nulls,
+		Distinct:       len(c.distinct),
+		DistinctCapped: c.capped,
+		TopValues:      c.topValues(),
+	}
+	if c.values == 0 {
+		return profile
+	}
+
+	profile.Lengths = types.LengthStats{
+		Min:  c.minLength,
+		Max:  c.maxLength,
+		Mean: math.Round(float64(c.totalLength)/float64(c.values)*10) / 10,
+	}
+	lower := 1
+	for b, bound := range lengthBuckets {
+		if c.lengths[b] > 0 {
+			profile.Lengths.Histogram = append(profile.Lengths.Histogram, types.LengthBucket{Range: bucketRange(lower, bound), Count: c.lengths[b]})
+		}
+		lower = bound + 1
+	}
+
+	// The most specific type most values have
+	profile.Type, profile.Min, profile.Max = "text", c.minText, c.maxText
+	typed := c.values - c.markers
+	kind := -1
+	for k := 0; k < kinds && typed > 0; k++ {
+		if float64(c.matches[k]) >= typeShare*float64(typed) {
+			kind = k
+			break
+		}
+	}
+	switch kind {
+	case kindBoolean:
+		profile.Type = "boolean"
+	case kindInteger:
+		profile.Type, profile.Min, profile.Max = "integer", c.integers.minValue, c.integers.maxValue
+	case kindNumber:
+		profile.Type, profile.Min, profile.Max = "number", c.numbers.minValue, c.numbers.maxValue
+	case kindDate:
+		profile.Type, profile.Format, profile.Min, profile.Max = "date", c.layout, c.minDateValue, c.maxDateValue
+		if strings.Contains(c.layout, ":04") {
+			profile.Type = "datetime"
+		}
+	}
+	if kind >= 0 && c.matches[kind] < typed {
+		profile.Anomalies = append(profile.Anomalies, types.Anomaly{
+			Reason:   "not " + article(profile.Type) + " " + profile.Type,
+			Count:    typed - c.matches[kind],
+			Examples: c.misses[kind],
+		})
+	}
+
+	for k, anomaly := range c.anomalies {
+		switch {
+		case anomaly.Count == 0:
+			continue
+		case k == anomalySentinelDate && kind != kindDate:
+			continue
+		case k == anomalyNegative && (kind != kindInteger && kind != kindNumber || !transform.QuantityColumn(c.name)):
+			continue
+		}
+		anomaly.Reason = anomalyReasons[k]
+		profile.Anomalies = append(profile.Anomalies, anomaly)
+	}
+	if c.explosion != nil {
+		profile.Anomalies = append(profile.Anomalies, *c.explosion)
+	}
+	return profile
+}
+
+// topValues returns the most frequent values, ties in value order
+func (c *column) topValues() []types.ValueCount {
+	top := make([]types.ValueCount, 0, len(c.distinct))
+	for value, count := range c.distinct {
+		top = append(top, types.ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Value < top[j].Value
+	})
+	return top[:min(len(top), c.options.TopValues)]
+}
+
+func bucketRange(lower, upper int) string {
+	switch {
+	case upper == math.MaxInt:
+		return ">" + strconv.Itoa(lower-1)
+	case lower == upper:
+		return strconv.Itoa(lower)
+	default:
+		return fmt.Sprintf("%d-%d", lower, upper)
+	}
+}
+
+func article(typeName string) string {
+	if strings.ContainsRune("aeiou", rune(typeName[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+func isBoolean(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "y", "n", "t", "f":
+		return true
+	}
+	return false
+}
+
+// isInteger accepts whole numbers without leading zeros, which are rather
+// codes whose zeros an integer column would lose
+func isInteger(value string) bool {
+	digits := strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+	if digits == "" || (len(digits) > 1 && digits[0] == '0') {
+		return false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNumber reads plain numbers, and numbers with a decimal comma,
+// thousands separators or a currency symbol that CleanNumber understands.
+// Values with letters or leading zeros, like codes and IDs, are not numbers.
+func parseNumber(value string) (float64, bool) {
+	digits := strings.TrimLeft(value, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		return 0, false
+	}
+	hasDigit := false
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r == '.', r == ',', r == '-', r == '+', r == ' ', r == '\'', r == '(', r == ')', unicode.Is(unicode.Sc, r):
+		default:
+			return 0, false
+		}
+	}
+	if !hasDigit {
+		return 0, false
+	}
+
+	cleaned, err := transform.CleanNumber(value, "")
+	if err != nil {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(cleaned, 64)
+	return number, err == nil
+}
+
+// isInvisible reports control characters other than tabs and newlines,
+// zero-width characters and soft hyphens, which break exact matching
+func isInvisible(r rune) bool {
+	switch r {
+	case '\t', '\n':
+		return false
+	case '\u200B', '\u200C', '\u200D', '\u2060', '\uFEFF', '\u00AD':
+		return true
+	}
+	return unicode.IsControl(r)
+}
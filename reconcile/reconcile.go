@@ -0,0 +1,121 @@
+// Package reconcile accounts for the rows of a conversion and compares the
+// control totals of its source and output, so that a migration can be signed
+// off as losing no records or amounts.
+package reconcile
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	transform "github.com/ashr-tech/csv-migration-tools/transform"
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// Add adds value to sum, read as the converter reads the numbers of a source
+// column: cleaned of currency symbols and thousands separators, then scaled.
+// Sums are exact, so cents never drift. It reports whether value is a number.
+func Add(sum *big.Rat, value, decimalSeparator string, scale float64) bool {
+	number, err := transform.CleanNumber(value, decimalSeparator)
+	if err == nil && scale != 0 {
+		number, err = transform.ScaleNumber(number, scale)
+	}
+	if err != nil {
+		return false
+	}
+	x, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return false
+	}
+	sum.Add(sum, x)
+	return true
+}
+
+// Output counts the rows a conversion writes and sums their control total
+// columns
+type Output struct {
+	columns   []string
+	tolerance *big.Rat
+	index     []int
+	totals    []big.Rat
+	invalid   []int
+	rows      int
+}
+
+// NewOutput prepares the control totals of target columns, found in the
+// header of the output. Totals differing by at most tolerance, e.g. from
+// rounding every row, still reconcile; nil tolerates no difference.
+func NewOutput(header, columns []string, tolerance *big.Rat) (*Output, error) {
+	if tolerance == nil {
+		tolerance = new(big.Rat)
+	}
+	o := &Output{
+		columns:   columns,
+		tolerance: tolerance,
+		index:     make([]int, len(columns)),
+		totals:    make([]big.Rat, len(columns)),
+		invalid:   make([]int, len(columns)),
+	}
+	for i, column := range columns {
+		if o.index[i] = slices.Index(header, column); o.index[i] < 0 {
+			return nil, fmt.Errorf("control total column %q is not written", column)
+		}
+	}
+	return o, nil
+}
+
+// Add counts an output row and adds its values to the control totals. The
+// converter writes plain numbers, so "." is always the decimal separator.
+func (o *Output) Add(row []string) {
+	o.rows++
+	for i, index := range o.index {
+		if value := row[index]; value != "" && !Add(&o.totals[i], value, ".", 0) {
+			o.invalid[i]++
+		}
+	}
+}
+
+// Report reconciles the output with the statistics of the conversion, whose
+// duplicate rows were removed before writing
+func (o *Output) Report(stats *types.ConversionStats, duplicates int) *types.Reconciliation {
+	report := &types.Reconciliation{
+		SourceRows: stats.RowsRead,
+		Filtered:   stats.RowsFiltered,
+		Skipped:    stats.RowsSkipped,
+		Rejected:   stats.RowsRejected,
+		Converted:  stats.RowsProcessed,
+		Duplicates: duplicates,
+		OutputRows: o.rows,
+	}
+	report.Balanced = report.SourceRows == report.Filtered+report.Skipped+report.Rejected+report.Converted &&
+		report.OutputRows == report.Converted-report.Duplicates
+
+	for i, column := range o.columns {
+		total := types.ControlTotal{Column: column, OutputInvalid: o.invalid[i]}
+		source := new(big.Rat)
+		if j := slices.IndexFunc(stats.Columns, func(col types.ColumnStats) bool { return col.Column == column }); j >= 0 {
+			col := stats.Columns[j]
+			total.SourceColumn, total.SourceInvalid = col.SourceColumn, col.SourceInvalid
+			if col.SourceTotal != nil {
+				source = col.SourceTotal
+			}
+		}
+		difference := new(big.Rat).Sub(&o.totals[i], source)
+		total.Source, total.Output, total.Difference = format(source), format(&o.totals[i]), format(difference)
+		total.Balanced = new(big.Rat).Abs(difference).Cmp(o.tolerance) <= 0
+		if o.tolerance.Sign() > 0 {
+			total.Tolerance = format(o.tolerance)
+		}
+		report.Balanced = report.Balanced && total.Balanced
+		report.ControlTotals = append(report.ControlTotals, total)
+	}
+	return report
+}
+
+// format writes a sum exactly, without trailing zeros, e.g. "1250.5". The
+// numbers summed are decimals, so their sums are too.
+func format(x *big.Rat) string {
+	s := strings.TrimRight(x.FloatString(30), "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,133 @@
+// Package report writes a self-contained HTML summary of a conversion run,
+// to attach to a migration sign-off: its files, statistics, schema coverage,
+// unmapped values, the validation of its output and its reconciliation.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	coverage "github.com/ashr-tech/csv-migration-tools/coverage"
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+	validate "github.com/ashr-tech/csv-migration-tools/validate"
+)
+
+// Summary collects a run for its report. Its methods other than Write do
+// nothing on a nil Summary, so callers need not check whether a report was
+// asked for.
+type Summary struct {
+	report    types.RunReport
+	schema    []types.ColumnSchema
+	options   validate.Options
+	validator *validate.Validator
+}
+
+// New starts the summary of the run writing the output name, converted to
+// targetSchema. The output is validated with options.
+func New(name, targetSchemaPath string, targetSchema []types.ColumnSchema, options validate.Options) *Summary {
+	return &Summary{
+		report:  types.RunReport{Name: name, TargetSchema: targetSchemaPath},
+		schema:  targetSchema,
+		options: options,
+	}
+}
+
+// Source adds a source of the run, with the coverage of the target schema by
+// its source schema as the conversion applied it
+func (s *Summary) Source(data, schemaPath string, schema []types.ColumnSchema) {
+	if s == nil {
+		return
+	}
+	report := coverage.Schemas(schema, s.schema)
+	report.SourceSchema, report.TargetSchema = schemaPath, s.report.TargetSchema
+	s.report.Sources = append(s.report.Sources, types.ReportSource{
+		Data:     utils.RedactURL(data),
+		Schema:   schemaPath,
+		Coverage: report,
+	})
+}
+
+// Header starts validating the output rows under header against the target
+// schema. Columns after the target columns, like those of --provenance, are
+// left out.
+func (s *Summary) Header(header []string) {
+	if s == nil {
+		return
+	}
+	s.validator = validate.New(header[:min(len(header), len(s.schema))], s.schema, s.options)
+}
+
+// Row validates an output row
+func (s *Summary) Row(row []string) {
+	if s == nil || s.validator == nil {
+		return
+	}
+	s.validator.Add(row[:min(len(row), len(s.schema))])
+}
+
+// Write writes the report of the run, with its statistics and the files it
+// wrote, to path, which may be a storage URL. runManifest is the manifest of
+// the run, if any.
+func (s *Summary) Write(path string, stats *types.ConversionStats, outputs []string, runManifest string) error {
+	s.report.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	s.report.Stats, s.report.Outputs, s.report.RunManifest = stats, outputs, runManifest
+	if s.validator != nil {
+		s.report.Validation = s.validator.Report()
+		s.report.Validation.File, s.report.Validation.Schema = strings.Join(outputs, ", "), s.report.TargetSchema
+	}
+
+	var html bytes.Buffer
+	if err := page.Execute(&html, &s.report); err != nil {
+		return err
+	}
+	return utils.WriteData(path, html.Bytes())
+}
+
+// valueCount is a value with how often it occurred
+type valueCount struct {
+	Value string
+	Count int
+}
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(part, total int) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", float64(part)/float64(total)*100)
+	},
+	"counts": func(counts map[string]int) []valueCount {
+		values := make([]valueCount, 0, len(counts))
+		for value, count := range counts {
+			values = append(values, valueCount{value, count})
+		}
+		sort.Slice(values, func(a, b int) bool {
+			if values[a].Count != values[b].Count {
+				return values[a].Count > values[b].Count
+			}
+			return values[a].Value < values[b].Value
+		})
+		return values
+	},
+	"unmapped": func(stats *types.ConversionStats) int {
+		unmapped := 0
+		for _, col := range stats.Columns {
+			unmapped += col.Unmapped
+		}
+		return unmapped
+	},
+	"complete": func(sources []types.ReportSource) bool {
+		for _, source := range sources {
+			if !source.Coverage.Complete {
+				return false
+			}
+		}
+		return true
+	},
+	"join": strings.Join,
+}).Parse(pageTemplate))
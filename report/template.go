@@ -0,0 +1,117 @@
+package report
+
+// pageTemplate is the report page. Its styles are inline and it links to
+// nothing, so the file can be attached to a ticket and opened anywhere.
+const pageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Migration report: {{.Name}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 72em; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+h2 { border-bottom: 1px solid #ccc; margin-top: 2em; padding-bottom: 0.2em; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+td.number { text-align: right; font-variant-numeric: tabular-nums; }
+.meta { color: #666; }
+.ok { color: #1a7f37; font-weight: bold; }
+.warn { color: #9a6700; font-weight: bold; }
+.fail { color: #cf222e; font-weight: bold; }
+code { background: #f4f4f4; padding: 0 0.2em; }
+</style>
+</head>
+<body>
+<h1>Migration report: {{.Name}}</h1>
+<p class="meta">Generated {{.GeneratedAt}}</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Rows converted</th><td class="number">{{.Stats.RowsProcessed}}</td></tr>
+<tr><th>Rows rejected</th><td class="number">{{.Stats.RowsRejected}}</td></tr>
+<tr><th>Unmapped values</th><td>{{with unmapped .Stats}}<span class="warn">{{.}}</span>{{else}}<span class="ok">none</span>{{end}}</td></tr>
+<tr><th>Schema coverage</th><td>{{if complete .Sources}}<span class="ok">complete</span>{{else}}<span class="warn">incomplete</span>{{end}}</td></tr>
+<tr><th>Validation</th><td>{{with .Validation}}{{if .Passed}}<span class="ok">passed</span>{{else}}<span class="fail">failed</span>{{end}}{{else}}not run{{end}}</td></tr>
+<tr><th>Reconciliation</th><td>{{with .Stats.Reconciliation}}{{if .Balanced}}<span class="ok">balanced</span>{{else}}<span class="fail">does not balance</span>{{end}}{{else}}not requested{{end}}</td></tr>
+</table>
+
+<h2>Files</h2>
+<table>
+<tr><th>Role</th><th>Path</th></tr>
+{{range .Sources}}<tr><td>source data</td><td><code>{{.Data}}</code></td></tr>
+<tr><td>source schema</td><td><code>{{.Schema}}</code></td></tr>
+{{end}}<tr><td>target schema</td><td><code>{{.TargetSchema}}</code></td></tr>
+{{range .Outputs}}<tr><td>output</td><td><code>{{.}}</code></td></tr>
+{{end}}{{with .RunManifest}}<tr><td>run manifest</td><td><code>{{.}}</code></td></tr>
+{{end}}</table>
+{{with .RunManifest}}<p>When the run succeeds, the SHA-256 of every file of the run, this report included, is recorded in <code>{{.}}</code>; check them with <code>csvmigrate verify {{.}}</code>.</p>
+{{end}}
+
+<h2>Statistics</h2>
+{{with .Stats}}<p>Rows read: {{.RowsRead}}, converted: {{.RowsProcessed}}, filtered out: {{.RowsFiltered}}, skipped: {{.RowsSkipped}}, rejected: {{.RowsRejected}}</p>
+{{with .NullSourceColumns}}<p class="warn">Target columns without a source column: {{join . ", "}}</p>
+{{end}}<table>
+<tr><th>Column</th><th>Source column</th><th>Fill rate</th><th>Mapped</th><th>Unmapped</th><th>Invalid</th></tr>
+{{$rows := .RowsProcessed}}{{range .Columns}}<tr><td>{{.Column}}</td><td>{{.SourceColumn}}</td><td class="number">{{percent .NonEmpty $rows}}</td><td class="number">{{.Mapped}}</td><td class="number">{{.Unmapped}}</td><td class="number">{{.Invalid}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Unmapped values</h2>
+{{if unmapped .Stats}}<p>These source values have no <code>values_mapping</code> entry and were written unchanged.</p>
+{{range .Stats.Columns}}{{if .UnmappedValues}}<h3>{{.Column}}</h3>
+<table>
+<tr><th>Source value</th><th>Rows</th></tr>
+{{range counts .UnmappedValues}}<tr><td><code>{{.Value}}</code></td><td class="number">{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}{{else}}<p>Every value of a mapped column was found in its mapping.</p>
+{{end}}
+
+<h2>Schema coverage</h2>
+{{range .Sources}}{{with .Coverage}}<h3>{{.SourceSchema}}</h3>
+{{if .Complete}}<p class="ok">The source schema covers every target column and value.</p>
+{{end}}{{with .UnmappedTargets}}<p>Target columns no source column maps to: {{join . ", "}}</p>
+{{end}}{{with .UnusedSources}}<p>Source columns mapped to no target column: {{join . ", "}}</p>
+{{end}}{{with .UnknownTargets}}<p>Target columns missing from the target schema: {{join . ", "}}</p>
+{{end}}{{with .Values}}<table>
+<tr><th>Column</th><th>Source columns</th><th>Values covered</th><th>Uncovered values</th><th>Unmapped source values</th><th>Invalid targets</th></tr>
+{{range .}}<tr><td>{{.Column}}</td><td>{{join .SourceColumns ", "}}</td><td class="number">{{.Covered}} / {{.Values}}{{if .Lookup}} (lookup){{end}}</td><td>{{join .Uncovered ", "}}</td><td>{{join .UnmappedValues ", "}}</td><td>{{join .InvalidTargets ", "}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}{{end}}
+
+<h2>Validation</h2>
+{{with .Validation}}<p>The output was checked against <code>{{.Schema}}</code>: {{if .Passed}}<span class="ok">it conforms</span>{{else}}<span class="fail">it does not conform</span>{{end}}.</p>
+{{range .HeaderErrors}}<p class="fail">Header: {{.}}</p>
+{{end}}{{with .RaggedRows}}<p class="fail">Rows with more or fewer fields than the header: {{.}}</p>
+{{end}}<table>
+<tr><th>Column</th><th>Result</th><th>Checks</th><th>Violations</th></tr>
+{{range .Columns}}<tr><td>{{.Column}}</td><td>{{if .Failures}}<span class="fail">failed</span>{{else if .Suspicious}}<span class="warn">suspect</span>{{else}}ok{{end}}</td><td>{{join .Checks ", "}}</td><td>{{template "anomalies" .Failures}}{{template "anomalies" .Suspicious}}</td></tr>
+{{end}}</table>
+{{with .Keys}}<table>
+<tr><th>Unique key</th><th>Duplicates</th><th>Examples</th></tr>
+{{range .}}<tr><td>{{join .Columns ", "}}</td><td class="number">{{.Duplicates}}</td><td>{{range .Examples}}<code>{{.Key}}</code> in rows {{range $i, $row := .Rows}}{{if $i}}, {{end}}{{$row}}{{end}}<br>{{end}}</td></tr>
+{{end}}</table>
+{{end}}{{else}}<p>The output was not validated.</p>
+{{end}}
+
+<h2>Reconciliation</h2>
+{{with .Stats.Reconciliation}}<table>
+<tr><th>Source rows</th><td class="number">{{.SourceRows}}</td></tr>
+<tr><th>Filtered out</th><td class="number">{{.Filtered}}</td></tr>
+<tr><th>Skipped</th><td class="number">{{.Skipped}}</td></tr>
+<tr><th>Rejected</th><td class="number">{{.Rejected}}</td></tr>
+<tr><th>Converted</th><td class="number">{{.Converted}}</td></tr>
+<tr><th>Duplicates removed</th><td class="number">{{.Duplicates}}</td></tr>
+<tr><th>Output rows</th><td class="number">{{.OutputRows}}</td></tr>
+</table>
+{{with .ControlTotals}}<table>
+<tr><th>Control total</th><th>Source column</th><th>Source</th><th>Output</th><th>Difference</th><th>Tolerance</th><th>Not numbers</th><th>Result</th></tr>
+{{range .}}<tr><td>{{.Column}}</td><td>{{.SourceColumn}}</td><td class="number">{{.Source}}</td><td class="number">{{.Output}}</td><td class="number">{{.Difference}}</td><td class="number">{{.Tolerance}}</td><td class="number">{{.SourceInvalid}} / {{.OutputInvalid}}</td><td>{{if .Balanced}}<span class="ok">balanced</span>{{else}}<span class="fail">differs</span>{{end}}</td></tr>
+{{end}}</table>
+{{end}}<p>{{if .Balanced}}<span class="ok">Every source row and control total is accounted for.</span>{{else}}<span class="fail">The source and output do not reconcile.</span>{{end}}</p>
+{{else}}<p>Not requested; convert with <code>--reconcile</code> to account for every source row, and <code>--control-totals</code> to compare sums.</p>
+{{end}}
+</body>
+</html>
+{{define "anomalies"}}{{range .}}<div>{{.Reason}}: {{.Count}}{{with .Examples}} ({{range $i, $e := .}}{{if $i}}, {{end}}row {{$e.Row}} <code>{{$e.Value}}</code>{{end}}){{end}}</div>{{end}}{{end}}
+`
@@ -0,0 +1,468 @@
+// Package synthetic generates fake rows conforming to a target schema, so
+// that the target system's import can be tested before real converted data
+// is ready.
+package synthetic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ashr-tech/csv-migration-tools/transform"
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// Options tune the generated rows
+type Options struct {
+	// Seed makes the rows reproducible: the same schema, seed and options
+	// always give the same rows
+	Seed int64
+	// NullRate is the share of empty values in columns that are not required
+	NullRate float64
+}
+
+// DefaultOptions are the options of the synth command
+var DefaultOptions = Options{Seed: 1, NullRate: 0.05}
+
+var (
+	firstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn", "Rowan", "Sky"}
+	lastNames  = []string{"Smith", "Tanaka", "Santoso", "Garcia", "Müller", "Kim", "Rossi", "Novak", "Silva", "Haddad", "Okafor", "Larsen"}
+	words      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor"}
+	cities     = []string{"Jakarta", "Osaka", "Lyon", "Austin", "Porto", "Leeds", "Graz", "Recife", "Pune", "Malmo", "Perth", "Quito"}
+)
+
+// dateRange is the span of generated dates, ending at dateEnd
+const dateRange = 5 * 365 * 24 * time.Hour
+
+var dateEnd = time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// Generator makes the rows of a target schema one at a time
+type Generator struct {
+	schema  []types.ColumnSchema
+	options Options
+	rng     *rand.Rand
+	values  []func(row int) string
+	row     int
+}
+
+// New returns a generator of rows of schema
+func New(schema []types.ColumnSchema, options Options) *Generator {
+	g := &Generator{schema: schema, options: options, rng: rand.New(rand.NewSource(options.Seed))}
+	for _, col := range schema {
+		g.values = append(g.values, g.column(col))
+	}
+	return g
+}
+
+// Header returns the target columns
+func (g *Generator) Header() []string {
+	header := make([]string, len(g.schema))
+	for i, col := range g.schema {
+		header[i] = col.Column
+	}
+	return header
+}
+
+// Next returns the next row
+func (g *Generator) Next() []string {
+	g.row++
+	row := make([]string, len(g.schema))
+	for i, col := range g.schema {
+		if !col.Required && g.rng.Float64() < g.options.NullRate {
+			continue
+		}
+		row[i] = g.values[i](g.row)
+	}
+	return row
+}
+
+// column returns the value generator of a column: one of its categorical
+// values, a value matching the pattern of its rules, or else a value of its
+// type in its format or for text one suiting the column's name. Values are
+// made to fit the max_length of its rules rather than cut to it, so that cut
+// values of unique columns do not repeat.
+func (g *Generator) column(col types.ColumnSchema) func(row int) string {
+	if len(col.Values) > 0 {
+		return func(int) string { return col.Values[g.rng.Intn(len(col.Values))] }
+	}
+
+	// A column unique on its own also makes its composite keys unique
+	unique := col.Unique || len(col.UniqueWith) > 0
+	maxLength := 0
+	if col.Rules != nil {
+		maxLength = col.Rules.MaxLength
+		// validate rejects schemas with patterns that do not compile
+		if pattern, err := syntax.Parse(col.Rules.Pattern, syntax.Perl); col.Rules.Pattern != "" && err == nil {
+			return g.matching(pattern, maxLength, unique)
+		}
+	}
+	if unique {
+		if value := g.uniqueColumn(col, maxLength); value != nil {
+			return value
+		}
+	}
+	return g.value(col, maxLength)
+}
+
+func (g *Generator) value(col types.ColumnSchema, maxLength int) func(row int) string {
+	name := strings.ToLower(col.Column)
+	// IDs are "id", or end in "_id" or, in camel case, "ID"
+	isID := name == "id" || strings.HasSuffix(name, "_id") || strings.HasSuffix(col.Column, "ID")
+	switch col.Type {
+	case "integer":
+		if rules := col.Rules; rules != nil && (rules.Min != nil || rules.Max != nil) {
+			low, high := numberRange(rules, 1000)
+			low, high = math.Ceil(low), max(math.Floor(high), math.Ceil(low))
+			return func(int) string { return strconv.FormatInt(int64(low)+g.rng.Int63n(int64(high-low)+1), 10) }
+		}
+		if name == "id" {
+			return strconv.Itoa
+		}
+		if isID {
+			return func(int) string { return strconv.Itoa(1 + g.rng.Intn(1000)) }
+		}
+		return func(int) string { return strconv.Itoa(g.rng.Intn(1000)) }
+
+	case "number":
+		decimals := 2
+		if col.Decimals != nil {
+			decimals = *col.Decimals
+		}
+		low, high := 0.0, 10000.0
+		if col.Rules != nil {
+			low, high = numberRange(col.Rules, high)
+		}
+		// Rounding must not cross the bounds
+		unit := math.Pow(10, float64(decimals))
+		low, high = math.Ceil(low*unit)/unit, math.Floor(high*unit)/unit
+		return func(int) string {
+			return strconv.FormatFloat(low+g.rng.Float64()*(high-low), 'f', decimals, 64)
+		}
+
+	case "boolean":
+		return func(int) string { return strconv.FormatBool(g.rng.Intn(2) == 1) }
+
+	case "date", "datetime":
+		layout := dateLayout(col)
+		end, span := dateEnd, dateRange
+		if col.Rules != nil {
+			end, span = dates(col.Rules)
+		}
+		return func(int) string {
+			offset := time.Duration(g.rng.Int63n(int64(span)))
+			if col.Type == "date" {
+				offset = offset.Truncate(24 * time.Hour)
+			} else {
+				offset = offset.Truncate(time.Second)
+			}
+			return end.Add(-offset).Format(layout)
+		}
+
+	case "phone":
+		return g.phone
+	}
+
+	switch {
+	case isID:
+		prefix := idPrefix(strings.TrimSuffix(strings.TrimSuffix(name, "_id"), "id"))
+		if name == "id" {
+			return func(row int) string { return code(prefix, row, 6, maxLength) }
+		}
+		return func(int) string { return code(prefix, 1+g.rng.Intn(1000), 6, maxLength) }
+	case strings.Contains(name, "email"):
+		return func(row int) string {
+			return fitText(fmt.Sprintf("%s.%d@example.com", strings.ToLower(g.pick(firstNames)), row), maxLength)
+		}
+	case strings.Contains(name, "phone") || strings.Contains(name, "mobile"):
+		return g.phone
+	case containsAny(name, "price", "amount", "cost", "total"):
+		return func(int) string { return strconv.FormatFloat(g.rng.Float64()*1000, 'f', 2, 64) }
+	case containsAny(name, "qty", "quantity", "count"):
+		return func(int) string { return strconv.Itoa(g.rng.Intn(500)) }
+	case containsAny(name, "product", "item", "company", "supplier", "vendor", "store", "department", "category"):
+		// Names of things rather than people
+		return func(int) string { return fitText(title(g.pick(words))+" "+title(g.pick(words)), maxLength) }
+	case strings.Contains(name, "name"):
+		return func(int) string { return fitText(g.pick(firstNames)+" "+g.pick(lastNames), maxLength) }
+	case strings.Contains(name, "city"):
+		return func(int) string { return fitText(g.pick(cities), maxLength) }
+	case strings.Contains(name, "code") || strings.Contains(name, "sku"):
+		prefix := strings.ToUpper(name[:min(3, len(name))]) + "-"
+		return func(row int) string { return code(prefix, row, 5, maxLength) }
+	}
+	return func(int) string { return fitText(g.pick(words)+" "+g.pick(words)+" "+g.pick(words), maxLength) }
+}
+
+// uniqueColumn returns a generator of a unique column that derives every
+// value from the row number, within the range and max_length of its rules,
+// or nil for booleans, which cannot be unique. Numbers, integers and dates
+// count from one end of their range and start over past the other, so rows
+// repeat only once the range runs out of values.
+func (g *Generator) uniqueColumn(col types.ColumnSchema, maxLength int) func(row int) string {
+	name := strings.ToLower(col.Column)
+	switch col.Type {
+	case "integer":
+		return func(row int) string { return strconv.FormatFloat(sequence(col.Rules, row, 1), 'f', 0, 64) }
+	case "number":
+		decimals := 2
+		if col.Decimals != nil {
+			decimals = *col.Decimals
+		}
+		// A closed range holds the most values a decimal apart
+		step := 1.0
+		if col.Rules != nil && col.Rules.Min != nil && col.Rules.Max != nil {
+			step = math.Pow(10, -float64(decimals))
+		}
+		return func(row int) string { return strconv.FormatFloat(sequence(col.Rules, row, step), 'f', decimals, 64) }
+	case "date", "datetime":
+		layout := dateLayout(col)
+		step := time.Second
+		if col.Type == "date" {
+			step = 24 * time.Hour
+		}
+		// Without a range, dates count back from dateEnd for good
+		end, count := dateEnd.Add(-step), int64(math.MaxInt64)
+		if col.Rules != nil && (col.Rules.MinDate != "" || col.Rules.MaxDate != "") {
+			var span time.Duration
+			end, span = dates(col.Rules)
+			count = int64(span/step) + 1
+		}
+		return func(row int) string {
+			return end.Add(-time.Duration(int64(row-1)%count) * step).Format(layout)
+		}
+	case "phone":
+		return func(row int) string { return fmt.Sprintf("+1555%07d", row) }
+	case "boolean":
+		return nil
+	}
+
+	prefix := idPrefix(strings.TrimSuffix(name, "_id"))
+	if strings.Contains(name, "email") {
+		return func(row int) string {
+			email := fmt.Sprintf("%s.%d@example.com", strings.ToLower(g.pick(firstNames)), row)
+			if maxLength > 0 && utf8.RuneCountInString(email) > maxLength {
+				return code(prefix, row, 6, maxLength)
+			}
+			return email
+		}
+	}
+	return func(row int) string { return code(prefix, row, 6, maxLength) }
+}
+
+// matchAttempts is how many values matching a pattern are drawn at most for
+// one that fits the max_length and, in unique columns, is new
+const matchAttempts = 100
+
+// matchRepeats is how many times an unbounded repeat such as * or + repeats
+// beyond its minimum at most
+const matchRepeats = 5
+
+// matching returns a generator of random values matching pattern, which fit
+// maxLength and are new in unique columns, as far as matchAttempts draws
+// find one. Unique columns remember their values to tell new ones.
+func (g *Generator) matching(pattern *syntax.Regexp, maxLength int, unique bool) func(row int) string {
+	var seen map[string]bool
+	if unique {
+		seen = make(map[string]bool)
+	}
+	return func(int) string {
+		var value strings.Builder
+		for attempt := 0; attempt < matchAttempts; attempt++ {
+			value.Reset()
+			g.match(&value, pattern)
+			if (maxLength <= 0 || utf8.RuneCountInString(value.String()) <= maxLength) && !seen[value.String()] {
+				break
+			}
+		}
+		if seen != nil {
+			seen[value.String()] = true
+		}
+		return value.String()
+	}
+}
+
+// match writes a random string matching re
+func (g *Generator) match(value *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			value.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		if len(re.Rune) > 0 {
+			value.WriteRune(g.classRune(re.Rune))
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		value.WriteByte(alphanumeric[g.rng.Intn(len(alphanumeric))])
+	case syntax.OpCapture:
+		g.match(value, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.match(value, sub)
+		}
+	case syntax.OpAlternate:
+		g.match(value, re.Sub[g.rng.Intn(len(re.Sub))])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		low, high := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			low, high = 0, -1
+		case syntax.OpPlus:
+			low, high = 1, -1
+		case syntax.OpQuest:
+			low, high = 0, 1
+		}
+		if high < 0 {
+			high = low + matchRepeats
+		}
+		for range low + g.rng.Intn(high-low+1) {
+			g.match(value, re.Sub[0])
+		}
+	}
+	// Anchors and empty matches write nothing
+}
+
+const alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// classRune returns a random rune of a character class, given as pairs of
+// bounds, and a printable ASCII one when the class has any, so that negated
+// classes like [^,] give readable values
+func (g *Generator) classRune(ranges []rune) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if low, high := max(ranges[i], ' '), min(ranges[i+1], '~'); low <= high {
+			printable = append(printable, low, high)
+		}
+	}
+	if len(printable) > 0 {
+		ranges = printable
+	}
+	pair := 2 * g.rng.Intn(len(ranges)/2)
+	return ranges[pair] + rune(g.rng.Intn(int(ranges[pair+1]-ranges[pair])+1))
+}
+
+// sequence returns the row's value of a sequence of values step apart within
+// the rules' min and max: counting up from min, down from max without a min,
+// or up from step without either, and starting over past the end of a closed
+// range
+func sequence(rules *types.Rules, row int, step float64) float64 {
+	n := float64(row - 1)
+	switch {
+	case rules == nil || (rules.Min == nil && rules.Max == nil):
+		return float64(row) * step
+	case rules.Max == nil:
+		return (math.Ceil(*rules.Min/step) + n) * step
+	case rules.Min == nil:
+		return (math.Floor(*rules.Max/step) - n) * step
+	}
+	low, high := math.Ceil(*rules.Min/step), math.Floor(*rules.Max/step)
+	return (low + math.Mod(n, max(high-low+1, 1))) * step
+}
+
+// idPrefix returns the prefix of codes of a column named name: its first
+// three letters in upper case, or "ID"
+func idPrefix(name string) string {
+	prefix := strings.ToUpper(name)
+	if len(prefix) > 3 {
+		prefix = prefix[:3]
+	}
+	if prefix == "" {
+		prefix = "ID"
+	}
+	return prefix
+}
+
+// code writes n after prefix, padded with zeros to digits. To fit maxLength
+// the padding shrinks down to four digits, then the prefix is shortened; both
+// are the same for every n, so codes of different numbers always differ.
+func code(prefix string, n, digits, maxLength int) string {
+	if maxLength > 0 && len(prefix)+digits > maxLength {
+		digits = max(maxLength-len(prefix), min(maxLength, 4))
+		prefix = prefix[:max(0, maxLength-digits)]
+	}
+	return fmt.Sprintf("%s%0*d", prefix, digits, n)
+}
+
+// fitText shortens text to maxLength by dropping its last words, and cuts the
+// first word only when it is too long by itself
+func fitText(text string, maxLength int) string {
+	for maxLength > 0 && utf8.RuneCountInString(text) > maxLength {
+		i := strings.LastIndexByte(text, ' ')
+		if i <= 0 {
+			return string([]rune(text)[:maxLength])
+		}
+		text = text[:i]
+	}
+	return text
+}
+
+// dateLayout returns the layout of the values of a date or datetime column
+func dateLayout(col types.ColumnSchema) string {
+	format := col.Format
+	if format == "" {
+		format = transform.DefaultDateFormat
+		if col.Type == "datetime" {
+			format = transform.DefaultDateTimeFormat
+		}
+	}
+	return transform.DateLayout(format)
+}
+
+// numberRange returns the bounds of the rules' min and max, from 0 to span
+// by default or span wide when one is given
+func numberRange(rules *types.Rules, span float64) (float64, float64) {
+	switch {
+	case rules.Min != nil && rules.Max != nil:
+		return *rules.Min, *rules.Max
+	case rules.Min != nil:
+		return *rules.Min, *rules.Min + span
+	case rules.Max != nil:
+		return min(0, *rules.Max-span), *rules.Max
+	}
+	return 0, span
+}
+
+// dates returns the end and span of the rules' date range, five years wide
+// when it is open at one end, and of the default range without one
+func dates(rules *types.Rules) (time.Time, time.Duration) {
+	first, errFirst := time.Parse(time.DateOnly, rules.MinDate)
+	last, errLast := time.Parse(time.DateOnly, rules.MaxDate)
+	// The range ends with the last second of its last day
+	end := last.Add(24*time.Hour - time.Second)
+	switch {
+	case errFirst == nil && errLast == nil:
+		return end, max(end.Sub(first), 1)
+	case errFirst == nil:
+		return first.Add(dateRange), dateRange
+	case errLast == nil:
+		return end, dateRange
+	}
+	return dateEnd, dateRange
+}
+
+func containsAny(name string, parts ...string) bool {
+	for _, part := range parts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func title(word string) string {
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+func (g *Generator) pick(list []string) string {
+	return list[g.rng.Intn(len(list))]
+}
+
+// phone returns a number of the 555 range reserved for fiction, in E.164
+func (g *Generator) phone(int) string {
+	return fmt.Sprintf("+1555%07d", g.rng.Intn(10000000))
+}
@@ -0,0 +1,81 @@
+package synthetic_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ashr-tech/csv-migration-tools/synthetic"
+	"github.com/ashr-tech/csv-migration-tools/types"
+	"github.com/ashr-tech/csv-migration-tools/utils"
+	"github.com/ashr-tech/csv-migration-tools/validate"
+)
+
+// TestRowsPassValidation generates rows of the sample schemas and of a schema
+// with rules of every kind, and validates them against their schema, as synth
+// promises its rows pass validate
+func TestRowsPassValidation(t *testing.T) {
+	for i := 1; i <= 4; i++ {
+		t.Run(fmt.Sprintf("sample_%d", i), func(t *testing.T) {
+			schema, err := utils.LoadSchemaJSON(fmt.Sprintf("../output/schemas/target_schema_%d.json", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRoundTrip(t, schema, 2000)
+		})
+	}
+
+	t.Run("rules", func(t *testing.T) {
+		zero, hundred, score, price := 0.0, 100.0, 10000.0, 99.5
+		two := 2
+		schema := []types.ColumnSchema{
+			{Column: "id", Type: "integer", Required: true, Unique: true, Rules: &types.Rules{Min: &hundred}},
+			{Column: "code", Unique: true, UniqueWith: []string{"region"}, Rules: &types.Rules{MaxLength: 8}},
+			{Column: "region", Values: []string{"north", "south"}},
+			{Column: "sku", Required: true, Unique: true, Rules: &types.Rules{Pattern: `[A-Z]{3}-\d{4}`}},
+			{Column: "postcode", Rules: &types.Rules{Pattern: `\d{5}(-\d{4})?`, MaxLength: 5}},
+			{Column: "note", Rules: &types.Rules{Pattern: `[^,]+`, MaxLength: 4}},
+			{Column: "score", Type: "integer", Unique: true, Rules: &types.Rules{Min: &zero, Max: &score}},
+			{Column: "price", Type: "number", Decimals: &two, Unique: true, Rules: &types.Rules{Min: &zero, Max: &price}},
+			{Column: "balance", Type: "number", Unique: true, Rules: &types.Rules{Max: &zero}},
+			{Column: "opened", Type: "date", Unique: true, Rules: &types.Rules{MinDate: "2020-01-01", MaxDate: "2029-12-31"}},
+			{Column: "updated", Type: "datetime", Unique: true, Rules: &types.Rules{MinDate: "2024-01-01"}},
+			{Column: "email", Unique: true, Rules: &types.Rules{MaxLength: 10}},
+			{Column: "customer_id", Rules: &types.Rules{MaxLength: 5}},
+			{Column: "customer_name", Rules: &types.Rules{MaxLength: 6}},
+			{Column: "description", Rules: &types.Rules{MaxLength: 12}},
+		}
+		checkRoundTrip(t, schema, 2000)
+	})
+}
+
+// checkRoundTrip validates rows generated from schema against it and reports
+// the failures
+func checkRoundTrip(t *testing.T, schema []types.ColumnSchema, rows int) {
+	t.Helper()
+	if err := validate.CheckSchema(schema); err != nil {
+		t.Fatal(err)
+	}
+	generator := synthetic.New(schema, synthetic.DefaultOptions)
+	validator := validate.New(generator.Header(), schema, validate.DefaultOptions)
+	for range rows {
+		validator.Add(generator.Next())
+	}
+
+	report := validator.Report()
+	if report.Passed {
+		return
+	}
+	for _, err := range report.HeaderErrors {
+		t.Error(err)
+	}
+	for _, col := range report.Columns {
+		for _, failure := range col.Failures {
+			t.Errorf("column %s: %+v", col.Column, failure)
+		}
+	}
+	for _, key := range report.Keys {
+		if key.Duplicates > 0 {
+			t.Errorf("key %v: %d duplicates, e.g. %+v", key.Columns, key.Duplicates, key.Examples)
+		}
+	}
+}
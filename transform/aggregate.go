@@ -0,0 +1,160 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Aggregate is one summary column, e.g. "sum(amount)=total"
+type Aggregate struct {
+	Func   string
+	Column string
+	Name   string
+}
+
+// ParseAggregates parses a specification like "sum(amount)=total,count(*)".
+// The output column name defaults to func_column, or the function name for count(*).
+func ParseAggregates(spec string) ([]Aggregate, error) {
+	var aggregates []Aggregate
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		expr, name, _ := strings.Cut(part, "=")
+		fn, column, found := strings.Cut(strings.TrimSpace(expr), "(")
+		if !found || !strings.HasSuffix(column, ")") {
+			return nil, fmt.Errorf("invalid aggregate %q: expected func(column)", part)
+		}
+
+		agg := Aggregate{
+			Func:   strings.ToLower(strings.TrimSpace(fn)),
+			Column: strings.TrimSpace(strings.TrimSuffix(column, ")")),
+			Name:   strings.TrimSpace(name),
+		}
+		switch agg.Func {
+		case "sum", "min", "max":
+			if agg.Column == "*" {
+				return nil, fmt.Errorf("invalid aggregate %q: %s needs a column", part, agg.Func)
+			}
+		case "count":
+		default:
+			return nil, fmt.Errorf("invalid aggregate %q: unknown function %q", part, agg.Func)
+		}
+		if agg.Name == "" {
+			agg.Name = agg.Func + "_" + agg.Column
+			if agg.Column == "*" {
+				agg.Name = agg.Func
+			}
+		}
+		aggregates = append(aggregates, agg)
+	}
+
+	return aggregates, nil
+}
+
+// GroupBy collapses the data rows of records into one row per distinct
+// combination of groupColumns, followed by the aggregate columns. Groups keep
+// the order in which they first appear; sum ignores empty values and count(column)
+// counts non-empty ones.
+func GroupBy(records [][]string, groupColumns []string, aggregates []Aggregate) ([][]string, error) {
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	groupIdx, err := columnIndexes(records[0], groupColumns)
+	if err != nil {
+		return nil, err
+	}
+	aggIdx := make([]int, len(aggregates))
+	for i, agg := range aggregates {
+		aggIdx[i] = -1
+		if agg.Column == "*" {
+			continue
+		}
+		idx, err := columnIndexes(records[0], []string{agg.Column})
+		if err != nil {
+			return nil, err
+		}
+		aggIdx[i] = idx[0]
+	}
+
+	type group struct {
+		key     []string
+		sums    []float64
+		counts  []int
+		extrema []string
+	}
+	groups := make(map[string]*group)
+	var order []*group
+
+	for rowIdx, row := range records[1:] {
+		key := rowKey(row, groupIdx)
+		g, exists := groups[key]
+		if !exists {
+			g = &group{
+				sums:    make([]float64, len(aggregates)),
+				counts:  make([]int, len(aggregates)),
+				extrema: make([]string, len(aggregates)),
+			}
+			for _, idx := range groupIdx {
+				g.key = append(g.key, field(row, idx))
+			}
+			groups[key] = g
+			order = append(order, g)
+		}
+
+		for i, agg := range aggregates {
+			if aggIdx[i] == -1 {
+				g.counts[i]++
+				continue
+			}
+			value := strings.TrimSpace(field(row, aggIdx[i]))
+			if value == "" {
+				continue
+			}
+			switch agg.Func {
+			case "sum":
+				n, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("row %d, column %s: cannot sum non-numeric value %q", rowIdx+1, agg.Column, value)
+				}
+				g.sums[i] += n
+			case "min":
+				if g.counts[i] == 0 || compareValues(value, g.extrema[i]) < 0 {
+					g.extrema[i] = value
+				}
+			case "max":
+				if g.counts[i] == 0 || compareValues(value, g.extrema[i]) > 0 {
+					g.extrema[i] = value
+				}
+			}
+			g.counts[i]++
+		}
+	}
+
+	header := append([]string{}, groupColumns...)
+	for _, agg := range aggregates {
+		header = append(header, agg.Name)
+	}
+
+	result := [][]string{header}
+	for _, g := range order {
+		row := append([]string{}, g.key...)
+		for i, agg := range aggregates {
+			switch agg.Func {
+			case "sum":
+				row = append(row, strconv.FormatFloat(g.sums[i], 'f', -1, 64))
+			case "count":
+				row = append(row, strconv.Itoa(g.counts[i]))
+			default:
+				row = append(row, g.extrema[i])
+			}
+		}
+		result = append(result, row)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package transform
+
+// SelectColumns returns records restricted to columns, in the given order
+func SelectColumns(records [][]string, columns []string) ([][]string, error) {
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	selectColumns, err := NewColumnSelector(records[0], columns)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([][]string, len(records))
+	for rowIdx, row := range records {
+		output[rowIdx] = selectColumns(row)
+	}
+
+	return output, nil
+}
+
+// NewColumnSelector returns a function selecting columns, in that order, from
+// rows under header, for rows converted one at a time
+func NewColumnSelector(header, columns []string) (func(row []string) []string, error) {
+	idx, err := columnIndexes(header, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []string) []string {
+		selected := make([]string, len(idx))
+		for i, colIdx := range idx {
+			selected[i] = field(row, colIdx)
+		}
+		return selected
+	}, nil
+}
@@ -0,0 +1,95 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// LoadCrosswalk reads an ID crosswalk CSV with source_id and target_id
+// columns. When entity is set, only rows of that entity are used.
+func LoadCrosswalk(path, entity string) (map[string]string, error) {
+	if entity == "" {
+		return LoadLookup(path, "source_id", "target_id")
+	}
+
+	records, err := utils.ReadCSVFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		header[i] = strings.TrimSpace(name)
+	}
+
+	idx, err := columnIndexes(header, []string{"source_id", "target_id", "entity"})
+	if err != nil {
+		return nil, fmt.Errorf("crosswalk %s: %v", path, err)
+	}
+
+	table := make(map[string]string)
+	for _, row := range records[1:] {
+		if strings.TrimSpace(field(row, idx[2])) != entity {
+			continue
+		}
+		key := strings.TrimSpace(field(row, idx[0]))
+		if _, exists := table[key]; !exists {
+			table[key] = strings.TrimSpace(field(row, idx[1]))
+		}
+	}
+
+	return table, nil
+}
+
+// IDCrosswalk collects the source → target ID pairs produced by a run, so
+// they can be written as a crosswalk CSV for reconciliation and rollback.
+// Files converted concurrently may add to it at the same time.
+type IDCrosswalk struct {
+	mu   sync.Mutex
+	seen map[[2]string]bool
+	rows [][]string
+}
+
+func NewIDCrosswalk() *IDCrosswalk {
+	return &IDCrosswalk{seen: make(map[[2]string]bool)}
+}
+
+// Add records that sourceID of entity became targetID. Repeated pairs are ignored.
+func (c *IDCrosswalk) Add(entity, sourceID, targetID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := [2]string{entity, sourceID}
+	if c.seen[key] {
+		return
+	}
+
+	c.seen[key] = true
+	c.rows = append(c.rows, []string{sourceID, targetID, entity})
+}
+
+func (c *IDCrosswalk) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.rows)
+}
+
+// Records returns the crosswalk with a source_id,target_id,entity header,
+// ordered by entity and target ID
+func (c *IDCrosswalk) Records() [][]string {
+	c.mu.Lock()
+	rows := append([][]string(nil), c.rows...)
+	c.mu.Unlock()
+	sort.SliceStable(rows, func(a, b int) bool {
+		if rows[a][2] != rows[b][2] {
+			return rows[a][2] < rows[b][2]
+		}
+		return compareValues(rows[a][1], rows[b][1]) < 0
+	})
+
+	return append([][]string{{"source_id", "target_id", "entity"}}, rows...)
+}
@@ -0,0 +1,193 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default output formats for date and datetime columns
+const (
+	DefaultDateFormat     = "YYYY-MM-DD"
+	DefaultDateTimeFormat = "YYYY-MM-DD HH:mm:ss"
+)
+
+// Source formats of Excel serial dates like 44927 or 44927.75: days since
+// 1899-12-30 in the 1900 date system of Windows Excel, or since 1904-01-01 in
+// the 1904 system of older Mac workbooks, with the time of day as the
+// fraction. They contain no date tokens, so DateLayout keeps them as layouts.
+const (
+	ExcelSerialFormat     = "excel"
+	ExcelSerial1904Format = "excel1904"
+)
+
+// maxExcelSerial is 9999-12-31, the last date Excel can represent
+const maxExcelSerial = 2958465
+
+// Format tokens, longest first so "MMMM" wins over "MM"
+var dateTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"},
+	{"MMMM", "January"},
+	{"MMM", "Jan"},
+	{"YY", "06"},
+	{"MM", "01"},
+	{"DD", "02"},
+	{"HH", "15"},
+	{"hh", "03"},
+	{"mm", "04"},
+	{"ss", "05"},
+	{"M", "1"},
+	{"D", "2"},
+	{"A", "PM"},
+	{"Z", "Z07:00"},
+}
+
+// DateLayout converts a format such as "DD/MM/YYYY HH:mm" into a Go time layout
+func DateLayout(format string) string {
+	var layout strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, t := range dateTokens {
+			if strings.HasPrefix(format[i:], t.token) {
+				layout.WriteString(t.layout)
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			layout.WriteByte(format[i])
+			i++
+		}
+	}
+
+	return layout.String()
+}
+
+// Candidate layouts for detection. Single-digit layouts also accept
+// zero-padded values, so "2/1/2006" covers "02/01/2006".
+var (
+	isoLayouts = []string{
+		"2006-01-02",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04:05",
+		"2006/01/02",
+		"2006/01/02 15:04:05",
+		"20060102",
+	}
+	dayFirstLayouts = []string{
+		"2/1/2006",
+		"2/1/2006 15:04:05",
+		"2/1/2006 15:04",
+		"2-1-2006",
+		"2.1.2006",
+		"2/1/06",
+		"2 Jan 2006",
+		"2-Jan-2006",
+		"2 January 2006",
+		"02-Jan-06",
+	}
+	monthFirstLayouts = []string{
+		"1/2/2006",
+		"1/2/2006 15:04:05",
+		"1/2/2006 15:04",
+		"1/2/2006 3:04 PM",
+		"1-2-2006",
+		"1/2/06",
+		"Jan 2, 2006",
+		"Jan 2 2006",
+		"January 2, 2006",
+	}
+)
+
+// DetectDateLayout finds the layout parsing the most values. When day-first and
+// month-first layouts parse equally well (e.g. every day is 12 or less), the
+// preference decides. It returns false when no layout parses any value.
+func DetectDateLayout(values []string, dayFirst bool) (string, bool) {
+	candidates := append([]string{}, isoLayouts...)
+	if dayFirst {
+		candidates = append(candidates, dayFirstLayouts...)
+		candidates = append(candidates, monthFirstLayouts...)
+	} else {
+		candidates = append(candidates, monthFirstLayouts...)
+		candidates = append(candidates, dayFirstLayouts...)
+	}
+
+	best, bestCount := "", 0
+	for _, layout := range candidates {
+		count := 0
+		for _, value := range values {
+			if _, err := time.Parse(layout, value); err == nil {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = layout, count
+		}
+	}
+
+	// Numbers in a date column are Excel serials, unless a layout such as
+	// "20060102" reads as many values
+	count := 0
+	for _, value := range values {
+		if _, err := ParseExcelSerial(value, false); err == nil {
+			count++
+		}
+	}
+	if count > bestCount {
+		best, bestCount = ExcelSerialFormat, count
+	}
+
+	return best, bestCount > 0
+}
+
+// ParseExcelSerial converts an Excel serial date of the 1900 date system, or
+// of the 1904 system when date1904 is set, into a time rounded to the second.
+// Serial 60 is the 29 February 1900 that Excel wrongly counts, so serials
+// below it start a day later.
+func ParseExcelSerial(value string, date1904 bool) (time.Time, error) {
+	serial, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(serial) {
+		return time.Time{}, fmt.Errorf("invalid Excel serial date %q", value)
+	}
+
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	switch {
+	case date1904:
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+		if serial < 0 || serial >= maxExcelSerial-1462+1 {
+			return time.Time{}, fmt.Errorf("invalid Excel serial date %q", value)
+		}
+	case serial < 1 || serial >= maxExcelSerial+1 || (serial >= 60 && serial < 61):
+		return time.Time{}, fmt.Errorf("invalid Excel serial date %q", value)
+	case serial < 60:
+		epoch = epoch.AddDate(0, 0, 1)
+	}
+
+	// Whole days are added as dates, since a Duration spans only 292 years
+	seconds := int64(math.Round(serial * 24 * 60 * 60))
+	days := seconds / (24 * 60 * 60)
+	return epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds-days*24*60*60) * time.Second), nil
+}
+
+// ConvertDate parses value with fromLayout, or as an Excel serial date for
+// ExcelSerialFormat and ExcelSerial1904Format, and formats it with toLayout
+func ConvertDate(value, fromLayout, toLayout string) (string, error) {
+	var t time.Time
+	var err error
+	switch fromLayout {
+	case ExcelSerialFormat, ExcelSerial1904Format:
+		t, err = ParseExcelSerial(value, fromLayout == ExcelSerial1904Format)
+	default:
+		t, err = time.Parse(fromLayout, value)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return t.Format(toLayout), nil
+}
@@ -0,0 +1,77 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dedupe removes rows sharing the same values in keyColumns. The first row of
+// records is the header. When keepLast is set the last duplicate is kept instead
+// of the first. It returns the remaining records and the number of rows removed.
+func Dedupe(records [][]string, keyColumns []string, keepLast bool) ([][]string, int, error) {
+	if len(records) == 0 {
+		return records, 0, nil
+	}
+
+	keyIdx, err := columnIndexes(records[0], keyColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Remember which row is kept for every key
+	kept := make(map[string]int)
+	var order []string
+	for rowIdx := 1; rowIdx < len(records); rowIdx++ {
+		key := rowKey(records[rowIdx], keyIdx)
+		if _, exists := kept[key]; !exists {
+			order = append(order, key)
+		} else if !keepLast {
+			continue
+		}
+		kept[key] = rowIdx
+	}
+
+	keptRows := make([]bool, len(records))
+	for _, key := range order {
+		keptRows[kept[key]] = true
+	}
+
+	output := [][]string{records[0]}
+	for rowIdx := 1; rowIdx < len(records); rowIdx++ {
+		if keptRows[rowIdx] {
+			output = append(output, records[rowIdx])
+		}
+	}
+
+	return output, len(records) - len(output), nil
+}
+
+func columnIndexes(header []string, columns []string) ([]int, error) {
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = -1
+		for j, name := range header {
+			if name == column {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] == -1 {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	return indexes, nil
+}
+
+func rowKey(row []string, indexes []int) string {
+	parts := make([]string, len(indexes))
+	for i, idx := range indexes {
+		if idx < len(row) {
+			parts[i] = row[idx]
+		}
+	}
+
+	// Unit separator keeps ("a,b", "c") and ("a", "b,c") apart
+	return strings.Join(parts, "\x1f")
+}
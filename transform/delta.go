@@ -0,0 +1,54 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// Delta splits the data rows of records into rows whose key is not in state
+// (inserts) and rows whose content hash changed (updates), updating state with
+// the new hashes. Columns listed in ignore are left out of the hash.
+func Delta(records [][]string, state *types.DeltaState, ignore []string) (inserts, updates [][]string, err error) {
+	if len(records) == 0 {
+		return records, records, nil
+	}
+
+	header := records[0]
+	keyIdx, err := columnIndexes(header, state.KeyColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var hashIdx []int
+	for i, name := range header {
+		if !slices.Contains(ignore, name) {
+			hashIdx = append(hashIdx, i)
+		}
+	}
+
+	if state.Rows == nil {
+		state.Rows = make(map[string]string)
+	}
+
+	inserts = [][]string{header}
+	updates = [][]string{header}
+	for _, row := range records[1:] {
+		key := rowKey(row, keyIdx)
+		sum := sha256.Sum256([]byte(rowKey(row, hashIdx)))
+		hash := hex.EncodeToString(sum[:])
+
+		previous, exists := state.Rows[key]
+		switch {
+		case !exists:
+			inserts = append(inserts, row)
+		case previous != hash:
+			updates = append(updates, row)
+		}
+		state.Rows[key] = hash
+	}
+
+	return inserts, updates, nil
+}
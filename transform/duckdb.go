@@ -0,0 +1,669 @@
+package transform
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// DuckDBConversion converts one source file inside DuckDB: the schemas are
+// turned into SQL, so files larger than memory are mapped, deduplicated,
+// aggregated and sorted by DuckDB, which spills to disk. Values are cleaned
+// like the Go conversion does; lookups, phone columns and Unicode
+// normalization are not available.
+type DuckDBConversion struct {
+	// Source is a local CSV or TSV file (optionally gzipped) or Parquet file
+	Source string
+	// Delimiter of CSV sources, detected when 0
+	Delimiter    rune
+	SourceSchema []types.ColumnSchema
+	TargetSchema []types.ColumnSchema
+	// DayFirst prefers DD/MM over MM/DD when detecting ambiguous dates
+	DayFirst bool
+	// NullSource (warn, fill or fail) handles target columns no source
+	// column feeds; fill writes NullSourceFill
+	NullSource     string
+	NullSourceFill string
+	Offset         int
+	Limit          int
+	DedupeBy       []string
+	DedupeKeepLast bool
+	GroupBy        []string
+	Aggregates     []Aggregate
+	SortKeys       []SortKey
+	// Columns, HeaderStyle and HeaderRenames shape the written header
+	Columns       []string
+	HeaderStyle   string
+	HeaderRenames map[string]string
+	// Output is the converted file, written with OutDelimiter (default comma)
+	Output       string
+	OutDelimiter rune
+	QuoteAll     bool
+	// Compress is "" or "gzip"
+	Compress string
+}
+
+// DuckDBResult is the outcome of a DuckDB conversion
+type DuckDBResult struct {
+	Stats *types.ConversionStats
+	// Duplicates is the number of rows removed by DedupeBy
+	Duplicates int
+	// Rows is the number of rows written
+	Rows int
+}
+
+// duckdbMacros clean values the way CleanNumber, CleanInteger and
+// ParseExcelSerial do; each returns NULL for invalid values
+const duckdbMacros = `CREATE MACRO clean_value(v) AS nullif(trim(CAST(v AS VARCHAR), ' ' || chr(9) || chr(10) || chr(11) || chr(12) || chr(13)), '');
+CREATE MACRO last_index(s, c) AS CASE WHEN instr(s, c) = 0 THEN 0 ELSE length(s) - instr(reverse(s), c) + 1 END;
+CREATE MACRO occurrences(s, c) AS length(s) - length(replace(s, c, ''));
+CREATE MACRO guess_decimal_separator(d) AS CASE
+	WHEN instr(d, '.') > 0 AND instr(d, ',') > 0 THEN CASE WHEN last_index(d, ',') > last_index(d, '.') THEN ',' ELSE '.' END
+	WHEN occurrences(d, '.') > 1 THEN ','
+	WHEN occurrences(d, ',') = 1 AND length(d) - instr(d, ',') <> 3 THEN ','
+	ELSE '.'
+END;
+CREATE MACRO number_core(v) AS regexp_extract(v, '[.,]?[0-9](.*[0-9])?');
+CREATE MACRO number_digits(v) AS regexp_replace(number_core(v), '[\s''\x{00A0}\x{202F}]', '', 'g');
+CREATE MACRO plain_number(d, sep) AS replace(replace(d, CASE WHEN sep = ',' THEN '.' ELSE ',' END, ''), sep, '.');
+CREATE MACRO leading_zero(x) AS CASE WHEN starts_with(x, '.') THEN '0' || x ELSE x END;
+CREATE MACRO negative_number(v) AS (starts_with(v, '(') AND ends_with(v, ')'))
+	OR instr(regexp_extract(v, '^[^0-9]*'), '-') > 0
+	OR trim(regexp_extract(v, '[^0-9]*$')) = '-';
+CREATE MACRO signed_number(v, x) AS CASE WHEN TRY_CAST(x AS DOUBLE) IS NOT NULL THEN CASE WHEN negative_number(v) THEN '-' || x ELSE x END END;
+CREATE MACRO clean_number(v, sep) AS CASE WHEN regexp_full_match(number_core(v), '[0-9.,\s''\x{00A0}\x{202F}]+') THEN
+	signed_number(v, leading_zero(plain_number(number_digits(v), CASE WHEN sep = '' THEN guess_decimal_separator(number_digits(v)) ELSE sep END)))
+END;
+CREATE MACRO clean_integer(v, sep) AS CASE WHEN NOT regexp_matches(clean_number(v, sep), '\.[0-9]*[1-9]') THEN split_part(clean_number(v, sep), '.', 1) END;
+CREATE MACRO excel_days(epoch, serial) AS epoch + to_seconds(CAST(round(serial * 86400) AS BIGINT));
+CREATE MACRO excel_serial(v, date1904) AS CASE
+	WHEN date1904 AND TRY_CAST(v AS DOUBLE) >= 0 AND TRY_CAST(v AS DOUBLE) < 2957004 THEN excel_days(TIMESTAMP '1904-01-01', TRY_CAST(v AS DOUBLE))
+	WHEN NOT date1904 AND TRY_CAST(v AS DOUBLE) >= 1 AND TRY_CAST(v AS DOUBLE) < 60 THEN excel_days(TIMESTAMP '1899-12-31', TRY_CAST(v AS DOUBLE))
+	WHEN NOT date1904 AND TRY_CAST(v AS DOUBLE) >= 61 AND TRY_CAST(v AS DOUBLE) < 2958466 THEN excel_days(TIMESTAMP '1899-12-30', TRY_CAST(v AS DOUBLE))
+END;
+`
+
+// duckdbColumn is the SQL of one target column
+type duckdbColumn struct {
+	// raw is the trimmed source value, mapped the value after values_mapping
+	// and converted, for typed columns, the typed value or NULL when invalid
+	raw, mapped, converted string
+	// mappingKeys are the values_mapping keys, nil without a mapping
+	mappingKeys []string
+	typed       bool
+}
+
+const (
+	// maxDateSamples caps the distinct values a date format is detected from
+	maxDateSamples = 10000
+	// maxInvalidRows caps the row numbers kept per column for invalid values
+	maxInvalidRows = 10
+)
+
+// Run converts the source into the output file
+func (c DuckDBConversion) Run() (*DuckDBResult, error) {
+	relation, err := c.sourceRelation()
+	if err != nil {
+		return nil, err
+	}
+	setup := duckdbMacros + "CREATE VIEW source AS SELECT * FROM " + relation + ";\n"
+
+	workDir, err := os.MkdirTemp("", "convert-duckdb-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+	outputFile := func(name string) string { return filepath.Join(workDir, name+".csv") }
+
+	// Source columns are looked up by their trimmed name, like the Go conversion does
+	if err := utils.RunDuckDB(setup + duckdbCopy("SELECT column_name FROM (DESCRIBE source)", outputFile("columns"))); err != nil {
+		return nil, err
+	}
+	described, err := readDuckDBOutput(outputFile("columns"))
+	if err != nil {
+		return nil, err
+	}
+	sourceColumns := make(map[string]string)
+	for _, row := range described[1:] {
+		if _, exists := sourceColumns[strings.TrimSpace(row[0])]; !exists {
+			sourceColumns[strings.TrimSpace(row[0])] = row[0]
+		}
+	}
+
+	stats := &types.ConversionStats{Columns: make([]types.ColumnStats, len(c.TargetSchema))}
+	columns := make([]duckdbColumn, len(c.TargetSchema))
+	sources := make([]*types.ColumnSchema, len(c.TargetSchema))
+	var detect []int
+	for i, targetCol := range c.TargetSchema {
+		stats.Columns[i] = types.ColumnStats{
+			Column:         targetCol.Column,
+			UnmappedValues: make(map[string]int),
+			MissingIDs:     make(map[string]int),
+		}
+
+		for s := range c.SourceSchema {
+			if c.SourceSchema[s].TargetColumn == targetCol.Column {
+				sources[i] = &c.SourceSchema[s]
+				break
+			}
+		}
+		sourceCol := sources[i]
+		if sourceCol == nil || sourceCol.Column == "" {
+			if c.NullSource == "fail" {
+				return nil, fmt.Errorf("target column %s has no source column", targetCol.Column)
+			}
+			stats.NullSourceColumns = append(stats.NullSourceColumns, targetCol.Column)
+			fill := "NULL::VARCHAR"
+			if c.NullSource == "fill" && c.NullSourceFill != "" {
+				fill = utils.QuoteDuckDBString(c.NullSourceFill)
+			}
+			columns[i] = duckdbColumn{raw: "NULL::VARCHAR", mapped: fill}
+			continue
+		}
+
+		stats.Columns[i].SourceColumn = sourceCol.Column
+		if err := checkDuckDBSupport(*sourceCol, targetCol); err != nil {
+			return nil, err
+		}
+		if (targetCol.Type == "date" || targetCol.Type == "datetime") && sourceCol.Format == "" {
+			if _, exists := sourceColumns[sourceCol.Column]; exists {
+				detect = append(detect, i)
+			}
+		}
+	}
+
+	// Undeclared date formats are detected from a sample of distinct values
+	detected := make(map[int]string)
+	if len(detect) > 0 {
+		var samples []string
+		for _, i := range detect {
+			value := "clean_value(" + utils.QuoteDuckDBIdentifier(sourceColumns[sources[i].Column]) + ")"
+			samples = append(samples, fmt.Sprintf("(SELECT %d AS target, value FROM (SELECT DISTINCT %s AS value FROM source WHERE %s IS NOT NULL LIMIT %d))",
+				i, value, value, maxDateSamples))
+		}
+		if err := utils.RunDuckDB(setup + duckdbCopy(strings.Join(samples, " UNION ALL "), outputFile("dates"))); err != nil {
+			return nil, err
+		}
+		rows, err := readDuckDBOutput(outputFile("dates"))
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[int][]string)
+		for _, row := range rows[1:] {
+			i, _ := strconv.Atoi(row[0])
+			values[i] = append(values[i], row[1])
+		}
+		for _, i := range detect {
+			if layout, found := DetectDateLayout(values[i], c.DayFirst); found {
+				detected[i] = layout
+				stats.Columns[i].DetectedFormat = layout
+			}
+		}
+	}
+
+	for i, targetCol := range c.TargetSchema {
+		sourceCol := sources[i]
+		if sourceCol == nil || sourceCol.Column == "" {
+			continue
+		}
+		column, err := duckdbColumnSQL(*sourceCol, targetCol, sourceColumns, detected[i], i)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = column
+	}
+
+	script, err := c.conversionSQL(columns, outputFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.RunDuckDB(setup + script); err != nil {
+		return nil, err
+	}
+
+	// Statistics
+	columnStats, err := readDuckDBOutput(outputFile("stats"))
+	if err != nil {
+		return nil, err
+	}
+	counts, err := readDuckDBOutput(outputFile("counts"))
+	if err != nil {
+		return nil, err
+	}
+	if len(columnStats) < 2 || len(counts) < 2 {
+		return nil, fmt.Errorf("DuckDB returned no statistics")
+	}
+	values := columnStats[1]
+	number := func(row []string, index int) int {
+		n, _ := strconv.Atoi(row[index])
+		return n
+	}
+	stats.RowsProcessed = number(values, 0)
+	stats.RowsSkipped = number(counts[1], 0) - stats.RowsProcessed
+	for i, column := range columns {
+		base := 1 + i*duckdbColumnStats
+		colStats := &stats.Columns[i]
+		colStats.NonEmpty = number(values, base)
+		if column.mappingKeys != nil {
+			colStats.Mapped = number(values, base+1)
+			colStats.Unmapped = number(values, base+2)
+		}
+		colStats.Invalid = number(values, base+3)
+		for _, row := range strings.Fields(values[base+4]) {
+			rowNumber, _ := strconv.Atoi(row)
+			colStats.InvalidRows = append(colStats.InvalidRows, rowNumber)
+		}
+	}
+	if _, err := os.Stat(outputFile("unmapped")); err == nil {
+		unmapped, err := readDuckDBOutput(outputFile("unmapped"))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range unmapped[1:] {
+			i, _ := strconv.Atoi(row[0])
+			stats.Columns[i].UnmappedValues[row[1]] = number(row, 2)
+		}
+	}
+
+	return &DuckDBResult{
+		Stats:      stats,
+		Duplicates: stats.RowsProcessed - number(counts[1], 1),
+		Rows:       number(counts[1], 2),
+	}, nil
+}
+
+// sourceRelation is the DuckDB table function reading the source file
+func (c DuckDBConversion) sourceRelation() (string, error) {
+	if utils.IsRemote(c.Source) || utils.IsDatabaseURL(c.Source) {
+		return "", fmt.Errorf("the DuckDB engine reads local files only, not %s", utils.RedactURL(c.Source))
+	}
+	if _, err := os.Stat(c.Source); err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(c.Source), ".gz")
+	switch {
+	case utils.IsParquet(c.Source):
+		return "read_parquet(" + utils.QuoteDuckDBString(c.Source) + ")", nil
+	case strings.Contains(c.Source, "!") || slices.Contains([]string{".zip", ".xlsx", ".json", ".jsonl", ".ndjson"}, filepath.Ext(name)):
+		return "", fmt.Errorf("the DuckDB engine reads CSV, TSV and Parquet files only, not %s", c.Source)
+	}
+
+	options := "header = true, all_varchar = true, null_padding = true"
+	if c.Delimiter != 0 {
+		options += ", delim = " + utils.QuoteDuckDBString(string(c.Delimiter))
+	}
+	return "read_csv(" + utils.QuoteDuckDBString(c.Source) + ", " + options + ")", nil
+}
+
+// checkDuckDBSupport rejects schema features only the Go conversion has
+func checkDuckDBSupport(sourceCol, targetCol types.ColumnSchema) error {
+	switch {
+	case sourceCol.Lookup != nil:
+		return fmt.Errorf("column %s: lookups are not supported by the DuckDB engine", sourceCol.Column)
+	case sourceCol.NormalizeUnicode:
+		return fmt.Errorf("column %s: normalize_unicode is not supported by the DuckDB engine", sourceCol.Column)
+	case sourceCol.LooseMapping:
+		return fmt.Errorf("column %s: loose_mapping is not supported by the DuckDB engine", sourceCol.Column)
+	case targetCol.Type == "phone":
+		return fmt.Errorf("column %s: phone columns are not supported by the DuckDB engine", targetCol.Column)
+	case targetCol.Type == "number" && targetCol.Decimals != nil && targetCol.Rounding != "" && targetCol.Rounding != "half_up":
+		return fmt.Errorf("column %s: rounding %q is not supported by the DuckDB engine, only half_up", targetCol.Column, targetCol.Rounding)
+	}
+	return nil
+}
+
+// duckdbColumnSQL builds the expressions of target column index i, which
+// sourceCol feeds
+func duckdbColumnSQL(sourceCol, targetCol types.ColumnSchema, sourceColumns map[string]string, detected string, i int) (duckdbColumn, error) {
+	column := duckdbColumn{raw: "NULL::VARCHAR"}
+	if name, exists := sourceColumns[sourceCol.Column]; exists {
+		column.raw = "clean_value(" + utils.QuoteDuckDBIdentifier(name) + ")"
+	}
+
+	raw := fmt.Sprintf("r%d", i)
+	column.mapped = raw
+	if sourceCol.ValuesMapping != nil {
+		column.mappingKeys = sourceCol.ValuesMapping.Keys()
+		if len(column.mappingKeys) > 0 {
+			var mapping strings.Builder
+			mapping.WriteString("nullif(CASE " + raw)
+			for _, key := range column.mappingKeys {
+				mappedValue, _ := sourceCol.ValuesMapping.Lookup(key)
+				mapping.WriteString(" WHEN " + utils.QuoteDuckDBString(key) + " THEN " + utils.QuoteDuckDBString(mappedValue))
+			}
+			mapping.WriteString(" ELSE " + raw + " END, '')")
+			column.mapped = mapping.String()
+		}
+	}
+
+	mapped := fmt.Sprintf("m%d", i)
+	decimalSeparator := utils.QuoteDuckDBString(sourceCol.DecimalSeparator)
+	switch targetCol.Type {
+	case "date", "datetime":
+		column.typed = true
+		format := targetCol.Format
+		if format == "" {
+			format = DefaultDateFormat
+			if targetCol.Type == "datetime" {
+				format = DefaultDateTimeFormat
+			}
+		}
+		toFormat, err := strftimeFormat(DateLayout(format))
+		if err != nil {
+			return column, fmt.Errorf("column %s: %v", targetCol.Column, err)
+		}
+
+		fromLayout := detected
+		if sourceCol.Format != "" {
+			fromLayout = DateLayout(sourceCol.Format)
+		}
+		if fromLayout == "" {
+			// Without a format every value is invalid
+			column.converted = "NULL::VARCHAR"
+			break
+		}
+		if fromLayout == ExcelSerialFormat || fromLayout == ExcelSerial1904Format {
+			column.converted = fmt.Sprintf("strftime(excel_serial(%s, %t), %s)",
+				mapped, fromLayout == ExcelSerial1904Format, utils.QuoteDuckDBString(toFormat))
+			break
+		}
+		fromFormat, err := strftimeFormat(fromLayout)
+		if err != nil {
+			return column, fmt.Errorf("column %s: %v", sourceCol.Column, err)
+		}
+		column.converted = fmt.Sprintf("strftime(try_strptime(%s, %s), %s)",
+			mapped, utils.QuoteDuckDBString(fromFormat), utils.QuoteDuckDBString(toFormat))
+
+	case "number":
+		column.typed = true
+		number := fmt.Sprintf("clean_number(%s, %s)", mapped, decimalSeparator)
+		if scale := sourceCol.Scale; scale != 0 {
+			factor := strconv.FormatFloat(scale, 'f', -1, 64)
+			factorDecimals := 0
+			if _, fraction, found := strings.Cut(factor, "."); found {
+				factorDecimals = len(fraction)
+			}
+			number = fmt.Sprintf("rtrim(rtrim(CAST(TRY_CAST(%s AS DECIMAL(38, 18)) * CAST(%s AS DECIMAL(38, %d)) AS VARCHAR), '0'), '.')",
+				number, utils.QuoteDuckDBString(factor), factorDecimals)
+		}
+		if targetCol.Decimals != nil {
+			decimals := *targetCol.Decimals
+			number = fmt.Sprintf("CAST(TRY_CAST(round(TRY_CAST(%s AS DECIMAL(38, 18)), %d) AS DECIMAL(38, %d)) AS VARCHAR)", number, decimals, decimals)
+		}
+		column.converted = number
+
+	case "integer":
+		column.typed = true
+		column.converted = fmt.Sprintf("clean_integer(%s, %s)", mapped, decimalSeparator)
+	}
+
+	return column, nil
+}
+
+// duckdbColumnStats is the number of statistics queried per target column
+const duckdbColumnStats = 5
+
+// conversionSQL builds the script converting the source and writing the
+// output and statistics files
+func (c DuckDBConversion) conversionSQL(columns []duckdbColumn, outputFile func(string) string) (string, error) {
+	var script strings.Builder
+	list := func(items []string) string { return strings.Join(items, ", ") }
+
+	// Row numbers count data rows from 1, matching invalid row numbers of the Go conversion
+	script.WriteString("CREATE VIEW numbered AS SELECT row_number() OVER () AS __row, * FROM source;\n")
+
+	var raws, mappeds, converteds, values, targets []string
+	for i, column := range columns {
+		raws = append(raws, fmt.Sprintf("%s AS r%d", column.raw, i))
+		mappeds = append(mappeds, fmt.Sprintf("%s AS m%d", column.mapped, i))
+		converted := fmt.Sprintf("m%d", i)
+		if column.typed {
+			converted = column.converted
+		}
+		converteds = append(converteds, fmt.Sprintf("%s AS c%d", converted, i))
+		// Invalid values are kept as they are
+		values = append(values, fmt.Sprintf("coalesce(c%d, m%d) AS v%d", i, i, i))
+		targets = append(targets, fmt.Sprintf("v%d AS %s", i, utils.QuoteDuckDBIdentifier(c.TargetSchema[i].Column)))
+	}
+
+	where := fmt.Sprintf("__row > %d", c.Offset)
+	if c.Limit > 0 {
+		where += fmt.Sprintf(" AND __row <= %d", c.Offset+c.Limit)
+	}
+	fmt.Fprintf(&script, "CREATE VIEW raw AS SELECT __row, %s FROM numbered WHERE %s;\n", list(raws), where)
+	fmt.Fprintf(&script, "CREATE VIEW mapped AS SELECT *, %s FROM raw;\n", list(mappeds))
+	fmt.Fprintf(&script, "CREATE VIEW typed AS SELECT *, %s FROM mapped;\n", list(converteds))
+	fmt.Fprintf(&script, "CREATE VIEW converted AS SELECT *, %s FROM typed;\n", list(values))
+	fmt.Fprintf(&script, "CREATE VIEW targeted AS SELECT __row, %s FROM converted;\n", list(targets))
+
+	header := make([]string, len(c.TargetSchema))
+	for i, col := range c.TargetSchema {
+		header[i] = col.Column
+	}
+
+	// Remove duplicated rows, keeping the first or last one in place
+	deduped := "SELECT * FROM targeted"
+	if len(c.DedupeBy) > 0 {
+		if _, err := columnIndexes(header, c.DedupeBy); err != nil {
+			return "", fmt.Errorf("error removing duplicates: %v", err)
+		}
+		order := "__row"
+		if c.DedupeKeepLast {
+			order += " DESC"
+		}
+		deduped += fmt.Sprintf(" QUALIFY row_number() OVER (PARTITION BY %s ORDER BY %s) = 1", list(quoteDuckDBIdentifiers(c.DedupeBy)), order)
+	}
+	fmt.Fprintf(&script, "CREATE VIEW deduped AS %s;\n", deduped)
+
+	// Summarize rows per group, keeping groups in order of first appearance
+	grouped := "SELECT * FROM deduped"
+	if len(c.GroupBy) > 0 {
+		if _, err := columnIndexes(header, c.GroupBy); err != nil {
+			return "", fmt.Errorf("error aggregating output: %v", err)
+		}
+		selected := append([]string{"min(__row) AS __row"}, quoteDuckDBIdentifiers(c.GroupBy)...)
+		for _, agg := range c.Aggregates {
+			expr, err := duckdbAggregate(agg, header)
+			if err != nil {
+				return "", fmt.Errorf("error aggregating output: %v", err)
+			}
+			selected = append(selected, expr+" AS "+utils.QuoteDuckDBIdentifier(agg.Name))
+		}
+		grouped = fmt.Sprintf("SELECT %s FROM deduped GROUP BY %s", list(selected), list(quoteDuckDBIdentifiers(c.GroupBy)))
+
+		header = slices.Clone(c.GroupBy)
+		for _, agg := range c.Aggregates {
+			header = append(header, agg.Name)
+		}
+	}
+	fmt.Fprintf(&script, "CREATE VIEW grouped AS %s;\n", grouped)
+
+	// Numbers sort numerically before text and empty values, as in Sort, and
+	// equal rows keep their order
+	var order []string
+	if _, err := columnIndexes(header, sortKeyColumns(c.SortKeys)); err != nil {
+		return "", fmt.Errorf("error sorting output: %v", err)
+	}
+	for _, key := range c.SortKeys {
+		numbers, values := "ASC NULLS LAST", "ASC NULLS FIRST"
+		if key.Desc {
+			numbers, values = "DESC NULLS FIRST", "DESC NULLS LAST"
+		}
+		column := utils.QuoteDuckDBIdentifier(key.Column)
+		order = append(order, fmt.Sprintf("TRY_CAST(%s AS DOUBLE) %s", column, numbers), column+" "+values)
+	}
+	order = append(order, "__row")
+
+	// Reorder or restrict the written columns, then rename them
+	written := header
+	if len(c.Columns) > 0 {
+		if _, err := columnIndexes(header, c.Columns); err != nil {
+			return "", fmt.Errorf("error selecting output columns: %v", err)
+		}
+		written = c.Columns
+	}
+	renamed := [][]string{slices.Clone(written)}
+	if err := RenameHeader(renamed, c.HeaderStyle, c.HeaderRenames); err != nil {
+		return "", fmt.Errorf("error renaming output header: %v", err)
+	}
+	var outputColumns []string
+	for i, name := range written {
+		outputColumns = append(outputColumns, utils.QuoteDuckDBIdentifier(name)+" AS "+utils.QuoteDuckDBIdentifier(renamed[0][i]))
+	}
+	fmt.Fprintf(&script, "CREATE TABLE result AS SELECT %s FROM grouped ORDER BY %s;\n", list(outputColumns), list(order))
+
+	delimiter := c.OutDelimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	options := "FORMAT csv, HEADER, DELIMITER " + utils.QuoteDuckDBString(string(delimiter))
+	if c.QuoteAll {
+		options += ", FORCE_QUOTE *"
+	}
+	if c.Compress == "gzip" {
+		options += ", COMPRESSION gzip"
+	}
+	fmt.Fprintf(&script, "COPY result TO %s (%s);\n", utils.QuoteDuckDBString(c.Output), options)
+
+	// Per column: non-empty, mapped, unmapped and invalid values and the
+	// first invalid rows
+	stats := []string{"count(*)"}
+	var unmapped []string
+	for i, column := range columns {
+		isMapped := "false"
+		if len(column.mappingKeys) > 0 {
+			keys := make([]string, len(column.mappingKeys))
+			for k, key := range column.mappingKeys {
+				keys[k] = utils.QuoteDuckDBString(key)
+			}
+			isMapped = fmt.Sprintf("r%d IN (%s)", i, list(keys))
+		}
+		isInvalid := fmt.Sprintf("m%d IS NOT NULL AND c%d IS NULL", i, i)
+		stats = append(stats,
+			fmt.Sprintf("count(v%d)", i),
+			fmt.Sprintf("count(*) FILTER (WHERE %s)", isMapped),
+			fmt.Sprintf("count(*) FILTER (WHERE r%d IS NOT NULL AND NOT (%s))", i, isMapped),
+			fmt.Sprintf("count(*) FILTER (WHERE %s)", isInvalid),
+			fmt.Sprintf("array_to_string(list_slice(list(CAST(__row AS VARCHAR) ORDER BY __row) FILTER (WHERE %s), 1, %d), ' ')", isInvalid, maxInvalidRows))
+		if column.mappingKeys != nil {
+			unmapped = append(unmapped, fmt.Sprintf("(SELECT %d AS target, r%d AS value, count(*) AS n FROM converted WHERE r%d IS NOT NULL AND NOT (%s) GROUP BY r%d)",
+				i, i, i, isMapped, i))
+		}
+	}
+	script.WriteString(duckdbCopy("SELECT "+list(stats)+" FROM converted", outputFile("stats")))
+	script.WriteString(duckdbCopy("SELECT (SELECT count(*) FROM numbered), (SELECT count(*) FROM deduped), (SELECT count(*) FROM result)", outputFile("counts")))
+	if len(unmapped) > 0 {
+		script.WriteString(duckdbCopy(strings.Join(unmapped, " UNION ALL "), outputFile("unmapped")))
+	}
+
+	return script.String(), nil
+}
+
+// duckdbAggregate is the SQL of an aggregate over the rows of a group, with
+// the results GroupBy gives: sums ignore empty values and drop a zero
+// fraction, and min and max compare numerically when all values are numbers
+func duckdbAggregate(agg Aggregate, header []string) (string, error) {
+	if agg.Column == "*" {
+		return "count(*)", nil
+	}
+	if _, err := columnIndexes(header, []string{agg.Column}); err != nil {
+		return "", err
+	}
+
+	column := utils.QuoteDuckDBIdentifier(agg.Column)
+	switch agg.Func {
+	case "sum":
+		return fmt.Sprintf(`regexp_replace(CAST(coalesce(sum(CAST(%s AS DOUBLE)), 0) AS VARCHAR), '\.0$', '')`, column), nil
+	case "count":
+		return fmt.Sprintf("count(%s)", column), nil
+	}
+	fn := "arg_" + agg.Func
+	return fmt.Sprintf("CASE WHEN count(%s) = count(TRY_CAST(%s AS DOUBLE)) THEN %s(%s, TRY_CAST(%s AS DOUBLE)) ELSE %s(%s) END",
+		column, column, fn, column, column, agg.Func, column), nil
+}
+
+// strftimeTokens translate Go time layout elements into strftime and
+// strptime specifiers, longest first
+var strftimeTokens = []struct{ layout, format string }{
+	{"January", "%B"},
+	{"Jan", "%b"},
+	{"Monday", "%A"},
+	{"Mon", "%a"},
+	{"2006", "%Y"},
+	{"PM", "%p"},
+	{"01", "%m"},
+	{"02", "%d"},
+	{"03", "%I"},
+	{"04", "%M"},
+	{"05", "%S"},
+	{"06", "%y"},
+	{"15", "%H"},
+	{"1", "%-m"},
+	{"2", "%-d"},
+	{"3", "%-I"},
+	{"%", "%%"},
+}
+
+// strftimeFormat converts a Go time layout into a DuckDB format string
+func strftimeFormat(layout string) (string, error) {
+	if strings.Contains(layout, "Z07") || strings.Contains(layout, "-07") {
+		return "", fmt.Errorf("dates with time zone offsets are not supported by the DuckDB engine")
+	}
+
+	var format strings.Builder
+	for i := 0; i < len(layout); {
+		matched := false
+		for _, t := range strftimeTokens {
+			if strings.HasPrefix(layout[i:], t.layout) {
+				format.WriteString(t.format)
+				i += len(t.layout)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			format.WriteByte(layout[i])
+			i++
+		}
+	}
+	return format.String(), nil
+}
+
+// duckdbCopy is the statement writing the result of query to a CSV file
+func duckdbCopy(query, path string) string {
+	return fmt.Sprintf("COPY (%s) TO %s (FORMAT csv, HEADER);\n", query, utils.QuoteDuckDBString(path))
+}
+
+// readDuckDBOutput reads a CSV file written by duckdbCopy
+func readDuckDBOutput(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("DuckDB wrote no result: %v", err)
+	}
+	defer file.Close()
+	return csv.NewReader(file).ReadAll()
+}
+
+func quoteDuckDBIdentifiers(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = utils.QuoteDuckDBIdentifier(name)
+	}
+	return quoted
+}
+
+func sortKeyColumns(keys []SortKey) []string {
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = key.Column
+	}
+	return columns
+}
@@ -0,0 +1,331 @@
+package transform
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ExternalSorter sorts rows that need not fit in memory. Rows are collected
+// until they take up the memory limit, then sorted and spilled to a temporary
+// file as a run; Each merges the runs. Like Sort, rows with equal keys keep
+// the order they were added in.
+type ExternalSorter struct {
+	keys   []SortKey
+	keyIdx []int
+	limit  int
+
+	rows [][]string
+	size int
+	// runs are the spilled sorted chunks, in the order they were added
+	dir  string
+	runs []string
+	// files numbers the files written to dir, and spilled counts the runs
+	files   int
+	spilled int
+}
+
+// NewExternalSorter returns a sorter of rows with the given header, which
+// holds about memoryLimit bytes of rows before spilling them
+func NewExternalSorter(header []string, keys []SortKey, memoryLimit int) (*ExternalSorter, error) {
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = key.Column
+	}
+	keyIdx, err := columnIndexes(header, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExternalSorter{keys: keys, keyIdx: keyIdx, limit: memoryLimit}, nil
+}
+
+// Add adds a row, which the sorter keeps, so it must not be changed afterwards
+func (s *ExternalSorter) Add(row []string) error {
+	s.rows = append(s.rows, row)
+	s.size += rowSize(row)
+	if s.size >= s.limit {
+		return s.spill()
+	}
+
+	return nil
+}
+
+// rowSize estimates the memory of a row: its slice, string headers and bytes
+func rowSize(row []string) int {
+	size := 24 + 16*len(row)
+	for _, value := range row {
+		size += len(value)
+	}
+
+	return size
+}
+
+func (s *ExternalSorter) sortRows() {
+	sort.SliceStable(s.rows, func(a, b int) bool {
+		return compareRows(s.rows[a], s.rows[b], s.keys, s.keyIdx) < 0
+	})
+}
+
+// spill writes the held rows sorted into a new run
+func (s *ExternalSorter) spill() error {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "convert-sort-*")
+		if err != nil {
+			return fmt.Errorf("error creating sort directory: %v", err)
+		}
+		s.dir = dir
+	}
+
+	s.sortRows()
+	path, file, err := s.createRun()
+	if err != nil {
+		return fmt.Errorf("error spilling sorted rows: %v", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriterSize(file, 1<<20)
+	for _, row := range s.rows {
+		writeRunRow(w, row)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error spilling sorted rows: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error spilling sorted rows: %v", err)
+	}
+
+	s.runs = append(s.runs, path)
+	s.rows, s.size = nil, 0
+	s.spilled++
+	if len(s.runs) == maxRuns {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact merges the spilled runs into one. Runs are spilled in the order
+// their rows were added, so the merged run comes first of the ones that follow.
+func (s *ExternalSorter) compact() error {
+	path, file, err := s.createRun()
+	if err != nil {
+		return fmt.Errorf("error spilling sorted rows: %v", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriterSize(file, 1<<20)
+	err = s.merge(s.runs, func(row []string) error {
+		writeRunRow(w, row)
+		return nil
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error spilling sorted rows: %v", err)
+	}
+
+	for _, run := range s.runs {
+		os.Remove(run)
+	}
+	s.runs = []string{path}
+	return nil
+}
+
+func (s *ExternalSorter) createRun() (string, *os.File, error) {
+	s.files++
+	path := filepath.Join(s.dir, fmt.Sprintf("run%06d", s.files))
+	file, err := os.Create(path)
+	return path, file, err
+}
+
+// Spilled returns the number of sorted runs spilled to disk so far
+func (s *ExternalSorter) Spilled() int {
+	return s.spilled
+}
+
+// Each calls fn with every added row in sorted order, stopping at the first
+// error. Rows are only held in memory when none were spilled; otherwise the
+// runs and the rows still held are merged, one row of each at a time.
+func (s *ExternalSorter) Each(fn func(row []string) error) error {
+	if len(s.runs) == 0 {
+		s.sortRows()
+		for _, row := range s.rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(s.rows) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+
+	return s.merge(s.runs, fn)
+}
+
+// maxRuns is the most runs merged at once; once that many are spilled they
+// are merged into one, so the open files stay few however small the memory
+// limit is
+const maxRuns = 64
+
+// merge calls fn with the rows of runs in sorted order
+func (s *ExternalSorter) merge(runs []string, fn func(row []string) error) error {
+	merger := &runMerger{sorter: s}
+	defer merger.close()
+	for i, path := range runs {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error reading sorted rows: %v", err)
+		}
+		run := &runReader{index: i, file: file, r: bufio.NewReaderSize(file, 256*1024)}
+		merger.readers = append(merger.readers, run)
+		if err := run.advance(); err != nil {
+			return err
+		}
+		if run.row != nil {
+			merger.heads = append(merger.heads, run)
+		}
+	}
+	heap.Init(merger)
+
+	for merger.Len() > 0 {
+		run := merger.heads[0]
+		if err := fn(run.row); err != nil {
+			return err
+		}
+		if err := run.advance(); err != nil {
+			return err
+		}
+		if run.row == nil {
+			heap.Pop(merger)
+		} else {
+			heap.Fix(merger, 0)
+		}
+	}
+
+	return nil
+}
+
+// Close removes the spilled runs
+func (s *ExternalSorter) Close() error {
+	s.rows = nil
+	if s.dir == "" {
+		return nil
+	}
+
+	dir := s.dir
+	s.dir, s.runs = "", nil
+	return os.RemoveAll(dir)
+}
+
+// Runs hold each row as its number of fields followed by the length and the
+// bytes of every field
+func writeRunRow(w *bufio.Writer, row []string) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(row)))])
+	for _, value := range row {
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(value)))])
+		w.WriteString(value)
+	}
+}
+
+type runReader struct {
+	index int
+	file  *os.File
+	r     *bufio.Reader
+	// row is the next row of the run, nil when it is exhausted
+	row []string
+	buf []byte
+}
+
+// advance reads the next row of the run
+func (run *runReader) advance() error {
+	row, err := run.read()
+	if err != nil {
+		return fmt.Errorf("error reading sorted rows: %v", err)
+	}
+	run.row = row
+	return nil
+}
+
+func (run *runReader) read() ([]string, error) {
+	fields, err := binary.ReadUvarint(run.r)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The fields are read into one buffer and cut from one string
+	row := make([]string, fields)
+	ends := make([]int, fields)
+	run.buf = run.buf[:0]
+	for i := range row {
+		length, err := binary.ReadUvarint(run.r)
+		if err != nil {
+			return nil, err
+		}
+		start := len(run.buf)
+		run.buf = append(run.buf, make([]byte, length)...)
+		if _, err := io.ReadFull(run.r, run.buf[start:]); err != nil {
+			return nil, err
+		}
+		ends[i] = len(run.buf)
+	}
+	data := string(run.buf)
+	start := 0
+	for i, end := range ends {
+		row[i] = data[start:end]
+		start = end
+	}
+
+	return row, nil
+}
+
+// runMerger is a heap of the runs by their next row. Runs added earlier win
+// ties, which keeps equal rows in the order they were added.
+type runMerger struct {
+	sorter  *ExternalSorter
+	readers []*runReader
+	heads   []*runReader
+}
+
+func (m *runMerger) close() {
+	for _, run := range m.readers {
+		run.file.Close()
+	}
+}
+
+func (m *runMerger) Len() int { return len(m.heads) }
+
+func (m *runMerger) Less(a, b int) bool {
+	s := m.sorter
+	if c := compareRows(m.heads[a].row, m.heads[b].row, s.keys, s.keyIdx); c != 0 {
+		return c < 0
+	}
+
+	return m.heads[a].index < m.heads[b].index
+}
+
+func (m *runMerger) Swap(a, b int) { m.heads[a], m.heads[b] = m.heads[b], m.heads[a] }
+
+func (m *runMerger) Push(x any) { m.heads = append(m.heads, x.(*runReader)) }
+
+func (m *runMerger) Pop() any {
+	last := m.heads[len(m.heads)-1]
+	m.heads = m.heads[:len(m.heads)-1]
+	return last
+}
@@ -0,0 +1,399 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Filter is a compiled row filter expression such as
+// row["status"] != "deleted" && row["created_at"] >= "2020-01-01".
+//
+// Supported syntax: row["column"] lookups, string and number literals,
+// comparisons (== != < <= > >=), &&, ||, ! and parentheses. Comparisons are
+// numeric when both sides are numbers, otherwise textual.
+//
+// A compiled filter is bound to the header of each source with Bind, which
+// resolves its columns to field indexes.
+type Filter struct {
+	root filterNode
+}
+
+type filterNode interface {
+	eval(row []string) (string, bool)
+}
+
+// CompileFilter parses expression into a Filter
+func CompileFilter(expression string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos].text)
+	}
+
+	return &Filter{root: root}, nil
+}
+
+// Bind returns the filter reading rows by the field indexes of its columns,
+// taken from index, which maps column names to fields. A column missing from
+// index is an error, as a misspelled column would otherwise silently match
+// every row or none.
+func (f *Filter) Bind(index map[string]int) (*Filter, error) {
+	var err error
+	var bind func(node filterNode) filterNode
+	bind = func(node filterNode) filterNode {
+		switch n := node.(type) {
+		case columnNode:
+			i, ok := index[n.name]
+			if !ok && err == nil {
+				err = fmt.Errorf("unknown column %q in filter", n.name)
+			}
+			return columnNode{name: n.name, index: i}
+		case notNode:
+			return notNode{bind(n.operand)}
+		case logicalNode:
+			return logicalNode{or: n.or, left: bind(n.left), right: bind(n.right)}
+		case comparisonNode:
+			return comparisonNode{op: n.op, left: bind(n.left), right: bind(n.right)}
+		}
+		return node
+	}
+
+	root := bind(f.root)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{root: root}, nil
+}
+
+// Match reports whether the row satisfies the filter, which must be bound to
+// the header of the row
+func (f *Filter) Match(row []string) bool {
+	_, ok := f.root.eval(row)
+	return ok
+}
+
+// Columns returns the columns the filter reads
+func (f *Filter) Columns() []string {
+	var columns []string
+	var walk func(node filterNode)
+	walk = func(node filterNode) {
+		switch n := node.(type) {
+		case columnNode:
+			columns = append(columns, n.name)
+		case notNode:
+			walk(n.operand)
+		case logicalNode:
+			walk(n.left)
+			walk(n.right)
+		case comparisonNode:
+			walk(n.left)
+			walk(n.right)
+		}
+	}
+	walk(f.root)
+	return columns
+}
+
+type tokenKind int
+
+const (
+	tokenOperator tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenIdent
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				value.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, filterToken{tokenString, value.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{tokenNumber, string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, filterToken{tokenIdent, string(runes[i:j])})
+			i = j
+		default:
+			operator := ""
+			for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]"} {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q in filter", r)
+			}
+			tokens = append(tokens, filterToken{tokenOperator, operator})
+			i += len(operator)
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) accept(operator string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == operator {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *filterParser) expect(operator string) error {
+	if !p.accept(operator) {
+		return fmt.Errorf("expected %q in filter", operator)
+	}
+
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{or: true, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return comparisonNode{op: op, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token.kind {
+	case tokenString, tokenNumber:
+		return literalNode(token.text), nil
+	case tokenIdent:
+		switch token.text {
+		case "row":
+			if err := p.expect("["); err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenString {
+				return nil, fmt.Errorf("expected column name after row[ in filter")
+			}
+			column := p.tokens[p.pos].text
+			p.pos++
+			return columnNode{name: column, index: -1}, p.expect("]")
+		case "true":
+			return literalNode("true"), nil
+		case "false":
+			return literalNode(""), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %q in filter", token.text)
+}
+
+// Values are truthy when non-empty, so a bare row["email"] tests for presence
+
+type literalNode string
+
+func (n literalNode) eval([]string) (string, bool) {
+	return string(n), n != ""
+}
+
+// columnNode reads the field at index, which is -1 until the filter is bound
+type columnNode struct {
+	name  string
+	index int
+}
+
+func (n columnNode) eval(row []string) (string, bool) {
+	value := ""
+	if n.index >= 0 {
+		value = strings.TrimSpace(field(row, n.index))
+	}
+	return value, value != ""
+}
+
+type notNode struct {
+	operand filterNode
+}
+
+func (n notNode) eval(row []string) (string, bool) {
+	_, ok := n.operand.eval(row)
+	return boolResult(!ok)
+}
+
+type logicalNode struct {
+	or          bool
+	left, right filterNode
+}
+
+func (n logicalNode) eval(row []string) (string, bool) {
+	_, left := n.left.eval(row)
+	if n.or && left {
+		return boolResult(true)
+	}
+	if !n.or && !left {
+		return boolResult(false)
+	}
+
+	_, right := n.right.eval(row)
+	return boolResult(right)
+}
+
+type comparisonNode struct {
+	op          string
+	left, right filterNode
+}
+
+func (n comparisonNode) eval(row []string) (string, bool) {
+	left, _ := n.left.eval(row)
+	right, _ := n.right.eval(row)
+	c := compareFilterValues(left, right)
+
+	switch n.op {
+	case "==":
+		return boolResult(c == 0)
+	case "!=":
+		return boolResult(c != 0)
+	case "<":
+		return boolResult(c < 0)
+	case "<=":
+		return boolResult(c <= 0)
+	case ">":
+		return boolResult(c > 0)
+	default:
+		return boolResult(c >= 0)
+	}
+}
+
+// compareFilterValues compares numerically when both values are numbers,
+// otherwise as strings. Unlike compareValues it does not order numbers before
+// text, so an empty amount stays less than 100 as the filter doc says.
+func compareFilterValues(a, b string) int {
+	fa, aErr := parseNumber(a)
+	fb, bErr := parseNumber(b)
+	if aErr != nil || bErr != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+func boolResult(ok bool) (string, bool) {
+	if ok {
+		return "true", true
+	}
+
+	return "", false
+}
@@ -0,0 +1,45 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/ashr-tech/csv-migration-tools/transform"
+)
+
+// TestFilterComparisons checks that comparisons are numeric only when both
+// sides are numbers, so empty and non-numeric values compare as text
+func TestFilterComparisons(t *testing.T) {
+	tests := []struct {
+		expression string
+		amount     string
+		want       bool
+	}{
+		{`row["amount"] > 100`, "250", true},
+		{`row["amount"] > 100`, "99.5", false},
+		{`row["amount"] > 100`, "1e3", true},
+		{`row["amount"] == 100`, "100.0", true},
+		{`row["amount"] > 100`, "", false},
+		{`row["amount"] < 100`, "", true},
+		{`row["amount"] == ""`, "", true},
+		{`row["amount"] != 0`, "", true},
+		{`row["amount"] > 100`, "n/a", true},
+		{`row["amount"] < 100`, "n/a", false},
+		{`row["amount"] == "n/a"`, "n/a", true},
+		{`row["amount"] < 2`, "10", false},
+		{`row["amount"] < "2"`, "10", false},
+		{`row["amount"] < "b"`, "a", true},
+		{`row["amount"] == 0`, "NaN", false},
+	}
+	for _, tt := range tests {
+		filter, err := transform.CompileFilter(tt.expression)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expression, err)
+		}
+		if filter, err = filter.Bind(map[string]int{"amount": 0}); err != nil {
+			t.Fatalf("%s: %v", tt.expression, err)
+		}
+		if got := filter.Match([]string{tt.amount}); got != tt.want {
+			t.Errorf("%s with amount %q: got %v, want %v", tt.expression, tt.amount, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// RenameHeader rewrites the header row (records[0]) in place. Columns listed
+// in renames get their new name as-is; the others are normalized to style:
+// "snake" (snake_case), "lower", "upper" or "" (only trimmed). Every style
+// strips a byte order mark and surrounding whitespace.
+func RenameHeader(records [][]string, style string, renames map[string]string) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	header := records[0]
+	for i, name := range header {
+		if renamed, exists := renames[name]; exists {
+			header[i] = renamed
+			continue
+		}
+
+		normalized, err := NormalizeHeader(name, style)
+		if err != nil {
+			return err
+		}
+		header[i] = normalized
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range header {
+		if seen[name] {
+			return fmt.Errorf("duplicate column %q after renaming the header", name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+// NormalizeHeader formats one header name in the given style
+func NormalizeHeader(name, style string) (string, error) {
+	name = strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))
+
+	switch style {
+	case "":
+		return name, nil
+	case "lower":
+		return strings.ToLower(name), nil
+	case "upper":
+		return strings.ToUpper(name), nil
+	case "snake":
+		return snakeCase(name), nil
+	}
+
+	return "", fmt.Errorf("unknown header style %q", style)
+}
+
+// snakeCase lowercases name and separates words with underscores. Words are
+// split at non-alphanumeric characters and at lower-to-upper case changes,
+// so "Customer ID", "customerId" and "customer-id" all become customer_id.
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	pendingSeparator := false
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingSeparator = b.Len() > 0
+			continue
+		}
+
+		if unicode.IsUpper(r) && i > 0 && b.Len() > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				pendingSeparator = true
+			}
+		}
+
+		if pendingSeparator {
+			b.WriteRune('_')
+			pendingSeparator = false
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package transform
+
+import (
+	"strconv"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// GenerateKeys replaces the values of column with sequential IDs starting at
+// start and returns the crosswalk from the previous value to the new ID.
+func GenerateKeys(records [][]string, column string, start int) (map[string]string, error) {
+	crosswalk := make(map[string]string)
+	if len(records) == 0 {
+		return crosswalk, nil
+	}
+
+	idx, err := columnIndexes(records[0], []string{column})
+	if err != nil {
+		return nil, err
+	}
+
+	for rowIdx := 1; rowIdx < len(records); rowIdx++ {
+		row := records[rowIdx]
+		newID := strconv.Itoa(start + rowIdx - 1)
+
+		if oldID := field(row, idx[0]); oldID != "" {
+			if _, exists := crosswalk[oldID]; !exists {
+				crosswalk[oldID] = newID
+			}
+		}
+		if idx[0] < len(row) {
+			row[idx[0]] = newID
+		}
+	}
+
+	return crosswalk, nil
+}
+
+// Remap rewrites the values of column through crosswalk, leaving values
+// without an entry unchanged. It returns the number of values left unchanged.
+func Remap(records [][]string, column string, crosswalk map[string]string) (int, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	idx, err := columnIndexes(records[0], []string{column})
+	if err != nil {
+		return 0, err
+	}
+
+	missing := 0
+	for _, row := range records[1:] {
+		value := field(row, idx[0])
+		if value == "" {
+			continue
+		}
+		if newID, exists := crosswalk[value]; exists {
+			row[idx[0]] = newID
+		} else {
+			missing++
+		}
+	}
+
+	return missing, nil
+}
+
+// KeySet returns the non-empty values of column, the keys other tables may
+// reference
+func KeySet(records [][]string, column string) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	if len(records) == 0 {
+		return keys, nil
+	}
+
+	idx, err := columnIndexes(records[0], []string{column})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range records[1:] {
+		if value := field(row, idx[0]); value != "" {
+			keys[value] = true
+		}
+	}
+	return keys, nil
+}
+
+// Orphans counts the non-empty values of column missing from keys, and
+// returns the first examples of them with their row, counting the header as
+// row 1.
+func Orphans(records [][]string, column string, keys map[string]bool, examples int) (int, []types.AnomalyExample, error) {
+	if len(records) == 0 {
+		return 0, nil, nil
+	}
+
+	idx, err := columnIndexes(records[0], []string{column})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	orphans := 0
+	var found []types.AnomalyExample
+	for rowIdx, row := range records[1:] {
+		value := field(row, idx[0])
+		if value == "" || keys[value] {
+			continue
+		}
+		orphans++
+		if len(found) < examples {
+			found = append(found, types.AnomalyExample{Row: rowIdx + 2, Value: value})
+		}
+	}
+	return orphans, found, nil
+}
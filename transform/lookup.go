@@ -0,0 +1,47 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// lookupCache holds the tables indexed by LoadLookup
+var lookupCache utils.FileCache[map[string]string]
+
+// LoadLookup reads a reference CSV and indexes the valueColumn by keyColumn.
+// When a key repeats, the first row wins. Tables of local files are indexed
+// once and shared, so they must not be changed.
+func LoadLookup(path, keyColumn, valueColumn string) (map[string]string, error) {
+	return lookupCache.Get(path, keyColumn+"\x00"+valueColumn, func() (map[string]string, error) {
+		return loadLookup(path, keyColumn, valueColumn)
+	})
+}
+
+func loadLookup(path, keyColumn, valueColumn string) (map[string]string, error) {
+	records, err := utils.ReadCSVFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		header[i] = strings.TrimSpace(name)
+	}
+
+	idx, err := columnIndexes(header, []string{keyColumn, valueColumn})
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %v", path, err)
+	}
+
+	table := make(map[string]string, len(records)-1)
+	for _, row := range records[1:] {
+		key := strings.TrimSpace(field(row, idx[0]))
+		if _, exists := table[key]; !exists {
+			table[key] = strings.TrimSpace(field(row, idx[1]))
+		}
+	}
+
+	return table, nil
+}
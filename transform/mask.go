@@ -0,0 +1,68 @@
+package transform
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+var (
+	fakeFirstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn", "Rowan", "Sky"}
+	fakeLastNames  = []string{"Smith", "Tanaka", "Santoso", "Garcia", "Müller", "Kim", "Rossi", "Novak", "Silva", "Haddad", "Okafor", "Larsen"}
+	fakeWords      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor"}
+)
+
+// Mask anonymizes value according to rule. Hashes and fake values are derived
+// from an HMAC of the value with salt, so the same input always gets the same
+// replacement and masked columns can still be joined across files.
+func Mask(value string, rule types.MaskRule, salt string) (string, error) {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	digest := mac.Sum(nil)
+
+	switch rule.Method {
+	case "hash":
+		return hex.EncodeToString(digest), nil
+
+	case "partial":
+		char := rule.Char
+		if char == "" {
+			char = "*"
+		}
+		runes := []rune(value)
+		masked := make([]string, len(runes))
+		for i, r := range runes {
+			if i < rule.KeepStart || i >= len(runes)-rule.KeepEnd {
+				masked[i] = string(r)
+			} else {
+				masked[i] = char
+			}
+		}
+		return strings.Join(masked, ""), nil
+
+	case "fake":
+		n := binary.BigEndian.Uint64(digest[:8])
+		pick := func(list []string, shift uint) string {
+			return list[(n>>shift)%uint64(len(list))]
+		}
+
+		switch rule.Kind {
+		case "name":
+			return pick(fakeFirstNames, 0) + " " + pick(fakeLastNames, 16), nil
+		case "email":
+			return fmt.Sprintf("user_%s@example.com", hex.EncodeToString(digest[:4])), nil
+		case "phone":
+			return fmt.Sprintf("+1555%07d", n%10000000), nil
+		case "text", "":
+			return pick(fakeWords, 0) + " " + pick(fakeWords, 8) + " " + pick(fakeWords, 16), nil
+		}
+		return "", fmt.Errorf("unknown fake kind %q", rule.Kind)
+	}
+
+	return "", fmt.Errorf("unknown mask method %q", rule.Method)
+}
@@ -0,0 +1,182 @@
+package transform
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CleanNumber strips currency symbols, thousands separators and whitespace
+// from value ("Rp 1.250.000", "$1,299.99", "(12.50)") and returns a plain
+// number with "." as decimal separator. When decimalSeparator is empty it is
+// guessed: with both "." and "," present the last one is decimal, repeated
+// dots are thousands separators, a single comma is decimal unless followed by
+// exactly three digits, otherwise "." is decimal.
+func CleanNumber(value, decimalSeparator string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+
+	// The number runs from the first to the last digit; anything around it
+	// (currency codes and symbols) is dropped
+	first := strings.IndexFunc(value, unicode.IsDigit)
+	last := strings.LastIndexFunc(value, unicode.IsDigit)
+	if first == -1 {
+		return "", fmt.Errorf("invalid number %q", value)
+	}
+	prefix, core, suffix := value[:first], value[first:last+1], value[last+1:]
+	if strings.Contains(prefix, "-") || strings.TrimSpace(suffix) == "-" {
+		negative = true
+	}
+	if strings.HasSuffix(prefix, ".") || strings.HasSuffix(prefix, ",") {
+		// Leading decimal separator, e.g. ".5"
+		core = prefix[len(prefix)-1:] + core
+	}
+
+	var digits strings.Builder
+	for _, r := range core {
+		switch {
+		case unicode.IsDigit(r), r == '.', r == ',':
+			digits.WriteRune(r)
+		case unicode.IsSpace(r), r == '\'', r == ' ', r == ' ':
+			// Thousands grouping with spaces or apostrophes
+		default:
+			return "", fmt.Errorf("invalid number %q", value)
+		}
+	}
+	number := digits.String()
+
+	if decimalSeparator == "" {
+		decimalSeparator = guessDecimalSeparator(number)
+	}
+	thousandsSeparator := ","
+	if decimalSeparator == "," {
+		thousandsSeparator = "."
+	}
+
+	number = strings.ReplaceAll(number, thousandsSeparator, "")
+	if strings.Count(number, decimalSeparator) > 1 {
+		return "", fmt.Errorf("invalid number %q", value)
+	}
+	number = strings.Replace(number, decimalSeparator, ".", 1)
+	if strings.HasPrefix(number, ".") {
+		number = "0" + number
+	}
+
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return "", fmt.Errorf("invalid number %q", value)
+	}
+
+	if negative {
+		number = "-" + number
+	}
+
+	return number, nil
+}
+
+func guessDecimalSeparator(number string) string {
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+
+	switch {
+	case lastDot != -1 && lastComma != -1:
+		if lastComma > lastDot {
+			return ","
+		}
+		return "."
+	case strings.Count(number, ".") > 1:
+		return ","
+	case lastComma != -1 && strings.Count(number, ",") == 1 && len(number)-lastComma-1 != 3:
+		// A single comma not followed by a group of three digits, e.g. "12,5"
+		return ","
+	default:
+		return "."
+	}
+}
+
+// CleanInteger is CleanNumber for whole numbers; a zero fraction is dropped
+func CleanInteger(value, decimalSeparator string) (string, error) {
+	number, err := CleanNumber(value, decimalSeparator)
+	if err != nil {
+		return "", err
+	}
+
+	whole, fraction, found := strings.Cut(number, ".")
+	if found && strings.Trim(fraction, "0") != "" {
+		return "", fmt.Errorf("invalid integer %q", value)
+	}
+
+	return whole, nil
+}
+
+// ScaleNumber multiplies a plain number by factor exactly, e.g. cents to
+// units with factor 0.01
+func ScaleNumber(number string, factor float64) (string, error) {
+	x, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return "", fmt.Errorf("invalid number %q", number)
+	}
+	f, _ := new(big.Rat).SetString(strconv.FormatFloat(factor, 'f', -1, 64))
+	x.Mul(x, f)
+
+	// Both operands are finite decimals, so the product is too
+	scaled := strings.TrimRight(x.FloatString(30), "0")
+	return strings.TrimSuffix(scaled, "."), nil
+}
+
+// RoundNumber rounds a plain number to decimals places and always writes that
+// many. Modes are half_up (the default, ties away from zero), half_even,
+// down (towards zero), up (away from zero), floor and ceiling.
+func RoundNumber(number string, decimals int, mode string) (string, error) {
+	if !slices.Contains([]string{"", "half_up", "half_even", "down", "up", "floor", "ceiling"}, mode) {
+		return "", fmt.Errorf("unknown rounding mode %q", mode)
+	}
+
+	x, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return "", fmt.Errorf("invalid number %q", number)
+	}
+
+	// Split x * 10^decimals into a whole part (truncated) and a remainder
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	x.Mul(x, new(big.Rat).SetInt(pow))
+	whole, rem := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+
+	if rem.Sign() != 0 {
+		negative := x.Sign() < 0
+		// Compare twice the remainder with the denominator to find ties
+		half := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(x.Denom())
+
+		away := false
+		switch mode {
+		case "", "half_up":
+			away = half >= 0
+		case "half_even":
+			away = half > 0 || (half == 0 && whole.Bit(0) == 1)
+		case "down":
+		case "up":
+			away = true
+		case "floor":
+			away = negative
+		case "ceiling":
+			away = !negative
+		}
+
+		if away {
+			if negative {
+				whole.Sub(whole, big.NewInt(1))
+			} else {
+				whole.Add(whole, big.NewInt(1))
+			}
+		}
+	}
+
+	return new(big.Rat).SetFrac(whole, pow).FloatString(decimals), nil
+}
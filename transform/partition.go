@@ -0,0 +1,43 @@
+package transform
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Partition splits the data rows of records (the first row is the header)
+// into n record sets by a hash of their values in keyColumns, so that rows
+// sharing a key always land in the same set, whatever the other rows are.
+// Every set starts with the header and keeps its rows in their original order.
+func Partition(records [][]string, keyColumns []string, n int) ([][][]string, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of partitions %d", n)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no header to partition by")
+	}
+
+	keyIdx, err := columnIndexes(records[0], keyColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make([][][]string, n)
+	for i := range partitions {
+		partitions[i] = [][]string{records[0]}
+	}
+	for _, row := range records[1:] {
+		i := partitionOf(rowKey(row, keyIdx), n)
+		partitions[i] = append(partitions[i], row)
+	}
+
+	return partitions, nil
+}
+
+// partitionOf hashes key with FNV-1a, which unlike maphash gives the same
+// partition in every run
+func partitionOf(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,63 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// NormalizePhone converts a phone number such as "0812-3456-789" or
+// "(021) 555 0199" into E.164 ("+628123456789"). Numbers without an
+// international prefix ("+" or "00") get countryCode, replacing a leading
+// trunk "0". Extensions are dropped.
+func NormalizePhone(value, countryCode string) (string, error) {
+	number := strings.TrimSpace(value)
+	countryCode = strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+
+	// Drop extensions like "x123" or "ext. 123"
+	lower := strings.ToLower(number)
+	for _, marker := range []string{"ext", "x", "#"} {
+		if i := strings.Index(lower, marker); i > 0 {
+			number, lower = number[:i], lower[:i]
+		}
+	}
+
+	international := strings.HasPrefix(number, "+")
+
+	var digits strings.Builder
+	for _, r := range number {
+		switch {
+		case unicode.IsDigit(r):
+			digits.WriteRune(r)
+		case r == '+' || r == '-' || r == '.' || r == '(' || r == ')' || r == '/' || unicode.IsSpace(r):
+		default:
+			return "", fmt.Errorf("invalid phone number %q", value)
+		}
+	}
+	national := digits.String()
+
+	switch {
+	case international:
+	case strings.HasPrefix(national, "00"):
+		national = national[2:]
+	case strings.HasPrefix(national, "0"):
+		if countryCode == "" {
+			return "", fmt.Errorf("phone number %q has no country code", value)
+		}
+		national = countryCode + strings.TrimPrefix(national, "0")
+	case countryCode != "" && strings.HasPrefix(national, countryCode):
+		// Already includes the country code without "+"
+	default:
+		if countryCode == "" {
+			return "", fmt.Errorf("phone number %q has no country code", value)
+		}
+		national = countryCode + national
+	}
+
+	// E.164 allows at most 15 digits
+	if len(national) < 8 || len(national) > 15 {
+		return "", fmt.Errorf("invalid phone number %q", value)
+	}
+
+	return "+" + national, nil
+}
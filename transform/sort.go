@@ -0,0 +1,119 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// ParseSortKeys parses a sort specification like "created_at:asc,id:desc".
+// The direction defaults to ascending when omitted.
+func ParseSortKeys(spec string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		column, direction, _ := strings.Cut(part, ":")
+		key := SortKey{Column: strings.TrimSpace(column)}
+		switch strings.ToLower(strings.TrimSpace(direction)) {
+		case "", "asc":
+		case "desc":
+			key.Desc = true
+		default:
+			return nil, fmt.Errorf("invalid sort direction %q for column %q", direction, key.Column)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Sort orders the data rows of records (the first row is the header) by keys.
+// Rows with equal keys keep their original order.
+func Sort(records [][]string, keys []SortKey) error {
+	if len(records) < 2 {
+		return nil
+	}
+
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = key.Column
+	}
+	keyIdx, err := columnIndexes(records[0], columns)
+	if err != nil {
+		return err
+	}
+
+	rows := records[1:]
+	sort.SliceStable(rows, func(a, b int) bool {
+		return compareRows(rows[a], rows[b], keys, keyIdx) < 0
+	})
+
+	return nil
+}
+
+func compareRows(a, b []string, keys []SortKey, keyIdx []int) int {
+	for i, idx := range keyIdx {
+		c := compareValues(field(a, idx), field(b, idx))
+		if keys[i].Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// compareValues compares numerically when both values are numbers, otherwise
+// as strings. Numbers sort before text, so a column mixing both is still
+// ordered consistently: 9 < 10 < "1a" < "b".
+func compareValues(a, b string) int {
+	fa, aErr := parseNumber(a)
+	fb, bErr := parseNumber(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// parseNumber parses a value compared as a number. NaN compares as text, as it
+// is unordered among numbers.
+func parseNumber(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err == nil && math.IsNaN(f) {
+		return 0, strconv.ErrSyntax
+	}
+	return f, err
+}
+
+func field(row []string, idx int) string {
+	if idx < len(row) {
+		return row[idx]
+	}
+
+	return ""
+}
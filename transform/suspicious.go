@@ -0,0 +1,42 @@
+package transform
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// quantityWords are the words of column names that count things, whose
+// values should not be negative
+var quantityWords = map[string]bool{
+	"qty": true, "quantity": true, "quantities": true, "stock": true, "count": true,
+	"units": true, "pieces": true, "pcs": true, "onhand": true, "inventory": true,
+}
+
+// SentinelDate reports whether t lies in the year 1900 or before, or in 2099
+// or after: placeholders that exports write for unknown or open-ended dates,
+// which the target usually rejects or misreads
+func SentinelDate(t time.Time) bool {
+	return t.Year() <= 1900 || t.Year() >= 2099
+}
+
+// QuantityColumn reports whether a column name, in any case style, holds a
+// word that counts things, like stock_quantity or qtyAvailable
+func QuantityColumn(name string) bool {
+	var word []rune
+	previous := ' '
+	for _, r := range name + " " {
+		boundary := !unicode.IsLetter(r) || (unicode.IsUpper(r) && unicode.IsLower(previous))
+		if boundary && len(word) > 0 {
+			if quantityWords[strings.ToLower(string(word))] {
+				return true
+			}
+			word = word[:0]
+		}
+		if unicode.IsLetter(r) {
+			word = append(word, r)
+		}
+		previous = r
+	}
+	return false
+}
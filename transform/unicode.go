@@ -0,0 +1,51 @@
+package transform
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeText applies Unicode NFC normalization, turns non-breaking spaces
+// into regular spaces, and strips zero-width and control characters (except
+// tabs and newlines) that break exact matching
+func NormalizeText(value string) string {
+	value = norm.NFC.String(value)
+
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\u00A0', '\u2007', '\u202F': // non-breaking spaces
+			return ' '
+		case '\u200B', '\u200C', '\u200D', '\u2060', '\uFEFF', '\u00AD': // zero-width characters and soft hyphen
+			return -1
+		case '\t', '\n':
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// LooseKey folds value for loose matching: lower case, with runs of
+// whitespace collapsed into one space and none at the ends. Values already
+// in that form, as most mapped codes are, are returned without allocating.
+func LooseKey(value string) string {
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		switch {
+		case b >= 0x80, b >= 'A' && b <= 'Z', b == '\t', b == '\n', b == '\v', b == '\f', b == '\r':
+			return foldLoose(value)
+		case b == ' ' && (i == 0 || i == len(value)-1 || value[i+1] == ' '):
+			return foldLoose(value)
+		}
+	}
+
+	return value
+}
+
+func foldLoose(value string) string {
+	return strings.Join(strings.Fields(strings.ToLower(value)), " ")
+}
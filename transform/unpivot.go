@@ -0,0 +1,55 @@
+package transform
+
+import (
+	"fmt"
+	"slices"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// Unpivot turns every data row into one row per unpivoted column. The other
+// columns are repeated on each row, followed by the key and value columns.
+func Unpivot(records [][]string, spec types.Unpivot) ([][]string, error) {
+	if len(records) == 0 {
+		return records, nil
+	}
+	if len(spec.Columns) == 0 || spec.KeyColumn == "" || spec.ValueColumn == "" {
+		return nil, fmt.Errorf("unpivot needs columns, key_column and value_column")
+	}
+
+	header := records[0]
+	meltIdx, err := columnIndexes(header, spec.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	var keepIdx []int
+	for i := range header {
+		if !slices.Contains(meltIdx, i) {
+			keepIdx = append(keepIdx, i)
+		}
+	}
+
+	outHeader := make([]string, 0, len(keepIdx)+2)
+	for _, i := range keepIdx {
+		outHeader = append(outHeader, header[i])
+	}
+	outHeader = append(outHeader, spec.KeyColumn, spec.ValueColumn)
+
+	result := [][]string{outHeader}
+	for _, row := range records[1:] {
+		for _, m := range meltIdx {
+			value := field(row, m)
+			if spec.SkipEmpty && value == "" {
+				continue
+			}
+			outRow := make([]string, 0, len(outHeader))
+			for _, i := range keepIdx {
+				outRow = append(outRow, field(row, i))
+			}
+			result = append(result, append(outRow, header[m], value))
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,24 @@
+package types
+
+// AuditManifest lists the AI requests of a run, whose prompts and responses
+// are kept as files next to it
+type AuditManifest struct {
+	Command   []string `json:"command"`
+	StartedAt string   `json:"started_at"`
+	// Redacted reports whether personal data and secrets were hidden from the
+	// files
+	Redacted  bool         `json:"redacted"`
+	Exchanges []AIExchange `json:"exchanges"`
+}
+
+// AIExchange is one AI request of a run. Prompt and Response are file names
+// relative to the manifest; Response is empty when the request failed.
+type AIExchange struct {
+	Mode       string `json:"mode"`
+	Model      string `json:"model"`
+	SentAt     string `json:"sent_at"`
+	ReceivedAt string `json:"received_at,omitempty"`
+	Prompt     string `json:"prompt"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,37 @@
+package types
+
+// CoverageReport tells how completely a source schema maps onto its target
+// schema
+type CoverageReport struct {
+	SourceSchema string `json:"source_schema"`
+	TargetSchema string `json:"target_schema"`
+	Complete     bool   `json:"complete"`
+	// UnmappedTargets are target columns no source column maps to
+	UnmappedTargets []string `json:"unmapped_targets,omitempty"`
+	// UnusedSources are source columns mapped to no target column, and
+	// UnknownTargets the target columns of source columns that the target
+	// schema lacks
+	UnusedSources  []string `json:"unused_sources,omitempty"`
+	UnknownTargets []string `json:"unknown_targets,omitempty"`
+	// Values cover the categorical target columns
+	Values []ValueCoverage `json:"values"`
+}
+
+// ValueCoverage tells which values of a categorical target column the
+// mappings of its source columns produce
+type ValueCoverage struct {
+	Column        string   `json:"column"`
+	SourceColumns []string `json:"source_columns,omitempty"`
+	Values        int      `json:"values"`
+	Covered       int      `json:"covered"`
+	// Lookup is set when a source column resolves values through a lookup
+	// file, whose values the schemas do not show
+	Lookup bool `json:"lookup,omitempty"`
+	// Uncovered are target values no mapping produces
+	Uncovered []string `json:"uncovered,omitempty"`
+	// UnmappedValues are source values without a mapping, which pass through
+	// unchanged although they are no target value
+	UnmappedValues []string `json:"unmapped_values,omitempty"`
+	// InvalidTargets are mapped values that are no target value
+	InvalidTargets []string `json:"invalid_targets,omitempty"`
+}
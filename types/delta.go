@@ -0,0 +1,8 @@
+package types
+
+// DeltaState remembers the hash of every converted row by key, so later runs
+// can tell new and changed rows apart from unchanged ones
+type DeltaState struct {
+	KeyColumns []string          `json:"key_columns"`
+	Rows       map[string]string `json:"rows"`
+}
@@ -0,0 +1,35 @@
+package types
+
+// DuplicateReport lists the repeated records of a source file: rows equal to
+// an earlier row, and rows sharing the key of an earlier row while differing
+// from it in other columns
+type DuplicateReport struct {
+	Source string   `json:"source"`
+	Rows   int      `json:"rows"`
+	Key    []string `json:"key,omitempty"`
+	// ExactRows counts the rows equal to an earlier row in every column
+	ExactRows int              `json:"exact_rows"`
+	Exact     []DuplicateGroup `json:"exact,omitempty"`
+	// NearKeys counts the keys of rows that differ in other columns, and
+	// NearRows those rows beyond the first of each key
+	NearKeys int              `json:"near_keys,omitempty"`
+	NearRows int              `json:"near_rows,omitempty"`
+	Near     []DuplicateGroup `json:"near,omitempty"`
+	// DifferingColumns count the near duplicates differing from the first
+	// row of their key in each column, most often differing first
+	DifferingColumns []ColumnCount `json:"differing_columns,omitempty"`
+}
+
+// DuplicateGroup is a repeated row or key and the first rows holding it,
+// counting the header as row 1. Columns are the columns the rows of a near
+// duplicate differ in.
+type DuplicateGroup struct {
+	Key     string   `json:"key,omitempty"`
+	Rows    []int    `json:"rows"`
+	Columns []string `json:"columns,omitempty"`
+}
+
+type ColumnCount struct {
+	Column string `json:"column"`
+	Count  int    `json:"count"`
+}
@@ -0,0 +1,42 @@
+package types
+
+// EquivalenceReport compares converted rows cell by cell with a known-good
+// target sample
+type EquivalenceReport struct {
+	Expected string `json:"expected"`
+	// Key are the columns matching converted rows to expected rows; rows are
+	// matched by position when there is none
+	Key    []string `json:"key,omitempty"`
+	Rows   int      `json:"rows"`
+	Cells  int      `json:"cells"`
+	Passed bool     `json:"passed"`
+	// MissingColumns are expected columns the converted rows lack, and
+	// ExtraColumns converted columns the sample lacks
+	MissingColumns []string `json:"missing_columns,omitempty"`
+	ExtraColumns   []string `json:"extra_columns,omitempty"`
+	// MissingRows counts expected rows without a converted row, and
+	// ExtraRows converted rows without an expected row; their keys list the
+	// first of them when matching by key
+	MissingRows int                 `json:"missing_rows,omitempty"`
+	MissingKeys []string            `json:"missing_keys,omitempty"`
+	ExtraRows   int                 `json:"extra_rows,omitempty"`
+	ExtraKeys   []string            `json:"extra_keys,omitempty"`
+	Columns     []ColumnEquivalence `json:"columns"`
+}
+
+// ColumnEquivalence counts the cells of a column that differ from the sample,
+// with the first of them
+type ColumnEquivalence struct {
+	Column     string         `json:"column"`
+	Mismatches int            `json:"mismatches"`
+	Examples   []CellMismatch `json:"examples,omitempty"`
+}
+
+// CellMismatch is a converted value that differs from the expected one. Row
+// is the row of the expected sample, counting the header as row 1.
+type CellMismatch struct {
+	Row      int    `json:"row"`
+	Key      string `json:"key,omitempty"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
@@ -0,0 +1,56 @@
+package types
+
+// EvaluationCase is a pair of samples with the schemas that generating from
+// them should give, reviewed by hand
+type EvaluationCase struct {
+	Name         string `json:"name"`
+	SourceSample string `json:"source_sample"`
+	TargetSample string `json:"target_sample"`
+	SourceSchema string `json:"source_schema"`
+	TargetSchema string `json:"target_schema"`
+}
+
+// ModelEvaluation scores the schemas a model generated for every case of an
+// evaluation corpus
+type ModelEvaluation struct {
+	Mode  string `json:"mode"`
+	Model string `json:"model"`
+	// Failed counts the cases whose generation failed, which score nothing
+	Failed int           `json:"failed,omitempty"`
+	Total  SchemaScore   `json:"total"`
+	Cases  []SchemaScore `json:"cases"`
+}
+
+// SchemaScore counts the parts of generated schemas that agree with the
+// reviewed ones
+type SchemaScore struct {
+	Case  string `json:"case,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Categories are the target columns classified as categorical or dynamic
+	// like the reviewed target schema
+	Categories Score `json:"categories"`
+	// Columns are the target columns mapped from the reviewed source column
+	Columns Score `json:"columns"`
+	// Values are the source values of the reviewed values_mapping mapped to
+	// the reviewed target value
+	Values   Score           `json:"values"`
+	Mistakes []SchemaMistake `json:"mistakes,omitempty"`
+}
+
+// Score counts the correct answers of a kind
+type Score struct {
+	Correct  int     `json:"correct"`
+	Total    int     `json:"total"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// SchemaMistake is a part of a generated schema that differs from the
+// reviewed one. Kind is "category", "column" or "value"; Value is the source
+// value of a "value" mistake.
+type SchemaMistake struct {
+	Kind         string `json:"kind"`
+	TargetColumn string `json:"target_column"`
+	Value        string `json:"value,omitempty"`
+	Expected     string `json:"expected"`
+	Actual       string `json:"actual"`
+}
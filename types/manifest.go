@@ -0,0 +1,61 @@
+package types
+
+type ProcessedFile struct {
+	SHA256      string `json:"sha256"`
+	Output      string `json:"output"`
+	ProcessedAt string `json:"processed_at"`
+}
+
+// ProcessedManifest tracks the input files converted by batch runs, keyed by path
+type ProcessedManifest struct {
+	Files map[string]ProcessedFile `json:"files"`
+}
+
+// RunManifest lists the files a run read and wrote with their SHA-256, so
+// that its outputs can be checked against it later
+type RunManifest struct {
+	Tool       string         `json:"tool"`
+	Command    []string       `json:"command"`
+	StartedAt  string         `json:"started_at"`
+	FinishedAt string         `json:"finished_at"`
+	Inputs     []FileChecksum `json:"inputs"`
+	Schemas    []FileChecksum `json:"schemas"`
+	Outputs    []FileChecksum `json:"outputs"`
+	// AIAudit is the manifest of the prompts and responses of the run, when
+	// they were saved with --audit-dir
+	AIAudit *FileChecksum `json:"ai_audit,omitempty"`
+	// Signature is the SHA-256 of the manifest without the signature, or its
+	// HMAC-SHA256 when a signing key was set, as named by SignatureAlgorithm
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	Signature          string `json:"signature"`
+}
+
+// FileChecksum is a file of a run. Standard input and database queries have
+// no checksum, and Unchecked says why.
+type FileChecksum struct {
+	Path      string `json:"path"`
+	SHA256    string `json:"sha256,omitempty"`
+	Unchecked string `json:"unchecked,omitempty"`
+}
+
+// ManifestVerification compares the files of a run manifest with their
+// current contents
+type ManifestVerification struct {
+	Manifest string `json:"manifest"`
+	// Signature is "valid", "invalid", or "unverified" for an HMAC signature
+	// checked without the key
+	Signature string             `json:"signature"`
+	Files     []FileVerification `json:"files"`
+	// Failed counts the files changed or missing since the run
+	Failed int `json:"failed"`
+}
+
+// FileVerification is the state of a file of a run manifest: "ok",
+// "changed", "missing" or "unchecked"
+type FileVerification struct {
+	Role     string `json:"role"`
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
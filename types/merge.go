@@ -0,0 +1,8 @@
+package types
+
+type MergeSource struct {
+	SourceData   string `json:"source_data"`
+	SourceSchema string `json:"source_schema"`
+	// Query is run when SourceData is a database URL
+	Query string `json:"query,omitempty"`
+}
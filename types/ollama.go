@@ -26,3 +26,10 @@ type OllamaCloudResponse struct {
 		Content string `json:"content"`
 	} `json:"message"`
 }
+
+// OllamaModels lists the models an Ollama service offers
+type OllamaModels struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
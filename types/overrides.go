@@ -0,0 +1,11 @@
+package types
+
+// SchemaOverrides adjusts a source schema for a single run without editing
+// the schema file. Both maps are keyed by source column.
+type SchemaOverrides struct {
+	// ValuesMapping adds or replaces values_mapping entries
+	ValuesMapping map[string]map[string]string `json:"values_mapping,omitempty"`
+	// TargetColumns forces the target column a source column is written to;
+	// an empty name leaves the source column unused
+	TargetColumns map[string]string `json:"target_columns,omitempty"`
+}
@@ -0,0 +1,21 @@
+package types
+
+// PreflightReport gathers the checks made before a migration run. Passed is
+// false when any check found a blocker.
+type PreflightReport struct {
+	Passed   bool             `json:"passed"`
+	Blockers int              `json:"blockers"`
+	Warnings int              `json:"warnings"`
+	Checks   []PreflightCheck `json:"checks"`
+}
+
+// PreflightCheck is one check of a pre-flight. Status is "ok", "warning",
+// "blocker", or "skipped" when the run does not need it or an earlier check
+// failed; Note tells what was checked or why it was skipped.
+type PreflightCheck struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Note     string   `json:"note,omitempty"`
+	Blockers []string `json:"blockers,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
@@ -0,0 +1,65 @@
+package types
+
+// Profile describes the columns of a source file over all of its rows
+type Profile struct {
+	Source  string          `json:"source"`
+	Rows    int             `json:"rows"`
+	Columns []ColumnProfile `json:"columns"`
+}
+
+// ColumnProfile holds the statistics of one column of a profiled file
+type ColumnProfile struct {
+	Column string `json:"column"`
+	// Type is inferred from the values: boolean, integer, number, date,
+	// datetime or text, or empty when the column holds no values
+	Type string `json:"type"`
+	// Format is the detected format of date and datetime columns
+	Format string `json:"format,omitempty"`
+	// Nulls counts empty values
+	Nulls    int `json:"nulls"`
+	Distinct int `json:"distinct"`
+	// DistinctCapped reports that distinct values stopped being counted at
+	// the limit, so Distinct is a lower bound and TopValues approximate
+	DistinctCapped bool         `json:"distinct_capped,omitempty"`
+	TopValues      []ValueCount `json:"top_values,omitempty"`
+	// Min and Max are compared as numbers or dates in columns of those
+	// types, as text otherwise
+	Min       string      `json:"min,omitempty"`
+	Max       string      `json:"max,omitempty"`
+	Lengths   LengthStats `json:"lengths"`
+	Anomalies []Anomaly   `json:"anomalies,omitempty"`
+}
+
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// LengthStats describe the lengths in characters of the non-empty values
+type LengthStats struct {
+	Min       int            `json:"min"`
+	Max       int            `json:"max"`
+	Mean      float64        `json:"mean"`
+	Histogram []LengthBucket `json:"histogram,omitempty"`
+}
+
+// LengthBucket counts the values with a length in Range, like "5-8"
+type LengthBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+// Anomaly is a kind of suspicious value found in a column, with the first
+// values of that kind
+type Anomaly struct {
+	Reason   string           `json:"reason"`
+	Count    int              `json:"count"`
+	Examples []AnomalyExample `json:"examples,omitempty"`
+}
+
+// AnomalyExample is a suspicious value and the row of the file holding it,
+// counting the header as row 1
+type AnomalyExample struct {
+	Row   int    `json:"row"`
+	Value string `json:"value"`
+}
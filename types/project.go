@@ -0,0 +1,29 @@
+package types
+
+type Project struct {
+	Tables []ProjectTable `json:"tables"`
+}
+
+type ProjectTable struct {
+	Name         string           `json:"name"`
+	SourceData   string           `json:"source_data"`
+	SourceSchema string           `json:"source_schema"`
+	TargetSchema string           `json:"target_schema"`
+	Query        string           `json:"query,omitempty"`
+	Key          *TableKey        `json:"key,omitempty"`
+	References   []TableReference `json:"references,omitempty"`
+}
+
+// TableKey is the primary key column of a target table. When Generate is set
+// the converter assigns new sequential IDs starting at Start (default 1).
+type TableKey struct {
+	Column   string `json:"column"`
+	Generate bool   `json:"generate,omitempty"`
+	Start    int    `json:"start,omitempty"`
+}
+
+// TableReference declares that Column holds keys of another table
+type TableReference struct {
+	Column string `json:"column"`
+	Table  string `json:"table"`
+}
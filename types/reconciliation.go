@@ -0,0 +1,37 @@
+package types
+
+// Reconciliation accounts for every source row of a conversion and compares
+// the control totals of the source with those of the output, so that a
+// migration can be signed off as losing no records or amounts. Balanced is
+// false when rows or totals do not add up.
+type Reconciliation struct {
+	Balanced bool `json:"balanced"`
+	// SourceRows are the data rows read from the sources, each of them
+	// filtered out, skipped, rejected or converted
+	SourceRows int `json:"source_rows"`
+	Filtered   int `json:"filtered"`
+	Skipped    int `json:"skipped"`
+	Rejected   int `json:"rejected"`
+	Converted  int `json:"converted"`
+	// Duplicates are converted rows removed by --dedupe-by; the others are
+	// the output rows
+	Duplicates    int            `json:"duplicates"`
+	OutputRows    int            `json:"output_rows"`
+	ControlTotals []ControlTotal `json:"control_totals,omitempty"`
+}
+
+// ControlTotal is the exact sum of a numeric target column over the output
+// rows, and of its source column over the converted source rows. Values
+// that are not numbers are counted, and left out of the sums. The totals
+// reconcile when they differ by at most Tolerance.
+type ControlTotal struct {
+	Column        string `json:"column"`
+	SourceColumn  string `json:"source_column,omitempty"`
+	Source        string `json:"source"`
+	Output        string `json:"output"`
+	Difference    string `json:"difference"`
+	Tolerance     string `json:"tolerance,omitempty"`
+	SourceInvalid int    `json:"source_invalid,omitempty"`
+	OutputInvalid int    `json:"output_invalid,omitempty"`
+	Balanced      bool   `json:"balanced"`
+}
@@ -0,0 +1,14 @@
+package types
+
+// RegressionCase is a sample conversion whose approved output must not change
+// unless the change is approved
+type RegressionCase struct {
+	Name         string `json:"name"`
+	SourceData   string `json:"source_data"`
+	SourceSchema string `json:"source_schema"`
+	TargetSchema string `json:"target_schema"`
+	// Approved is the reviewed output of the conversion
+	Approved string `json:"approved"`
+	// Key, when set, matches rows by these columns instead of by position
+	Key []string `json:"key,omitempty"`
+}
@@ -0,0 +1,24 @@
+package types
+
+// RunReport summarizes a conversion for signing it off: the files it read
+// and wrote, its statistics, how completely the schemas map, the validation
+// of its output and, when asked for, its reconciliation in Stats
+type RunReport struct {
+	Name         string         `json:"name"`
+	GeneratedAt  string         `json:"generated_at"`
+	TargetSchema string         `json:"target_schema"`
+	Sources      []ReportSource `json:"sources"`
+	Outputs      []string       `json:"outputs"`
+	// RunManifest is the --run-manifest listing the checksums of the files
+	RunManifest string            `json:"run_manifest,omitempty"`
+	Stats       *ConversionStats  `json:"stats"`
+	Validation  *ValidationReport `json:"validation,omitempty"`
+}
+
+// ReportSource is a source of a run with the coverage of the target schema
+// by its source schema
+type ReportSource struct {
+	Data     string          `json:"data"`
+	Schema   string          `json:"schema"`
+	Coverage *CoverageReport `json:"coverage"`
+}
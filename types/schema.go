@@ -1,8 +1,59 @@
 package types
 
 type ColumnSchema struct {
-	Column        string            `json:"column"`
-	TargetColumn  string            `json:"target_column,omitempty"`
-	Values        []string          `json:"values"`
-	ValuesMapping map[string]string `json:"values_mapping,omitempty"`
+	Column       string `json:"column"`
+	TargetColumn string `json:"target_column,omitempty"`
+	// Aliases are other header names of the column in source files, e.g.
+	// after an export renamed it
+	Aliases          []string  `json:"aliases,omitempty"`
+	Values           []string  `json:"values"`
+	ValuesMapping    *ValueMap `json:"values_mapping,omitempty"`
+	Required         bool      `json:"required,omitempty"`
+	Unique           bool      `json:"unique,omitempty"`
+	UniqueWith       []string  `json:"unique_with,omitempty"`
+	Type             string    `json:"type,omitempty"`
+	Format           string    `json:"format,omitempty"`
+	DecimalSeparator string    `json:"decimal_separator,omitempty"`
+	Scale            float64   `json:"scale,omitempty"`
+	Decimals         *int      `json:"decimals,omitempty"`
+	Rounding         string    `json:"rounding,omitempty"`
+	CountryCode      string    `json:"country_code,omitempty"`
+	NormalizeUnicode bool      `json:"normalize_unicode,omitempty"`
+	LooseMapping     bool      `json:"loose_mapping,omitempty"`
+	Lookup           *Lookup   `json:"lookup,omitempty"`
+	Mask             *MaskRule `json:"mask,omitempty"`
+	Rules            *Rules    `json:"rules,omitempty"`
+}
+
+// Lookup resolves a source value by joining against a reference CSV: the row
+// whose Key column equals the value provides the Value column.
+type Lookup struct {
+	File  string            `json:"file"`
+	Key   string            `json:"key"`
+	Value string            `json:"value"`
+	Table map[string]string `json:"-"`
+}
+
+// Rules are data-quality constraints on the non-empty values of a column,
+// checked by validate. Pattern is a regular expression the whole value must
+// match, Min and Max bound numbers, MaxLength counts characters, and MinDate
+// and MaxDate (YYYY-MM-DD) bound dates.
+type Rules struct {
+	Pattern   string   `json:"pattern,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	MaxLength int      `json:"max_length,omitempty"`
+	MinDate   string   `json:"min_date,omitempty"`
+	MaxDate   string   `json:"max_date,omitempty"`
+}
+
+// MaskRule anonymizes a target column: "hash" replaces values with a salted
+// hash, "partial" keeps KeepStart/KeepEnd characters and masks the rest with
+// Char, "fake" substitutes a realistic value of Kind (name, email, phone, text).
+type MaskRule struct {
+	Method    string `json:"method"`
+	KeepStart int    `json:"keep_start,omitempty"`
+	KeepEnd   int    `json:"keep_end,omitempty"`
+	Char      string `json:"char,omitempty"`
+	Kind      string `json:"kind,omitempty"`
 }
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"math/big"
+	"time"
+)
+
+type ColumnStats struct {
+	Column         string         `json:"column"`
+	SourceColumn   string         `json:"source_column,omitempty"`
+	NonEmpty       int            `json:"non_empty"`
+	Mapped         int            `json:"mapped"`
+	Unmapped       int            `json:"unmapped"`
+	UnmappedValues map[string]int `json:"unmapped_values,omitempty"`
+	Remapped       int            `json:"remapped,omitempty"`
+	MissingIDs     map[string]int `json:"missing_ids,omitempty"`
+	DetectedFormat string         `json:"detected_format,omitempty"`
+	Invalid        int            `json:"invalid,omitempty"`
+	InvalidRows    []int          `json:"invalid_rows,omitempty"`
+	// SourceTotal sums the source values of a control total column in the
+	// converted rows; SourceInvalid counts those that are not numbers
+	SourceTotal   *big.Rat `json:"-"`
+	SourceInvalid int      `json:"-"`
+}
+
+type ConversionStats struct {
+	// RowsRead counts the data rows read from the source
+	RowsRead      int `json:"rows_read"`
+	RowsProcessed int `json:"rows_processed"`
+	RowsFiltered  int `json:"rows_filtered,omitempty"`
+	RowsSkipped   int `json:"rows_skipped,omitempty"`
+	RowsRejected  int `json:"rows_rejected,omitempty"`
+	// NullSourceColumns are target columns that no source column feeds
+	NullSourceColumns []string      `json:"null_source_columns,omitempty"`
+	Columns           []ColumnStats `json:"columns"`
+	Rejects           []RejectedRow `json:"-"`
+	Timing            Timing        `json:"-"`
+	// Reconciliation is set when the conversion was reconciled
+	Reconciliation *Reconciliation `json:"reconciliation,omitempty"`
+}
+
+// Timing is where the time of a conversion went
+type Timing struct {
+	// Start is when the conversion started
+	Start time.Time
+	// Parse is spent reading the source, Transform converting its rows and
+	// Write writing and loading the output
+	Parse     time.Duration
+	Transform time.Duration
+	Write     time.Duration
+}
+
+// RejectedRow is a source row left out of the output, kept for the rejects file
+type RejectedRow struct {
+	SourceFile string
+	Row        int
+	Reason     string
+	Fields     []string
+}
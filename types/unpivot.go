@@ -0,0 +1,11 @@
+package types
+
+// Unpivot melts wide columns (jan_sales, feb_sales, ...) into rows: each
+// source row becomes one row per listed column, with the column name in
+// KeyColumn and its value in ValueColumn
+type Unpivot struct {
+	Columns     []string `json:"columns"`
+	KeyColumn   string   `json:"key_column"`
+	ValueColumn string   `json:"value_column"`
+	SkipEmpty   bool     `json:"skip_empty,omitempty"`
+}
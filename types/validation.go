@@ -0,0 +1,72 @@
+package types
+
+// ValidationReport tells whether a converted file conforms to its target
+// schema, and how it does not
+type ValidationReport struct {
+	File   string `json:"file"`
+	Schema string `json:"schema"`
+	Rows   int    `json:"rows"`
+	Passed bool   `json:"passed"`
+	// HeaderErrors describe missing, unexpected, repeated and misplaced
+	// columns
+	HeaderErrors []string `json:"header_errors,omitempty"`
+	// RaggedRows counts rows with more or fewer fields than the header
+	RaggedRows int                `json:"ragged_rows,omitempty"`
+	Columns    []ColumnValidation `json:"columns"`
+	// Keys are the unique columns and composite keys of the schema
+	Keys []KeyValidation `json:"keys,omitempty"`
+}
+
+// ColumnValidation holds the checks made on a target column and the values
+// that failed them, with the first failing values of each check
+type ColumnValidation struct {
+	Column string `json:"column"`
+	// Checks name the checks made, like "required", "values" or
+	// "date YYYY-MM-DD"; a column missing from the file has none
+	Checks   []string  `json:"checks"`
+	Failures []Anomaly `json:"failures,omitempty"`
+	// Suspicious values pass the checks but are likely wrong, like
+	// placeholder dates; they do not fail validation
+	Suspicious []Anomaly `json:"suspicious,omitempty"`
+}
+
+// KeyValidation holds the rows repeating a value of a unique column, or of
+// the columns of a composite key together
+type KeyValidation struct {
+	Columns []string `json:"columns"`
+	// Duplicates counts the rows repeating the key of an earlier row
+	Duplicates int `json:"duplicates"`
+	// Examples are the first repeated keys
+	Examples []DuplicateKey `json:"examples,omitempty"`
+}
+
+// DuplicateKey is a repeated key, joined with "|" when it has several
+// columns, and the first rows holding it, counting the header as row 1
+type DuplicateKey struct {
+	Key  string `json:"key"`
+	Rows []int  `json:"rows"`
+}
+
+// HeaderReport compares the header of a source file with the columns of its
+// source schema
+type HeaderReport struct {
+	File   string `json:"file,omitempty"`
+	Schema string `json:"schema,omitempty"`
+	// Compatible reports whether every schema column is in the header
+	Compatible bool `json:"compatible"`
+	// Missing are the schema columns found under no name of the header
+	Missing []string `json:"missing,omitempty"`
+	// Extra are the header columns the schema does not list
+	Extra []string `json:"extra,omitempty"`
+	// Aliased are the schema columns found under one of their aliases
+	Aliased []HeaderMatch `json:"aliased,omitempty"`
+	// Renames pair missing columns with similar extra header columns, which
+	// they were likely renamed to
+	Renames []HeaderMatch `json:"renames,omitempty"`
+}
+
+// HeaderMatch is a schema column found under another header name
+type HeaderMatch struct {
+	Column string `json:"column"`
+	Header string `json:"header"`
+}
@@ -0,0 +1,233 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/maphash"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ValueMap is the values_mapping of a source column: the target value of each
+// mapped source value. Schemas generated from high-cardinality columns map
+// tens of thousands of values, so the entries are kept back to back in one
+// string instead of a Go map, and indexed on the first lookup. A ValueMap is
+// never changed once loaded, so schemas can share it between workers; With
+// returns a changed copy. A nil ValueMap maps nothing.
+type ValueMap struct {
+	// data holds the source and target value of every entry back to back;
+	// entry i spans offsets[i] to offsets[i+1], its source value ending at
+	// split[i]
+	data    string
+	offsets []uint32
+	split   []uint32
+	index   *valueIndex
+	// added are entries given by With, which take precedence
+	added map[string]string
+}
+
+// valueIndex is a hash table of the entries of a ValueMap, built when first
+// needed and shared by the copies With makes
+type valueIndex struct {
+	once sync.Once
+	seed maphash.Seed
+	// slots hold 1 + the entry stored there, or 0 when empty
+	slots []uint32
+}
+
+// NewValueMap returns a ValueMap of the entries of mapping
+func NewValueMap(mapping map[string]string) *ValueMap {
+	var m ValueMap
+	var data strings.Builder
+	for _, value := range slices.Sorted(maps.Keys(mapping)) {
+		m.add(&data, value, mapping[value])
+	}
+	m.finish(&data)
+	return &m
+}
+
+func (m *ValueMap) add(data *strings.Builder, value, target string) {
+	if m.offsets == nil {
+		m.offsets = []uint32{0}
+	}
+	data.WriteString(value)
+	m.split = append(m.split, uint32(data.Len()))
+	data.WriteString(target)
+	m.offsets = append(m.offsets, uint32(data.Len()))
+}
+
+func (m *ValueMap) finish(data *strings.Builder) {
+	m.data = data.String()
+	m.index = &valueIndex{seed: maphash.MakeSeed()}
+}
+
+func (m *ValueMap) entries() int {
+	return len(m.split)
+}
+
+func (m *ValueMap) entry(i int) (value, target string) {
+	return m.data[m.offsets[i]:m.split[i]], m.data[m.split[i]:m.offsets[i+1]]
+}
+
+// Lookup returns the target value of a source value, and whether it is mapped
+func (m *ValueMap) Lookup(value string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	if target, found := m.added[value]; found {
+		return target, true
+	}
+	if m.index == nil || m.entries() == 0 {
+		return "", false
+	}
+
+	m.index.once.Do(m.buildIndex)
+	slots := m.index.slots
+	mask := uint64(len(slots) - 1)
+	for i := maphash.String(m.index.seed, value) & mask; ; i = (i + 1) & mask {
+		slot := slots[i]
+		if slot == 0 {
+			return "", false
+		}
+		if entryValue, target := m.entry(int(slot - 1)); entryValue == value {
+			return target, true
+		}
+	}
+}
+
+// buildIndex hashes the entries into a table at most half full. When a source
+// value repeats, the last entry wins, as in a JSON object decoded into a map.
+func (m *ValueMap) buildIndex() {
+	size := 2
+	for size < 2*m.entries() {
+		size *= 2
+	}
+	slots := make([]uint32, size)
+	mask := uint64(size - 1)
+	for e := 0; e < m.entries(); e++ {
+		value, _ := m.entry(e)
+		for i := maphash.String(m.index.seed, value) & mask; ; i = (i + 1) & mask {
+			if slots[i] == 0 {
+				slots[i] = uint32(e + 1)
+				break
+			}
+			if slotValue, _ := m.entry(int(slots[i] - 1)); slotValue == value {
+				slots[i] = uint32(e + 1)
+				break
+			}
+		}
+	}
+	m.index.slots = slots
+}
+
+// With returns a copy of m that also maps value to target. m is unchanged,
+// and the copy shares its entries.
+func (m *ValueMap) With(value, target string) *ValueMap {
+	if m == nil {
+		return NewValueMap(map[string]string{value: target})
+	}
+	copied := *m
+	copied.added = maps.Clone(m.added)
+	if copied.added == nil {
+		copied.added = make(map[string]string)
+	}
+	copied.added[value] = target
+	return &copied
+}
+
+// Keys returns the mapped source values in sorted order
+func (m *ValueMap) Keys() []string {
+	if m == nil {
+		return nil
+	}
+	keys := make([]string, 0, m.entries()+len(m.added))
+	if m.index != nil && m.entries() > 0 {
+		m.index.once.Do(m.buildIndex)
+		for _, slot := range m.index.slots {
+			if slot == 0 {
+				continue
+			}
+			value, _ := m.entry(int(slot - 1))
+			if _, added := m.added[value]; !added {
+				keys = append(keys, value)
+			}
+		}
+	}
+	for value := range m.added {
+		keys = append(keys, value)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Len returns the number of mapped source values
+func (m *ValueMap) Len() int {
+	return len(m.Keys())
+}
+
+// All iterates over the source and target values in sorted order
+func (m *ValueMap) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, value := range m.Keys() {
+			target, _ := m.Lookup(value)
+			if !yield(value, target) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON writes the entries as a JSON object with sorted keys, like a map
+func (m *ValueMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for value, target := range m.All() {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(value)
+		buf.Write(key)
+		buf.WriteByte(':')
+		mapped, _ := json.Marshal(target)
+		buf.Write(mapped)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a JSON object of strings token by token into the
+// entries, without building a map first
+func (m *ValueMap) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("values_mapping must be an object of strings")
+	}
+
+	*m = ValueMap{}
+	var entries strings.Builder
+	entries.Grow(len(data))
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		target, isString := token.(string)
+		if !isString {
+			return fmt.Errorf("values_mapping of %q must be a string, not %v", key, token)
+		}
+		m.add(&entries, key.(string), target)
+	}
+	m.finish(&entries)
+	return nil
+}
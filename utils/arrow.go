@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// arrowBatchRows is the number of rows per Arrow record batch
+const arrowBatchRows = 65536
+
+// Arrow metadata version V5, message header and type union members
+const (
+	arrowMetadataV5 = 4
+
+	arrowSchemaMessage      = 1
+	arrowRecordBatchMessage = 3
+
+	arrowInt           = 2
+	arrowFloatingPoint = 3
+	arrowUtf8          = 5
+	arrowBool          = 6
+)
+
+// IsArrow reports whether path is an Arrow IPC file
+func IsArrow(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".arrow", ".feather":
+		return true
+	}
+	return false
+}
+
+// WriteArrow writes records as an Arrow IPC file (Feather v2), which DuckDB,
+// Polars and pandas read without parsing. Like WriteParquet, number, integer
+// and boolean columns in columnTypes become nullable float64, int64 and bool
+// columns with empty values as null, and every other column utf8.
+// The records are the converted rows of text values, laid out as columns only
+// here: the conversion itself does not hold its rows as Arrow record batches.
+func WriteArrow(path string, records [][]string, columnTypes map[string]string) error {
+	if len(records) == 0 {
+		return fmt.Errorf("no records to write")
+	}
+	header, rows := records[0], records[1:]
+
+	typeIDs := make([]uint8, len(header))
+	fields := make([]fbTable, len(header))
+	for i, name := range header {
+		var typ fbTable
+		switch columnTypes[name] {
+		case "number":
+			typeIDs[i], typ = arrowFloatingPoint, fbTable{{0, int16(2)}} // double precision
+		case "integer":
+			typeIDs[i], typ = arrowInt, fbTable{{0, int32(64)}, {1, true}}
+		case "boolean":
+			typeIDs[i], typ = arrowBool, fbTable{}
+		default:
+			typeIDs[i], typ = arrowUtf8, fbTable{}
+		}
+		fields[i] = fbTable{{0, name}, {1, true}, {2, typeIDs[i]}, {3, typ}, {5, []fbTable{}}}
+	}
+	schema := fbTable{{0, int16(0)}, {1, fields}} // little endian
+
+	var out bytes.Buffer
+	out.WriteString("ARROW1\x00\x00")
+	writeArrowMessage(&out, arrowSchemaMessage, schema, nil)
+
+	var blocks bytes.Buffer
+	for start := 0; start == 0 || start < len(rows); start += arrowBatchRows {
+		batch := rows[start:min(start+arrowBatchRows, len(rows))]
+
+		var body bytes.Buffer
+		var nodes, buffers bytes.Buffer
+		addBuffer := func(data []byte) {
+			buffers.Write(binary.LittleEndian.AppendUint64(nil, uint64(body.Len())))
+			buffers.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(data))))
+			body.Write(data)
+			for body.Len()%8 != 0 {
+				body.WriteByte(0)
+			}
+		}
+
+		for i := range header {
+			valid := make([]bool, len(batch))
+			var values bytes.Buffer
+			var bits []bool
+			offsets := binary.LittleEndian.AppendUint32(nil, 0)
+			nulls := 0
+			for r, row := range batch {
+				value := ""
+				if i < len(row) {
+					value = row[i]
+				}
+				valid[r] = value != "" || typeIDs[i] == arrowUtf8
+
+				var err error
+				switch typeIDs[i] {
+				case arrowFloatingPoint:
+					var number float64
+					if valid[r] {
+						if number, err = strconv.ParseFloat(value, 64); err != nil {
+							err = fmt.Errorf("%q is not a number", value)
+						}
+					}
+					values.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(number)))
+				case arrowInt:
+					var number int64
+					if valid[r] {
+						if number, err = strconv.ParseInt(value, 10, 64); err != nil {
+							err = fmt.Errorf("%q is not an integer", value)
+						}
+					}
+					values.Write(binary.LittleEndian.AppendUint64(nil, uint64(number)))
+				case arrowBool:
+					var b bool
+					if valid[r] {
+						if b, err = strconv.ParseBool(value); err != nil {
+							err = fmt.Errorf("%q is not a boolean", value)
+						}
+					}
+					bits = append(bits, b)
+				default:
+					values.WriteString(value)
+					if values.Len() > math.MaxInt32 {
+						err = fmt.Errorf("more than 2 GB of text in one batch")
+					}
+					offsets = binary.LittleEndian.AppendUint32(offsets, uint32(values.Len()))
+				}
+				if err != nil {
+					return fmt.Errorf("column %s, row %d: %v", header[i], start+r+1, err)
+				}
+				if !valid[r] {
+					nulls++
+				}
+			}
+
+			nodes.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(batch))))
+			nodes.Write(binary.LittleEndian.AppendUint64(nil, uint64(nulls)))
+			addBuffer(packBits(valid))
+			switch typeIDs[i] {
+			case arrowBool:
+				addBuffer(packBits(bits))
+			case arrowUtf8:
+				addBuffer(offsets)
+				addBuffer(values.Bytes())
+			default:
+				addBuffer(values.Bytes())
+			}
+		}
+
+		recordBatch := fbTable{
+			{0, int64(len(batch))},
+			{1, fbStructs{Count: len(header), Data: nodes.Bytes()}},
+			{2, fbStructs{Count: buffers.Len() / 16, Data: buffers.Bytes()}},
+		}
+		offset := out.Len()
+		metadataLength := writeArrowMessage(&out, arrowRecordBatchMessage, recordBatch, body.Bytes())
+
+		blocks.Write(binary.LittleEndian.AppendUint64(nil, uint64(offset)))
+		blocks.Write(binary.LittleEndian.AppendUint32(nil, uint32(metadataLength)))
+		blocks.Write(make([]byte, 4))
+		blocks.Write(binary.LittleEndian.AppendUint64(nil, uint64(body.Len())))
+	}
+
+	// End of stream marker
+	out.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+
+	footer := buildFlatbuffer(fbTable{
+		{0, int16(arrowMetadataV5)},
+		{1, schema},
+		{2, fbStructs{}},
+		{3, fbStructs{Count: blocks.Len() / 24, Data: blocks.Bytes()}},
+	})
+	out.Write(footer)
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	out.WriteString("ARROW1")
+
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// writeArrowMessage writes an encapsulated IPC message and returns the length
+// of its metadata, including the 8-byte prefix
+func writeArrowMessage(out *bytes.Buffer, headerType uint8, header fbTable, body []byte) int {
+	metadata := buildFlatbuffer(fbTable{
+		{0, int16(arrowMetadataV5)},
+		{1, headerType},
+		{2, header},
+		{3, int64(len(body))},
+	})
+	out.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(metadata))))
+	out.Write(metadata)
+	out.Write(body)
+	return 8 + len(metadata)
+}
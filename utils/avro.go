@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// avroBlockRows is the number of rows per Avro data block
+const avroBlockRows = 10000
+
+// IsAvro reports whether path is an Avro container file
+func IsAvro(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".avro")
+}
+
+// WriteAvro writes records as an Avro object container file whose embedded
+// schema is a record named after the file. Like WriteParquet, number,
+// integer and boolean columns in columnTypes become nullable double, long and
+// boolean fields with empty values as null, and every other column a
+// nullable string. Header names are turned into valid Avro names
+// (Customer ID becomes Customer_ID), keeping the original as an alias.
+func WriteAvro(path string, records [][]string, columnTypes map[string]string) error {
+	if len(records) == 0 {
+		return fmt.Errorf("no records to write")
+	}
+	header, rows := records[0], records[1:]
+
+	type avroField struct {
+		Name    string   `json:"name"`
+		Type    []string `json:"type"`
+		Aliases []string `json:"aliases,omitempty"`
+	}
+	fields := make([]avroField, len(header))
+	kinds := make([]string, len(header))
+	seen := make(map[string]string)
+	for i, column := range header {
+		kinds[i] = "string"
+		switch columnTypes[column] {
+		case "number":
+			kinds[i] = "double"
+		case "integer":
+			kinds[i] = "long"
+		case "boolean":
+			kinds[i] = "boolean"
+		}
+
+		name := avroName(column)
+		if other, exists := seen[name]; exists {
+			return fmt.Errorf("columns %q and %q both become the Avro field %q", other, column, name)
+		}
+		seen[name] = column
+		fields[i] = avroField{Name: name, Type: []string{"null", kinds[i]}}
+		if name != column {
+			fields[i].Aliases = []string{column}
+		}
+	}
+
+	schema, err := json.Marshal(map[string]any{
+		"type":   "record",
+		"name":   avroName(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))),
+		"fields": fields,
+	})
+	if err != nil {
+		return err
+	}
+
+	sync := make([]byte, 16)
+	if _, err := rand.Read(sync); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	out.WriteString("Obj\x01")
+	out.Write(avroLong(2))
+	for _, entry := range [][2]string{{"avro.schema", string(schema)}, {"avro.codec", "null"}} {
+		out.Write(avroBytes([]byte(entry[0])))
+		out.Write(avroBytes([]byte(entry[1])))
+	}
+	out.Write(avroLong(0))
+	out.Write(sync)
+
+	var block bytes.Buffer
+	for start := 0; start < len(rows); start += avroBlockRows {
+		end := min(start+avroBlockRows, len(rows))
+		block.Reset()
+		for r, row := range rows[start:end] {
+			for i, kind := range kinds {
+				value := ""
+				if i < len(row) {
+					value = row[i]
+				}
+				if err := writeAvroValue(&block, value, kind); err != nil {
+					return fmt.Errorf("column %s, row %d: %v", header[i], start+r+1, err)
+				}
+			}
+		}
+		out.Write(avroLong(int64(end - start)))
+		out.Write(avroLong(int64(block.Len())))
+		out.Write(block.Bytes())
+		out.Write(sync)
+	}
+
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// writeAvroValue writes a value of a ["null", kind] union
+func writeAvroValue(w *bytes.Buffer, value, kind string) error {
+	if value == "" && kind != "string" {
+		w.Write(avroLong(0))
+		return nil
+	}
+	w.Write(avroLong(1))
+
+	switch kind {
+	case "double":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		w.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(number)))
+	case "long":
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		w.Write(avroLong(number))
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		if b {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+	default:
+		w.Write(avroBytes([]byte(value)))
+	}
+	return nil
+}
+
+// avroLong encodes a long as a zigzag varint
+func avroLong(v int64) []byte {
+	return binary.AppendUvarint(nil, uint64(v<<1^v>>63))
+}
+
+func avroBytes(b []byte) []byte {
+	return append(avroLong(int64(len(b))), b...)
+}
+
+// avroName replaces characters Avro names cannot contain with underscores
+func avroName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
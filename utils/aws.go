@@ -0,0 +1,386 @@
+package utils
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/ashr-tech/csv-migration-tools/config"
+)
+
+// awsCredentials are the keys requests to S3 are signed with. Temporary
+// credentials expire; others have a zero Expiration.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// awsCache keeps the credentials between requests, and the region of each
+// bucket learned from S3's redirects
+var awsCache struct {
+	sync.Mutex
+	credentials *awsCredentials
+	regions     map[string]string
+}
+
+// loadAWSCredentials returns the cached credentials, resolving them again
+// when they expire within five minutes
+func loadAWSCredentials() (awsCredentials, error) {
+	awsCache.Lock()
+	defer awsCache.Unlock()
+	if c := awsCache.credentials; c != nil && (c.Expiration.IsZero() || time.Until(c.Expiration) > 5*time.Minute) {
+		return *c, nil
+	}
+	credentials, err := resolveAWSCredentials()
+	if err != nil {
+		return credentials, err
+	}
+	awsCache.credentials = &credentials
+	return credentials, nil
+}
+
+// resolveAWSCredentials looks for credentials where the AWS SDKs do, in their
+// order: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY; the keys, the
+// credential_process or the SSO login of the AWS_PROFILE (or default)
+// profile in ~/.aws/credentials and ~/.aws/config; the task role of an ECS
+// or Fargate container; and the instance role of an EC2 instance.
+func resolveAWSCredentials() (awsCredentials, error) {
+	credentials := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.AccessKeyID != "" && credentials.SecretAccessKey != "" {
+		return credentials, nil
+	}
+
+	profileName := awsProfileName()
+	profile := awsProfile(profileName)
+	switch {
+	case profile["aws_access_key_id"] != "" && profile["aws_secret_access_key"] != "":
+		return awsCredentials{
+			AccessKeyID:     profile["aws_access_key_id"],
+			SecretAccessKey: profile["aws_secret_access_key"],
+			SessionToken:    profile["aws_session_token"],
+		}, nil
+	case profile["credential_process"] != "":
+		return processCredentials(profile["credential_process"])
+	case profile["sso_account_id"] != "":
+		return ssoCredentials(profile)
+	}
+
+	if os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "" {
+		return containerCredentials()
+	}
+	if !strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		credentials, err := instanceCredentials()
+		if err == nil {
+			return credentials, nil
+		}
+		return credentials, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, configure profile %s in ~/.aws/credentials or ~/.aws/config, or run with an instance or task role (%v)", profileName, err)
+	}
+	return credentials, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or configure profile %s in ~/.aws/credentials or ~/.aws/config", profileName)
+}
+
+// awsRegion is the region of buckets whose region is not known yet: that of
+// AWS_REGION, AWS_DEFAULT_REGION or the profile, else us-east-1
+func awsRegion() string {
+	return cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), awsProfile(awsProfileName())["region"], "us-east-1")
+}
+
+func awsProfileName() string {
+	return cmp.Or(os.Getenv("AWS_PROFILE"), "default")
+}
+
+// awsProfile returns the settings of a profile: those of its section of the
+// config file (AWS_CONFIG_FILE or ~/.aws/config), overridden by those of the
+// credentials file (AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials)
+func awsProfile(name string) map[string]string {
+	home, _ := os.UserHomeDir()
+	configFile := cmp.Or(os.Getenv("AWS_CONFIG_FILE"), filepath.Join(home, ".aws", "config"))
+	credentialsFile := cmp.Or(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), filepath.Join(home, ".aws", "credentials"))
+
+	// Profiles other than the default are "[profile name]" in the config file
+	section := "profile " + name
+	if name == "default" {
+		section = name
+	}
+	profile := readAWSSection(configFile, section)
+	for key, value := range readAWSSection(credentialsFile, name) {
+		profile[key] = value
+	}
+	return profile
+}
+
+// readAWSSection reads the settings of a section of an AWS config or
+// credentials file. Indented lines, which nest settings of a service such as
+// s3, are skipped; a missing file has no settings.
+func readAWSSection(path, section string) map[string]string {
+	settings := make(map[string]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return settings
+	}
+	defer file.Close()
+
+	current := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			continue
+		case current != section || raw[0] == ' ' || raw[0] == '\t':
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); found {
+			settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// awsCredentialsJSON is the credentials document of credential_process
+// commands, the ECS endpoint and the EC2 instance metadata
+type awsCredentialsJSON struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+func (c awsCredentialsJSON) credentials(source string) (awsCredentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("%s returned no AWS credentials", source)
+	}
+	credentials := awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: cmp.Or(c.SessionToken, c.Token)}
+	if c.Expiration != "" {
+		expiration, err := time.Parse(time.RFC3339, c.Expiration)
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("%s returned an invalid expiration %q", source, c.Expiration)
+		}
+		credentials.Expiration = expiration
+	}
+	return credentials, nil
+}
+
+// processCredentials runs the credential_process of a profile, which prints
+// the credentials as JSON
+func processCredentials(command string) (awsCredentials, error) {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	output, err := runClient(cmd)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("credential_process: %v", err)
+	}
+	var document awsCredentialsJSON
+	if err := json.Unmarshal(output, &document); err != nil {
+		return awsCredentials{}, fmt.Errorf("credential_process printed invalid JSON: %v", err)
+	}
+	return document.credentials("credential_process")
+}
+
+// ssoCredentials exchanges the token that "aws sso login" cached for the
+// credentials of the profile's account and role. The profile names its
+// start URL and region itself or through an sso_session section.
+func ssoCredentials(profile map[string]string) (awsCredentials, error) {
+	startURL, region, cacheKey := profile["sso_start_url"], profile["sso_region"], profile["sso_start_url"]
+	if session := profile["sso_session"]; session != "" {
+		home, _ := os.UserHomeDir()
+		configFile := cmp.Or(os.Getenv("AWS_CONFIG_FILE"), filepath.Join(home, ".aws", "config"))
+		settings := readAWSSection(configFile, "sso-session "+session)
+		startURL, region, cacheKey = settings["sso_start_url"], settings["sso_region"], session
+	}
+	if startURL == "" || region == "" || profile["sso_role_name"] == "" {
+		return awsCredentials{}, fmt.Errorf("the SSO profile needs sso_start_url, sso_region and sso_role_name")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	hash := sha1.Sum([]byte(cacheKey))
+	data, err := os.ReadFile(filepath.Join(home, ".aws", "sso", "cache", hex.EncodeToString(hash[:])+".json"))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no SSO login for %s: run aws sso login", startURL)
+	}
+	var token struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresAt   string `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return awsCredentials{}, fmt.Errorf("invalid SSO token cache: %v", err)
+	}
+	if expires, err := time.Parse(time.RFC3339, token.ExpiresAt); err == nil && time.Now().After(expires) {
+		return awsCredentials{}, fmt.Errorf("the SSO login for %s expired: run aws sso login", startURL)
+	}
+
+	query := url.Values{"account_id": {profile["sso_account_id"]}, "role_name": {profile["sso_role_name"]}}
+	endpoint := fmt.Sprintf(config.AWS_SSO_ENDPOINT, region) + "?" + query.Encode()
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("x-amz-sso_bearer_token", token.AccessToken)
+	var result struct {
+		RoleCredentials struct {
+			AccessKeyID     string `json:"accessKeyId"`
+			SecretAccessKey string `json:"secretAccessKey"`
+			SessionToken    string `json:"sessionToken"`
+			Expiration      int64  `json:"expiration"`
+		} `json:"roleCredentials"`
+	}
+	if err := getAWSJSON(http.DefaultClient, req, "SSO", &result); err != nil {
+		return awsCredentials{}, err
+	}
+	role := result.RoleCredentials
+	if role.AccessKeyID == "" {
+		return awsCredentials{}, fmt.Errorf("SSO returned no AWS credentials")
+	}
+	return awsCredentials{
+		AccessKeyID:     role.AccessKeyID,
+		SecretAccessKey: role.SecretAccessKey,
+		SessionToken:    role.SessionToken,
+		Expiration:      time.UnixMilli(role.Expiration),
+	}, nil
+}
+
+// containerCredentials gets the credentials of the task role of an ECS or
+// Fargate container, or of an EKS pod identity, from the endpoint the
+// container runtime sets up
+func containerCredentials() (awsCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = config.AWS_ECS_ENDPOINT + relative
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	authorization := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		authorization = strings.TrimSpace(string(data))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	var document awsCredentialsJSON
+	if err := getAWSJSON(awsMetadataClient, req, "the container credentials endpoint", &document); err != nil {
+		return awsCredentials{}, err
+	}
+	return document.credentials("the container credentials endpoint")
+}
+
+// awsMetadataClient asks the metadata endpoints, which outside AWS nothing
+// answers, so it does not wait long
+var awsMetadataClient = &http.Client{Timeout: 2 * time.Second}
+
+// instanceCredentials gets the credentials of the instance role of an EC2
+// instance from the instance metadata service, with an IMDSv2 session token
+// when the service hands one out. AWS_EC2_METADATA_SERVICE_ENDPOINT selects
+// another endpoint.
+func instanceCredentials() (awsCredentials, error) {
+	endpoint := strings.TrimSuffix(cmp.Or(os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), config.AWS_IMDS_ENDPOINT), "/")
+
+	token := ""
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(6*60*60))
+	if resp, err := awsMetadataClient.Do(req); err == nil {
+		data, _ := readBody(resp)
+		if resp.StatusCode == http.StatusOK {
+			token = string(data)
+		}
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequest(http.MethodGet, endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("X-aws-ec2-metadata-token", token)
+		}
+		resp, err := awsMetadataClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("instance metadata unavailable: %v", err)
+		}
+		data, err := readBody(resp)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("instance metadata %s: %s", path, resp.Status)
+		}
+		return data, err
+	}
+	const rolePath = "/latest/meta-data/iam/security-credentials/"
+	roles, err := get(rolePath)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+	if role == "" {
+		return awsCredentials{}, fmt.Errorf("the instance has no role")
+	}
+	data, err := get(rolePath + role)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	var document awsCredentialsJSON
+	if err := json.Unmarshal(data, &document); err != nil {
+		return awsCredentials{}, fmt.Errorf("invalid instance credentials: %v", err)
+	}
+	return document.credentials("the instance metadata service")
+}
+
+// getAWSJSON sends a credentials request and decodes its JSON response
+func getAWSJSON(client *http.Client, req *http.Request, source string, result any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s unavailable: %v", source, err)
+	}
+	data, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", source, resp.Status)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("invalid response of %s: %v", source, err)
+	}
+	return nil
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
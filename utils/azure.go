@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	config "github.com/ashr-tech/csv-migration-tools/config"
+)
+
+func readAzureBlob(location *url.URL) ([]byte, error) {
+	return azureRequest(http.MethodGet, location, nil)
+}
+
+func writeAzureBlob(location *url.URL, data []byte) error {
+	_, err := azureRequest(http.MethodPut, location, data)
+	return err
+}
+
+// azureBlobEndpoint resolves a blob URL and its SAS token. Blobs are named
+// az://<account>.blob.core.windows.net/<container>/<blob>, or
+// az://<container>/<blob> with the account in AZURE_STORAGE_ACCOUNT, or by
+// their https:// URL, which may carry a SAS token. AZURE_STORAGE_ENDPOINT
+// selects another endpoint, such as Azurite's http://127.0.0.1:10000/devstoreaccount1.
+func azureBlobEndpoint(location *url.URL) (string, string, error) {
+	host, path := location.Host, strings.TrimPrefix(location.Path, "/")
+	if strings.EqualFold(location.Scheme, "az") && !strings.Contains(host, ".") {
+		path = host + "/" + path
+		host = ""
+	}
+	if container, blob, _ := strings.Cut(path, "/"); container == "" || blob == "" {
+		return "", "", fmt.Errorf("%s does not name a container and blob", location.Redacted())
+	}
+
+	endpoint := os.Getenv("AZURE_STORAGE_ENDPOINT")
+	if endpoint == "" {
+		if host == "" {
+			account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+			if account == "" {
+				return "", "", fmt.Errorf("AZURE_STORAGE_ACCOUNT is not set for %s", location.Redacted())
+			}
+			host = account + ".blob.core.windows.net"
+		}
+		endpoint = "https://" + host
+	}
+
+	sas := location.RawQuery
+	if sas == "" {
+		sas = strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + escapeObjectPath(path), sas, nil
+}
+
+// azureRequest sends a blob request authorized by the SAS token, or else by
+// a managed identity token
+func azureRequest(method string, location *url.URL, body []byte) ([]byte, error) {
+	endpoint, sas, err := azureBlobEndpoint(location)
+	if err != nil {
+		return nil, err
+	}
+	if sas != "" {
+		endpoint += "?" + sas
+	}
+
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", config.AZURE_STORAGE_API_VERSION)
+	if method == http.MethodPut {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+	}
+	if sas == "" {
+		token, err := managedIdentityToken()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The error quotes the URL, which must not leak the SAS token
+		return nil, fmt.Errorf("request to %s failed", location.Redacted())
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var azureError struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.Unmarshal(data, &azureError) == nil && azureError.Code != "" {
+			message, _, _ := strings.Cut(azureError.Message, "\n")
+			return nil, fmt.Errorf("%s: %s: %s", resp.Status, azureError.Code, message)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	return data, nil
+}
+
+// managedIdentityToken gets a storage token for the managed identity of the
+// Azure VM, or of the App Service or Functions app the tool runs in.
+// AZURE_CLIENT_ID selects a user-assigned identity.
+func managedIdentityToken() (string, error) {
+	query := url.Values{"resource": {config.AZURE_STORAGE_RESOURCE}}
+	if clientID := os.Getenv("AZURE_CLIENT_ID"); clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	endpoint := config.AZURE_IMDS_ENDPOINT
+	headers := map[string]string{"Metadata": "true"}
+	query.Set("api-version", "2018-02-01")
+	if identityEndpoint := os.Getenv("IDENTITY_ENDPOINT"); identityEndpoint != "" {
+		endpoint = identityEndpoint
+		headers = map[string]string{"X-IDENTITY-HEADER": os.Getenv("IDENTITY_HEADER")}
+		query.Set("api-version", "2019-08-01")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	// Outside Azure nothing answers, so do not wait long
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("no Azure credentials: add a SAS token to the URL or AZURE_STORAGE_SAS_TOKEN, or run with a managed identity (%v)", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to read managed identity token: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("failed to get managed identity token: %s %s", resp.Status, token.Error)
+	}
+	return token.AccessToken, nil
+}
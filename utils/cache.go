@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// FileCache remembers values built from local files, such as parsed schemas
+// and indexed lookup tables, so that batch runs read and index each file
+// once. An entry is rebuilt when its file's modification time or size
+// changes. Remote files and stdin are not cached.
+type FileCache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]fileCacheEntry[V]
+}
+
+type fileCacheEntry[V any] struct {
+	modTime time.Time
+	size    int64
+	value   V
+}
+
+// Get returns the value of path under key, which tells apart values built
+// differently from the same file, or builds and remembers it. Files that
+// fail to build are not remembered.
+func (c *FileCache[V]) Get(path, key string, build func() (V, error)) (V, error) {
+	if IsStdin(path) || IsRemote(path) || IsDatabaseURL(path) || IsGoogleSheet(path) {
+		return build()
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return build()
+	}
+
+	key = path + "\x00" + key
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	c.mu.Unlock()
+	if found && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.value, nil
+	}
+
+	value, err := build()
+	if err != nil {
+		return value, err
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]fileCacheEntry[V])
+	}
+	c.entries[key] = fileCacheEntry[V]{modTime: info.ModTime(), size: info.Size(), value: value}
+	c.mu.Unlock()
+	return value, nil
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFile reads a possibly compressed file, storage object or download:
+// "data.csv.gz" is gunzipped, and "export.zip!customers.csv" reads one file
+// of a zip archive ("export.zip" is enough when it holds a single CSV, TSV or
+// JSON file). It also returns the name of the uncompressed file, whose
+// extension tells its format. Piped data ("-") has no extension, so gzip and
+// zip data are told by their first bytes.
+func ReadFile(path string) ([]byte, string, error) {
+	archive, inner, isZip := strings.Cut(path, "!")
+	if isZip || strings.EqualFold(filepath.Ext(dataName(path)), ".zip") {
+		return readZipEntry(archive, inner)
+	}
+
+	name := dataName(path)
+	content, err := readData(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if IsStdin(path) {
+		switch {
+		case bytes.HasPrefix(content, []byte("PK\x03\x04")):
+			return readZipEntry(path, "")
+		case bytes.HasPrefix(content, []byte("\x1f\x8b")):
+			return gunzip(content, name, name)
+		}
+	}
+	if !strings.EqualFold(filepath.Ext(name), ".gz") {
+		return content, name, nil
+	}
+	return gunzip(content, name, strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// gunzip uncompresses the content of the file name, returning it as uncompressed
+func gunzip(content []byte, name, uncompressed string) ([]byte, string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	defer reader.Close()
+
+	content, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	return content, uncompressed, nil
+}
+
+func readZipEntry(archive, name string) ([]byte, string, error) {
+	data, err := readData(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %v", archive, err)
+	}
+
+	var entry *zip.File
+	if name != "" {
+		for _, file := range reader.File {
+			if file.Name == name {
+				entry = file
+				break
+			}
+		}
+		if entry == nil {
+			return nil, "", fmt.Errorf("%s has no file %s", archive, name)
+		}
+	} else {
+		var candidates []string
+		for _, file := range reader.File {
+			ext := strings.ToLower(filepath.Ext(file.Name))
+			if !file.FileInfo().IsDir() && (ext == ".csv" || ext == ".tsv" || IsJSON(file.Name)) {
+				candidates = append(candidates, file.Name)
+				entry = file
+			}
+		}
+		if len(candidates) != 1 {
+			return nil, "", fmt.Errorf("%s holds %d data files, select one with %s!<file> (found: %s)",
+				archive, len(candidates), archive, strings.Join(candidates, ", "))
+		}
+	}
+
+	file, err := entry.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s from %s: %v", entry.Name, archive, err)
+	}
+
+	return content, entry.Name, nil
+}
+
+// compressWriter wraps file in the given compression. The zip entry is named
+// after the base of name. The returned function finishes the compressed
+// stream but does not close file.
+func compressWriter(file io.Writer, name string, compress string) (io.Writer, func() error, error) {
+	switch compress {
+	case "":
+		return file, func() error { return nil }, nil
+	case "gzip":
+		writer := gzip.NewWriter(file)
+		return writer, writer.Close, nil
+	case "zip":
+		writer := zip.NewWriter(file)
+		entry, err := writer.Create(filepath.Base(name))
+		if err != nil {
+			return nil, nil, err
+		}
+		return entry, writer.Close, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown compression %q", compress)
+}
+
+// CompressedExtension is the file extension added by a compression
+func CompressedExtension(compress string) string {
+	switch compress {
+	case "gzip":
+		return ".gz"
+	case "zip":
+		return ".zip"
+	}
+
+	return ""
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// DBColumn describes a table column in schema terms
+type DBColumn struct {
+	Name string
+	// Type is the schema type: integer, number, boolean, date, datetime or
+	// empty for text
+	Type string
+	// Decimals is the scale of fixed-point columns
+	Decimals *int
+	// Required is set for NOT NULL columns without a default
+	Required bool
+	// Values lists the labels of enum columns
+	Values []string
+}
+
+// DBTable is the metadata of a database table
+type DBTable struct {
+	Columns []DBColumn
+	// PrimaryKey is the primary key column, when the key has a single column
+	PrimaryKey string
+	// References are the single-column foreign keys
+	References []types.TableReference
+}
+
+// DescribeTable reads the column metadata of table from the catalog of the
+// database at connection, a postgres:// or mysql:// URL. table may be
+// qualified like schema.table.
+func DescribeTable(connection, table string) (DBTable, error) {
+	if strings.HasPrefix(strings.ToLower(connection), "mysql://") {
+		return describeMySQLTable(connection, table)
+	}
+	return describePostgresTable(connection, table)
+}
+
+// SampleTable returns up to rows rows of table with its header
+func SampleTable(connection, table string, rows int) ([][]string, error) {
+	return QueryDatabase(connection, fmt.Sprintf("SELECT * FROM %s LIMIT %d", QuoteTable(connection, table), rows))
+}
+
+// QuoteTable quotes each part of a possibly schema-qualified table name for
+// the database at connection: with backticks for MySQL, else double quotes
+func QuoteTable(connection, table string) string {
+	if !strings.HasPrefix(strings.ToLower(connection), "mysql://") {
+		return quotePostgresTable(table)
+	}
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = quoteMySQLIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// StripPassword removes the password from a database URL, so that it can be
+// saved in project files; the clients then read it from their usual places
+func StripPassword(connection string) string {
+	parsed, err := url.Parse(connection)
+	if err != nil || parsed.User == nil {
+		return connection
+	}
+	parsed.User = url.User(parsed.User.Username())
+	return parsed.String()
+}
+
+// describePostgresTable queries pg_catalog; the JSON aggregates keep the
+// result to one row
+func describePostgresTable(connection, table string) (DBTable, error) {
+	relation := quoteSQLString(quotePostgresTable(table)) + "::regclass"
+	query := fmt.Sprintf(`SELECT
+  (SELECT coalesce(json_agg(json_build_object(
+     'name', a.attname,
+     'type', format_type(a.atttypid, a.atttypmod),
+     'not_null', a.attnotnull,
+     'has_default', a.atthasdef OR a.attidentity <> '',
+     'enum', (SELECT json_agg(e.enumlabel ORDER BY e.enumsortorder) FROM pg_enum e WHERE e.enumtypid = a.atttypid)
+   ) ORDER BY a.attnum), '[]')
+   FROM pg_attribute a WHERE a.attrelid = %[1]s AND a.attnum > 0 AND NOT a.attisdropped) AS columns,
+  (SELECT coalesce(json_agg(json_build_object(
+     'type', c.contype,
+     'column', a.attname,
+     'table', CASE WHEN c.contype = 'f' THEN c.confrelid::regclass::text END
+   )), '[]')
+   FROM pg_constraint c JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+   WHERE c.conrelid = %[1]s AND c.contype IN ('p', 'f') AND cardinality(c.conkey) = 1) AS keys`, relation)
+
+	records, err := QueryDatabase(connection, query)
+	if err != nil {
+		return DBTable{}, err
+	}
+	if len(records) < 2 || len(records[1]) < 2 {
+		return DBTable{}, fmt.Errorf("unexpected catalog result for %s", table)
+	}
+
+	var columns []struct {
+		Name       string   `json:"name"`
+		Type       string   `json:"type"`
+		NotNull    bool     `json:"not_null"`
+		HasDefault bool     `json:"has_default"`
+		Enum       []string `json:"enum"`
+	}
+	if err := json.Unmarshal([]byte(records[1][0]), &columns); err != nil {
+		return DBTable{}, fmt.Errorf("error parsing column metadata: %v", err)
+	}
+	var keys []struct {
+		Type   string `json:"type"`
+		Column string `json:"column"`
+		Table  string `json:"table"`
+	}
+	if err := json.Unmarshal([]byte(records[1][1]), &keys); err != nil {
+		return DBTable{}, fmt.Errorf("error parsing key metadata: %v", err)
+	}
+
+	if len(columns) == 0 {
+		return DBTable{}, fmt.Errorf("table %s has no columns", table)
+	}
+
+	var described DBTable
+	for _, column := range columns {
+		dbColumn := DBColumn{Name: column.Name, Required: column.NotNull && !column.HasDefault, Values: column.Enum}
+		dbColumn.Type, dbColumn.Decimals = schemaType(column.Type)
+		described.Columns = append(described.Columns, dbColumn)
+	}
+	for _, key := range keys {
+		if key.Type == "p" {
+			described.PrimaryKey = key.Column
+		} else {
+			described.References = append(described.References, types.TableReference{Column: key.Column, Table: strings.Trim(key.Table, `"`)})
+		}
+	}
+	return described, nil
+}
+
+// mysqlColumn is a row of information_schema.COLUMNS
+type mysqlColumn struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Position int     `json:"position"`
+	Nullable string  `json:"nullable"`
+	Default  *string `json:"default"`
+	Extra    string  `json:"extra"`
+	Key      string  `json:"key"`
+}
+
+// describeMySQLTable queries information_schema; JSON_ARRAYAGG needs MySQL
+// 5.7.22 or MariaDB 10.5
+func describeMySQLTable(connection, table string) (DBTable, error) {
+	database := "DATABASE()"
+	if before, after, found := strings.Cut(table, "."); found {
+		database, table = quoteMySQLString(before), after
+	}
+	where := fmt.Sprintf("TABLE_SCHEMA = %s AND TABLE_NAME = %s", database, quoteMySQLString(table))
+	query := fmt.Sprintf(`SELECT
+  (SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT(
+     'name', COLUMN_NAME, 'type', COLUMN_TYPE, 'position', ORDINAL_POSITION,
+     'nullable', IS_NULLABLE, 'default', COLUMN_DEFAULT, 'extra', EXTRA, 'key', COLUMN_KEY
+   )), JSON_ARRAY())
+   FROM information_schema.COLUMNS WHERE %[1]s) AS columns,
+  (SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT('column', COLUMN_NAME, 'table', REFERENCED_TABLE_NAME)), JSON_ARRAY())
+   FROM information_schema.KEY_COLUMN_USAGE WHERE %[1]s AND REFERENCED_TABLE_NAME IS NOT NULL) AS foreign_keys`, where)
+
+	records, err := QueryDatabase(connection, query)
+	if err != nil {
+		return DBTable{}, err
+	}
+	if len(records) < 2 || len(records[1]) < 2 {
+		return DBTable{}, fmt.Errorf("unexpected catalog result for %s", table)
+	}
+
+	var columns []mysqlColumn
+	if err := json.Unmarshal([]byte(records[1][0]), &columns); err != nil {
+		return DBTable{}, fmt.Errorf("error parsing column metadata: %v", err)
+	}
+	var references []types.TableReference
+	if err := json.Unmarshal([]byte(records[1][1]), &references); err != nil {
+		return DBTable{}, fmt.Errorf("error parsing key metadata: %v", err)
+	}
+	if len(columns) == 0 {
+		return DBTable{}, fmt.Errorf("table %s not found", table)
+	}
+	// JSON_ARRAYAGG does not keep the column order
+	slices.SortFunc(columns, func(a, b mysqlColumn) int {
+		return a.Position - b.Position
+	})
+
+	described := DBTable{References: references}
+	primaryKeys := 0
+	for _, column := range columns {
+		dbColumn := DBColumn{
+			Name:     column.Name,
+			Required: column.Nullable == "NO" && column.Default == nil && column.Extra == "",
+			Values:   parseMySQLEnum(column.Type),
+		}
+		dbColumn.Type, dbColumn.Decimals = schemaType(column.Type)
+		described.Columns = append(described.Columns, dbColumn)
+		if column.Key == "PRI" {
+			described.PrimaryKey = column.Name
+			primaryKeys++
+		}
+	}
+	if primaryKeys > 1 {
+		described.PrimaryKey = ""
+	}
+	return described, nil
+}
+
+var (
+	integerTypes    = []string{"smallint", "integer", "bigint", "int", "tinyint", "mediumint", "smallserial", "serial", "bigserial", "year"}
+	numberTypes     = []string{"numeric", "decimal", "real", "double", "float", "money"}
+	decimalsPattern = regexp.MustCompile(`^\w+\(\d+,\s*(\d+)\)`)
+)
+
+// schemaType maps a Postgres or MySQL column type to a schema type and, for
+// fixed-point types, its number of decimals
+func schemaType(dbType string) (string, *int) {
+	dbType = strings.ToLower(dbType)
+	if strings.HasSuffix(dbType, "[]") {
+		return "", nil
+	}
+	name, _, _ := strings.Cut(dbType, "(")
+	name, _, _ = strings.Cut(name, " ")
+
+	switch {
+	case name == "boolean" || name == "bool" || strings.HasPrefix(dbType, "tinyint(1)"):
+		return "boolean", nil
+	case slices.Contains(integerTypes, name):
+		return "integer", nil
+	case slices.Contains(numberTypes, name):
+		if match := decimalsPattern.FindStringSubmatch(dbType); match != nil {
+			decimals, _ := strconv.Atoi(match[1])
+			return "number", &decimals
+		}
+		return "number", nil
+	case name == "date":
+		return "date", nil
+	case name == "timestamp" || name == "datetime":
+		return "datetime", nil
+	}
+	return "", nil
+}
+
+// parseMySQLEnum returns the labels of an enum('a','b') column type
+func parseMySQLEnum(columnType string) []string {
+	if !strings.HasPrefix(strings.ToLower(columnType), "enum(") {
+		return nil
+	}
+	var values []string
+	var value strings.Builder
+	quoted := false
+	body := columnType[len("enum(") : len(columnType)-1]
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\'' && quoted && i+1 < len(body) && body[i+1] == '\'':
+			value.WriteByte('\'')
+			i++
+		case c == '\'':
+			if quoted {
+				values = append(values, value.String())
+				value.Reset()
+			}
+			quoted = !quoted
+		case c == '\\' && quoted && i+1 < len(body):
+			value.WriteByte(body[i+1])
+			i++
+		case quoted:
+			value.WriteByte(c)
+		}
+	}
+	return values
+}
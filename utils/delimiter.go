@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// delimiterCandidates are the delimiters DetectDelimiter chooses from, in
+// order of preference when they fit equally well
+var delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+// multiDelimiterCandidates are the multi-character delimiters of legacy
+// exports that DetectMultiDelimiter looks for, in order of preference
+var multiDelimiterCandidates = []string{"~|~", "||"}
+
+// delimiterSampleSize is how much of a file DetectDelimiter looks at
+const delimiterSampleSize = 8 * 1024
+
+// delimiterSample returns the first few KB of content, in whole lines
+func delimiterSample(content string) string {
+	if len(content) <= delimiterSampleSize {
+		return content
+	}
+
+	sample := content[:delimiterSampleSize]
+	// Drop the last, probably cut-off, line
+	if i := strings.LastIndexByte(sample, '\n'); i > 0 {
+		sample = sample[:i]
+	}
+	return sample
+}
+
+// DetectDelimiter guesses the delimiter of CSV content from its first few KB.
+// It picks the candidate that appears in the header and the same number of
+// times in most records (quoted text is ignored), defaulting to a comma.
+func DetectDelimiter(content string) rune {
+	sample := delimiterSample(content)
+
+	// Count each candidate per record, outside quoted fields
+	var counts []map[rune]int
+	current := make(map[rune]int)
+	inQuotes := false
+	for _, r := range sample {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == '\n' && !inQuotes:
+			counts = append(counts, current)
+			current = make(map[rune]int)
+		case !inQuotes:
+			current[r]++
+		}
+	}
+	counts = append(counts, current)
+
+	// Skip blank lines and single-column preambles before the header
+	for len(counts) > 1 && !hasCandidate(counts[0]) {
+		counts = counts[1:]
+	}
+
+	best, bestConsistent, bestCount := ',', 0, 0
+	for _, candidate := range delimiterCandidates {
+		headerCount := counts[0][candidate]
+		if headerCount == 0 {
+			continue
+		}
+
+		consistent := 0
+		for _, record := range counts {
+			if record[candidate] == headerCount {
+				consistent++
+			}
+		}
+		if consistent > bestConsistent || (consistent == bestConsistent && headerCount > bestCount) {
+			best, bestConsistent, bestCount = candidate, consistent, headerCount
+		}
+	}
+
+	return best
+}
+
+func hasCandidate(record map[rune]int) bool {
+	for _, candidate := range delimiterCandidates {
+		if record[candidate] > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DetectMultiDelimiter returns the multi-character delimiter of CSV content,
+// like "||" or "~|~", when one appears in the header and the same number of
+// times in most lines of its first few KB (quoted text is ignored), and ""
+// otherwise.
+func DetectMultiDelimiter(content string) string {
+	// Blank out quoted text, keeping the unquoted text of each line
+	var unquoted strings.Builder
+	inQuotes := false
+	for _, r := range delimiterSample(content) {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case !inQuotes || r == '\n':
+			unquoted.WriteRune(r)
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(unquoted.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	for _, candidate := range multiDelimiterCandidates {
+		headerCount := strings.Count(lines[0], candidate)
+		if headerCount == 0 {
+			continue
+		}
+
+		consistent := 0
+		for _, line := range lines {
+			if strings.Count(line, candidate) == headerCount {
+				consistent++
+			}
+		}
+		if consistent*2 > len(lines) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// splitMultiDelimiter pre-tokenizes content separated by a multi-character
+// delimiter, which encoding/csv cannot split on: every occurrence outside a
+// quoted field is replaced by a private-use character that does not occur in
+// content, returned to be used as the single-character delimiter. Line
+// breaks are kept, so line numbers in parse errors still match the file.
+func splitMultiDelimiter(content, delimiter string) (string, rune, error) {
+	var substitute rune
+	for r := rune(0xE000); r <= 0xF8FF; r++ {
+		if !strings.ContainsRune(content, r) {
+			substitute = r
+			break
+		}
+	}
+	if substitute == 0 {
+		return "", 0, fmt.Errorf("cannot split on delimiter %q: the file uses every private-use character", delimiter)
+	}
+
+	var split strings.Builder
+	split.Grow(len(content))
+	fieldStart, inQuotes := true, false
+	for i := 0; i < len(content); {
+		if !inQuotes && strings.HasPrefix(content[i:], delimiter) {
+			split.WriteRune(substitute)
+			i += len(delimiter)
+			fieldStart = true
+			continue
+		}
+
+		c := content[i]
+		switch {
+		case inQuotes && c == '"' && i+1 < len(content) && content[i+1] == '"':
+			// An escaped quote inside a quoted field
+			split.WriteString(`""`)
+			i += 2
+			continue
+		case c == '"' && (inQuotes || fieldStart):
+			inQuotes = !inQuotes
+		}
+		// Leading spaces are trimmed, so a quote after them still opens a field
+		fieldStart = !inQuotes && (c == '\n' || (fieldStart && c == ' '))
+		split.WriteByte(c)
+		i++
+	}
+
+	return split.String(), substitute, nil
+}
+
+// ParseDelimiter reads a delimiter given on the command line: a single
+// character, or "tab" / "\t" for a tab
+func ParseDelimiter(value string) (rune, error) {
+	switch value {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+
+	r, size := utf8.DecodeRuneInString(value)
+	if value == "" || size != len(value) || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q: must be a single character other than a quote or line break", value)
+	}
+
+	return r, nil
+}
+
+// ParseInputDelimiter reads a source delimiter given on the command line,
+// which unlike an output delimiter may be several characters, e.g. "||" or
+// "~|~". It returns either the single character or the multi-character
+// delimiter.
+func ParseInputDelimiter(value string) (rune, string, error) {
+	switch value {
+	case "tab", `\t`:
+		return '\t', "", nil
+	}
+
+	if utf8.RuneCountInString(value) > 1 {
+		if strings.ContainsAny(value, "\"\r\n") {
+			return 0, "", fmt.Errorf("invalid delimiter %q: must not contain a quote or line break", value)
+		}
+		return 0, value, nil
+	}
+
+	r, err := ParseDelimiter(value)
+	return r, "", err
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RunDuckDB runs a SQL script with the duckdb client on an in-memory
+// database. Tables too large for memory spill to a temporary directory,
+// removed afterwards. Scripts return their results by copying them to files.
+func RunDuckDB(script string) error {
+	spill, err := os.MkdirTemp("", "duckdb-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(spill)
+
+	script = "SET temp_directory = " + quoteSQLString(spill) + ";\n" + script
+	cmd := exec.Command("duckdb", "-bail", "-batch", ":memory:")
+	cmd.Stdin = strings.NewReader(script)
+	_, err = runClient(cmd)
+	return err
+}
+
+// QuoteDuckDBString quotes a DuckDB string literal
+func QuoteDuckDBString(value string) string {
+	return quoteSQLString(value)
+}
+
+// QuoteDuckDBIdentifier quotes a DuckDB column or table name
+func QuoteDuckDBIdentifier(name string) string {
+	return quotePostgresIdentifier(name)
+}
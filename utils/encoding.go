@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// DetectEncoding guesses the character encoding of content: UTF-16 when it
+// starts with a UTF-16 byte order mark, UTF-8 when it is valid UTF-8, and
+// otherwise Windows-1252, the usual encoding of legacy Windows exports (and a
+// superset of the printable ISO-8859-1 characters).
+func DetectEncoding(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}), bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return "utf-16"
+	case utf8.Valid(content):
+		return "utf-8"
+	default:
+		return "windows-1252"
+	}
+}
+
+// LookupEncoding returns the encoding with the given IANA name or alias,
+// e.g. "windows-1252", "latin1" or "utf-16"
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8":
+		return unicode.UTF8, nil
+	case "utf-16", "utf16":
+		// Endianness comes from the byte order mark
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+	}
+
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+
+	return enc, nil
+}
+
+// DecodeText converts content from the named encoding to UTF-8. An empty
+// name detects the encoding with DetectEncoding.
+func DecodeText(content []byte, name string) (string, error) {
+	if name == "" {
+		name = DetectEncoding(content)
+	}
+
+	enc, err := LookupEncoding(name)
+	if err != nil {
+		return "", err
+	}
+	if enc == unicode.UTF8 {
+		return string(content), nil
+	}
+
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), content)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %v", name, err)
+	}
+
+	return string(decoded), nil
+}
+
+// encodingWriter wraps w so UTF-8 text written to it is converted to the
+// named encoding. Characters the encoding cannot represent fail the write.
+// The returned writer must be closed to flush the last bytes.
+func encodingWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	if name == "" {
+		return nopCloser{w}, nil
+	}
+
+	enc, err := LookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	if enc == unicode.UTF8 {
+		return nopCloser{w}, nil
+	}
+
+	return transform.NewWriter(w, enc.NewEncoder()), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
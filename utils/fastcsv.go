@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CSV parser backends of CSVOptions.Parser
+const (
+	// ParserStandard parses with encoding/csv
+	ParserStandard = "standard"
+	// ParserFast parses with parseFast
+	ParserFast = "fast"
+)
+
+// parseFast reads all records of content like encoding/csv configured by
+// newCSVReader would, with the same results and errors, but without copying:
+// fields are slices of content, except quoted fields with escaped quotes or
+// line breaks. project, when set, is applied to every record.
+func parseFast(content string, options CSVOptions, project func(record []string) []string) ([][]string, error) {
+	delimiter := options.Delimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	if delimiter == options.Comment {
+		return nil, errors.New("csv: invalid field or comment delimiter")
+	}
+
+	p := fastParser{content: content, delimiter: string(delimiter), options: options}
+	fieldsPerRecord := options.FieldsPerRecord
+	var records [][]string
+	for {
+		fields, recordLine, err := p.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if fields == nil {
+			return records, nil
+		}
+
+		// Check or update the expected fields per record
+		if fieldsPerRecord > 0 && len(fields) != fieldsPerRecord {
+			return nil, &csv.ParseError{StartLine: recordLine, Line: recordLine, Column: 1, Err: csv.ErrFieldCount}
+		} else if fieldsPerRecord == 0 {
+			fieldsPerRecord = len(fields)
+		}
+
+		if project != nil {
+			records = append(records, project(fields))
+			p.fields = p.fields[:0]
+		} else {
+			records = append(records, fields)
+		}
+	}
+}
+
+type fastParser struct {
+	content   string
+	delimiter string
+	options   CSVOptions
+	// pos is the offset of the next line and line the number of the last
+	pos, line int
+	// fields is a slab that records are appended to one after the other, so
+	// that they need not be allocated or copied one by one
+	fields []string
+}
+
+// fastSlabSize is how many fields a slab of fastParser holds at least
+const fastSlabSize = 64 * 1024
+
+// addField appends field to the record that started at start of the slab,
+// moving the record to a new slab when this one is full
+func (p *fastParser) addField(start int, field string) int {
+	if len(p.fields) == cap(p.fields) {
+		record := p.fields[start:]
+		p.fields = make([]string, len(record), max(fastSlabSize, 2*len(record)))
+		copy(p.fields, record)
+		start = 0
+	}
+	p.fields = append(p.fields, field)
+	return start
+}
+
+// readLine returns the next line without its line break, as encoding/csv
+// reads it, and whether it had one; ok is false at the end of content
+func (p *fastParser) readLine() (body string, newline, ok bool) {
+	if p.pos >= len(p.content) {
+		return "", false, false
+	}
+
+	rest := p.content[p.pos:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		body, newline = rest[:i], true
+		p.pos += i + 1
+	} else {
+		body = rest
+		p.pos = len(p.content)
+	}
+	p.line++
+	return strings.TrimSuffix(body, "\r"), newline, true
+}
+
+// readRecord returns the fields of the next record and its line number, or
+// nil fields at the end of content
+func (p *fastParser) readRecord() ([]string, int, error) {
+	// Skip empty lines and comments
+	var body string
+	var newline bool
+	for {
+		var ok bool
+		if body, newline, ok = p.readLine(); !ok {
+			return nil, 0, nil
+		}
+		if p.options.Comment != 0 {
+			if r, _ := utf8.DecodeRuneInString(body); r == p.options.Comment {
+				continue
+			}
+		}
+		if body != "" {
+			break
+		}
+	}
+
+	// recordLine is the line the record starts on, bodyLine the line of body
+	recordLine, bodyLine := p.line, p.line
+	start := len(p.fields)
+	record := func() []string {
+		return p.fields[start:len(p.fields):len(p.fields)]
+	}
+	rest := body
+parseField:
+	for {
+		// Leading white space is trimmed, as with TrimLeadingSpace
+		if rest != "" && (rest[0] <= ' ' || rest[0] >= utf8.RuneSelf) {
+			rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		}
+		column := len(body) - len(rest) + 1
+
+		if rest == "" || rest[0] != '"' {
+			// Unquoted field
+			field := rest
+			var i int
+			if len(p.delimiter) == 1 {
+				i = strings.IndexByte(rest, p.delimiter[0])
+			} else {
+				i = strings.Index(rest, p.delimiter)
+			}
+			if i >= 0 {
+				field = rest[:i]
+			}
+			if !p.options.LazyQuotes {
+				if j := strings.IndexByte(field, '"'); j >= 0 {
+					return nil, 0, &csv.ParseError{StartLine: recordLine, Line: bodyLine, Column: column + j, Err: csv.ErrBareQuote}
+				}
+			}
+			start = p.addField(start, field)
+			if i < 0 {
+				return record(), recordLine, nil
+			}
+			rest = rest[i+len(p.delimiter):]
+			continue
+		}
+
+		// Quoted field, possibly spanning lines
+		var field fieldBuilder
+		eof := false
+		rest = rest[1:]
+		for {
+			if i := strings.IndexByte(rest, '"'); i >= 0 {
+				field.add(rest[:i])
+				rest = rest[i+1:]
+				switch {
+				case strings.HasPrefix(rest, `"`):
+					// An escaped quote
+					field.add(`"`)
+					rest = rest[1:]
+				case strings.HasPrefix(rest, p.delimiter):
+					start = p.addField(start, field.String())
+					rest = rest[len(p.delimiter):]
+					continue parseField
+				case rest == "":
+					start = p.addField(start, field.String())
+					return record(), recordLine, nil
+				case p.options.LazyQuotes:
+					// A bare quote
+					field.add(`"`)
+				default:
+					return nil, 0, &csv.ParseError{StartLine: recordLine, Line: bodyLine, Column: len(body) - len(rest), Err: csv.ErrQuote}
+				}
+			} else if (rest != "" || newline) && !eof {
+				// The field goes on on the next line
+				field.add(rest)
+				if newline {
+					field.add("\n")
+				}
+				// A last line of just "\r" reads as the end too
+				if next, nextNewline, ok := p.readLine(); ok && (next != "" || nextNewline) {
+					body, newline, rest = next, nextNewline, next
+					bodyLine = p.line
+				} else {
+					eof, rest = true, ""
+				}
+			} else {
+				// Abrupt end of content
+				if !p.options.LazyQuotes {
+					// The column is past the last line, line break included
+					column := len(body) + 1
+					if newline {
+						column++
+					}
+					return nil, 0, &csv.ParseError{StartLine: recordLine, Line: bodyLine, Column: column, Err: csv.ErrQuote}
+				}
+				start = p.addField(start, field.String())
+				return record(), recordLine, nil
+			}
+		}
+	}
+}
+
+// fieldBuilder joins the pieces of a quoted field, copying only when there
+// is more than one
+type fieldBuilder struct {
+	first   string
+	builder strings.Builder
+	n       int
+}
+
+func (b *fieldBuilder) add(piece string) {
+	switch b.n {
+	case 0:
+		b.first = piece
+	case 1:
+		b.builder.WriteString(b.first)
+		fallthrough
+	default:
+		b.builder.WriteString(piece)
+	}
+	b.n++
+}
+
+func (b *fieldBuilder) String() string {
+	if b.n <= 1 {
+		return b.first
+	}
+	return b.builder.String()
+}
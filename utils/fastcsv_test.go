@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// FuzzParseFast checks that parseFast gives the records and errors of
+// encoding/csv, which it replaces on the hot path:
+//
+//	go test ./utils -run '^$' -fuzz FuzzParseFast -fuzztime 1m
+func FuzzParseFast(f *testing.F) {
+	seeds := []string{
+		"",
+		"a,b,c\n1,2,3\n",
+		"a,b,c\r\n1,2,3\r\n",
+		"a,b\n1,2",
+		"a,b\r\n\"x\r\ny\",2\r\n",
+		"a,b\n\"quoted, with comma\",\"with \"\"escaped\"\" quotes\"\n",
+		"a,b\n\"multi\nline\",2\n",
+		"a,b\nx\"y,2\n",
+		"a,b\n\"x\"y,2\n",
+		"a,b\n\"unterminated,2\n",
+		"a,b\n1,2,3\n4\n",
+		"a,b\n\n\n1,2\n",
+		"# comment\na,b\n#1,2\n3,4\n",
+		"a, b ,  c\n  1,\t2, 3\n",
+		"a;b\n1;2\n",
+		"a\tb\n\"1\t\"\t2\n",
+		"a,b\n\"\",\"\"\n,\n",
+		"\ufeffa,b\n1,2\n",
+		"a,b\n1,2\r\r\n3,4\r",
+		"a,b\n\"x\"\"\",\"\"\"y\"\n",
+		"a,b\n\xff\xfe,2\n",
+	}
+	for _, seed := range seeds {
+		for _, lazy := range []bool{false, true} {
+			f.Add(seed, lazy, int8(-1), uint8(0), false)
+			f.Add(seed, lazy, int8(0), uint8(0), true)
+		}
+	}
+
+	delimiters := []rune{',', ';', '\t', '|'}
+	f.Fuzz(func(t *testing.T, content string, lazyQuotes bool, fieldsPerRecord int8, delimiter uint8, comment bool) {
+		options := CSVOptions{
+			LazyQuotes:      lazyQuotes,
+			FieldsPerRecord: int(fieldsPerRecord%4) - 1,
+			Delimiter:       delimiters[int(delimiter)%len(delimiters)],
+		}
+		if comment {
+			options.Comment = '#'
+		}
+
+		want, wantErr := newCSVReader(strings.NewReader(content), options).ReadAll()
+		got, gotErr := parseFast(content, options, nil)
+		if (gotErr == nil) != (wantErr == nil) || (gotErr != nil && gotErr.Error() != wantErr.Error()) {
+			t.Fatalf("options %+v, content %q: error %v, encoding/csv %v", options, content, gotErr, wantErr)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("options %+v, content %q: records %q, encoding/csv %q", options, content, got, want)
+		}
+	})
+}
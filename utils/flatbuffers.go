@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/binary"
+	"slices"
+)
+
+// Arrow IPC metadata is serialized as flatbuffers. fbBuilder lays tables out
+// front to back: each table is preceded by its vtable and followed by the
+// strings, tables and vectors it references, so every offset points forward.
+
+type fbTable []fbField
+
+// fbField is a table field in the given vtable slot. Values are bool, uint8,
+// int16, int32, int64, string, fbTable, []fbTable or fbStructs.
+type fbField struct {
+	Slot  int
+	Value any
+}
+
+// fbStructs is a vector of inline structs, already encoded, with 8-byte alignment
+type fbStructs struct {
+	Count int
+	Data  []byte
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+// buildFlatbuffer encodes root as a flatbuffer, padded to a multiple of 8 bytes
+func buildFlatbuffer(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	pos := b.table(root)
+	binary.LittleEndian.PutUint32(b.buf, uint32(pos))
+	b.padTo(8, 0)
+	return b.buf
+}
+
+// padTo pads until the next write position plus offset is a multiple of align
+func (b *fbBuilder) padTo(align, offset int) {
+	for (len(b.buf)+offset)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func fbSize(value any) int {
+	switch value.(type) {
+	case bool, uint8:
+		return 1
+	case int16:
+		return 2
+	case int64:
+		return 8
+	}
+	return 4 // int32 and offsets
+}
+
+func (b *fbBuilder) table(t fbTable) int {
+	slots := 0
+	for _, field := range t {
+		slots = max(slots, field.Slot+1)
+	}
+
+	b.padTo(2, 0)
+	vtablePos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 4+2*slots)...)
+
+	// Start the table 4 bytes before an 8-byte boundary so that the fields
+	// after its vtable offset are aligned when written largest first
+	b.padTo(8, 4)
+	tablePos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(tablePos-vtablePos))
+
+	fields := slices.Clone(t)
+	slices.SortStableFunc(fields, func(a, c fbField) int { return cmp.Compare(fbSize(c.Value), fbSize(a.Value)) })
+
+	type reference struct {
+		pos   int
+		value any
+	}
+	var references []reference
+	for _, field := range fields {
+		binary.LittleEndian.PutUint16(b.buf[vtablePos+4+2*field.Slot:], uint16(len(b.buf)-tablePos))
+		switch v := field.Value.(type) {
+		case bool:
+			if v {
+				b.buf = append(b.buf, 1)
+			} else {
+				b.buf = append(b.buf, 0)
+			}
+		case uint8:
+			b.buf = append(b.buf, v)
+		case int16:
+			b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(v))
+		case int32:
+			b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v))
+		case int64:
+			b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(v))
+		default:
+			references = append(references, reference{len(b.buf), v})
+			b.buf = append(b.buf, 0, 0, 0, 0)
+		}
+	}
+	binary.LittleEndian.PutUint16(b.buf[vtablePos:], uint16(4+2*slots))
+	binary.LittleEndian.PutUint16(b.buf[vtablePos+2:], uint16(len(b.buf)-tablePos))
+
+	for _, ref := range references {
+		b.patch(ref.pos, b.child(ref.value))
+	}
+	return tablePos
+}
+
+// patch stores the offset from pos to target at pos
+func (b *fbBuilder) patch(pos, target int) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+func (b *fbBuilder) child(value any) int {
+	switch v := value.(type) {
+	case string:
+		b.padTo(4, 0)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(b.buf, v...)
+		b.buf = append(b.buf, 0)
+		return pos
+	case fbTable:
+		return b.table(v)
+	case []fbTable:
+		b.padTo(4, 0)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		elements := len(b.buf)
+		b.buf = append(b.buf, make([]byte, 4*len(v))...)
+		for i, table := range v {
+			b.patch(elements+4*i, b.table(table))
+		}
+		return pos
+	case fbStructs:
+		b.padTo(8, 4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v.Count))
+		b.buf = append(b.buf, v.Data...)
+		return pos
+	}
+	panic("flatbuffers: unsupported value")
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	config "github.com/ashr-tech/csv-migration-tools/config"
+)
+
+// gcsClient returns the API endpoint and an authorized client.
+// STORAGE_EMULATOR_HOST selects an emulator such as fake-gcs-server, which
+// is used without credentials like the official client libraries do.
+func gcsClient() (string, *googleClient, error) {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return strings.TrimSuffix(host, "/"), &googleClient{}, nil
+	}
+	client, err := newGoogleClient(config.GOOGLE_STORAGE_SCOPE)
+	return config.GOOGLE_STORAGE_ENDPOINT, client, err
+}
+
+// gcsObject splits a gs://bucket/object URL
+func gcsObject(location *url.URL) (string, string, error) {
+	object := strings.TrimPrefix(location.Path, "/")
+	if object == "" {
+		return "", "", fmt.Errorf("gs://%s has no object name", location.Host)
+	}
+	return location.Host, object, nil
+}
+
+// readGCS downloads an object through the Cloud Storage JSON API,
+// authenticated with Application Default Credentials
+func readGCS(location *url.URL) ([]byte, error) {
+	bucket, object, err := gcsObject(location)
+	if err != nil {
+		return nil, err
+	}
+	apiEndpoint, client, err := gcsClient()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", apiEndpoint, url.PathEscape(bucket), url.PathEscape(object))
+	return client.send(http.MethodGet, endpoint, "", nil)
+}
+
+// writeGCS uploads an object in a single request, replacing an existing one
+func writeGCS(location *url.URL, data []byte) error {
+	bucket, object, err := gcsObject(location)
+	if err != nil {
+		return err
+	}
+	apiEndpoint, client, err := gcsClient()
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", apiEndpoint, url.PathEscape(bucket), url.QueryEscape(object))
+	_, err = client.send(http.MethodPost, endpoint, "application/octet-stream", data)
+	return err
+}
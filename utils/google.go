@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	config "github.com/ashr-tech/csv-migration-tools/config"
+)
+
+type googleClient struct {
+	token string
+}
+
+// googleCredentials is a service account key or the user credentials saved
+// by "gcloud auth application-default login"
+type googleCredentials struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// newGoogleClient gets an access token for scope from Application Default
+// Credentials: the key file named by GOOGLE_APPLICATION_CREDENTIALS, else the
+// gcloud application default credentials, else the metadata server of the
+// Compute Engine, Cloud Run or GKE instance the tool runs on
+func newGoogleClient(scope string) (*googleClient, error) {
+	keyFile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if keyFile == "" {
+		if gcloudFile := gcloudCredentialsFile(); gcloudFile != "" {
+			if _, err := os.Stat(gcloudFile); err == nil {
+				keyFile = gcloudFile
+			}
+		}
+	}
+	if keyFile == "" {
+		token, err := metadataToken(scope)
+		if err != nil {
+			return nil, fmt.Errorf("no Google credentials: set GOOGLE_APPLICATION_CREDENTIALS or run gcloud auth application-default login (%v)", err)
+		}
+		return &googleClient{token: token}, nil
+	}
+
+	// Read directly, as credentials are local files and LoadJSON can itself
+	// need a client for storage URLs
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Google credentials: %v", err)
+	}
+	var key googleCredentials
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to load Google credentials: %v", err)
+	}
+
+	var token string
+	switch key.Type {
+	case "service_account", "":
+		token, err = serviceAccountToken(key, scope)
+	case "authorized_user":
+		token, err = requestToken(config.GOOGLE_TOKEN_ENDPOINT, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {key.ClientID},
+			"client_secret": {key.ClientSecret},
+			"refresh_token": {key.RefreshToken},
+		})
+	default:
+		return nil, fmt.Errorf("unsupported Google credentials type %q in %s", key.Type, keyFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &googleClient{token: token}, nil
+}
+
+// gcloudCredentialsFile is where gcloud saves application default credentials
+func gcloudCredentialsFile() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// serviceAccountToken exchanges a signed service account JWT for an access token
+func serviceAccountToken(key googleCredentials, scope string) (string, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("service account key has no private key")
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %v", err)
+	}
+	privateKey, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("service account private key is not an RSA key")
+	}
+
+	now := time.Now()
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := encode(map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + encode(map[string]any{
+		"iss":   key.ClientEmail,
+		"scope": scope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %v", err)
+	}
+	assertion := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	return requestToken(key.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// requestToken posts an OAuth token request and returns the access token
+func requestToken(tokenURI string, form url.Values) (string, error) {
+	resp, err := http.PostForm(tokenURI, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return decodeToken(resp)
+}
+
+// metadataToken asks the instance metadata server for a token of the
+// instance's service account; GCE_METADATA_HOST overrides its address
+func metadataToken(scope string) (string, error) {
+	endpoint := config.GOOGLE_METADATA_ENDPOINT
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		endpoint = strings.Replace(endpoint, "metadata.google.internal", host, 1)
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint+"?scopes="+url.QueryEscape(scope), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	// Off Google Cloud the server does not exist, so do not wait long
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("metadata server unavailable: %v", err)
+	}
+	defer resp.Body.Close()
+	return decodeToken(resp)
+}
+
+func decodeToken(resp *http.Response) (string, error) {
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to read access token: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("failed to get access token: %s %s", resp.Status, token.Error)
+	}
+	return token.AccessToken, nil
+}
+
+// do sends an authorized JSON request and decodes the response into result
+func (c *googleClient) do(method, endpoint string, body, result any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(method, endpoint, "application/json", data)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(resp, result)
+}
+
+// send sends an authorized request and returns the response body
+func (c *googleClient) send(method, endpoint, contentType string, body []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, endpoint, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return data, nil
+}
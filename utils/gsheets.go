@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	config "github.com/ashr-tech/csv-migration-tools/config"
+)
+
+const googleSheetsURLPrefix = "https://docs.google.com/spreadsheets/d/"
+
+// IsGoogleSheet reports whether path is a Google Sheets URL, optionally
+// followed by "!<sheet>"
+func IsGoogleSheet(path string) bool {
+	return strings.HasPrefix(path, googleSheetsURLPrefix)
+}
+
+// ReadGoogleSheet reads the values of one sheet of a Google Sheet. The sheet
+// is selected by "!<title>", else by the URL's #gid=, else it is the first
+// sheet. Authentication uses the service account key file named by
+// GOOGLE_APPLICATION_CREDENTIALS; share the sheet with its client_email.
+func ReadGoogleSheet(sheetURL string) ([][]string, error) {
+	client, spreadsheetID, title, err := openGoogleSheet(sheetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Values [][]string `json:"values"`
+	}
+	endpoint := fmt.Sprintf("%s/%s/values/%s", config.GOOGLE_SHEETS_ENDPOINT, spreadsheetID, url.PathEscape(quoteSheetTitle(title)))
+	if err := client.do(http.MethodGet, endpoint, nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to read Google Sheet: %v", err)
+	}
+
+	return result.Values, nil
+}
+
+// WriteGoogleSheet replaces the contents of one sheet of a Google Sheet with
+// records, selecting the sheet like ReadGoogleSheet. Values are written as
+// plain text, without formula or number parsing.
+func WriteGoogleSheet(sheetURL string, records [][]string) error {
+	client, spreadsheetID, title, err := openGoogleSheet(sheetURL)
+	if err != nil {
+		return err
+	}
+
+	rangeName := url.PathEscape(quoteSheetTitle(title))
+	clearURL := fmt.Sprintf("%s/%s/values/%s:clear", config.GOOGLE_SHEETS_ENDPOINT, spreadsheetID, rangeName)
+	if err := client.do(http.MethodPost, clearURL, struct{}{}, nil); err != nil {
+		return fmt.Errorf("failed to clear Google Sheet: %v", err)
+	}
+
+	updateURL := fmt.Sprintf("%s/%s/values/%s?valueInputOption=RAW", config.GOOGLE_SHEETS_ENDPOINT, spreadsheetID, rangeName)
+	body := map[string]any{"values": records}
+	if err := client.do(http.MethodPut, updateURL, body, nil); err != nil {
+		return fmt.Errorf("failed to write Google Sheet: %v", err)
+	}
+
+	return nil
+}
+
+// openGoogleSheet authenticates and resolves the spreadsheet ID and sheet title of a URL
+func openGoogleSheet(sheetURL string) (*googleClient, string, string, error) {
+	sheetURL, title, _ := strings.Cut(sheetURL, "!")
+
+	parsed, err := url.Parse(sheetURL)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid Google Sheet URL: %v", err)
+	}
+	spreadsheetID, _, _ := strings.Cut(strings.TrimPrefix(sheetURL, googleSheetsURLPrefix), "/")
+	spreadsheetID, _, _ = strings.Cut(spreadsheetID, "#")
+	if spreadsheetID == "" {
+		return nil, "", "", fmt.Errorf("invalid Google Sheet URL %q", sheetURL)
+	}
+
+	client, err := newGoogleClient(config.GOOGLE_SHEETS_SCOPE)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if title != "" {
+		return client, spreadsheetID, title, nil
+	}
+
+	// Find the sheet title from the gid, or take the first sheet
+	var spreadsheet struct {
+		Sheets []struct {
+			Properties struct {
+				SheetID int    `json:"sheetId"`
+				Title   string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	endpoint := fmt.Sprintf("%s/%s?fields=sheets.properties", config.GOOGLE_SHEETS_ENDPOINT, spreadsheetID)
+	if err := client.do(http.MethodGet, endpoint, nil, &spreadsheet); err != nil {
+		return nil, "", "", fmt.Errorf("failed to read Google Sheet: %v", err)
+	}
+	if len(spreadsheet.Sheets) == 0 {
+		return nil, "", "", fmt.Errorf("Google Sheet has no sheets")
+	}
+
+	gid := -1
+	if fragment, err := url.ParseQuery(parsed.Fragment); err == nil && fragment.Get("gid") != "" {
+		if gid, err = strconv.Atoi(fragment.Get("gid")); err != nil {
+			return nil, "", "", fmt.Errorf("invalid gid in %q", sheetURL)
+		}
+	}
+	for _, sheet := range spreadsheet.Sheets {
+		if gid == -1 || sheet.Properties.SheetID == gid {
+			return client, spreadsheetID, sheet.Properties.Title, nil
+		}
+	}
+
+	return nil, "", "", fmt.Errorf("Google Sheet has no sheet with gid %d", gid)
+}
+
+// quoteSheetTitle quotes a sheet title for use as an A1 range
+func quoteSheetTitle(title string) string {
+	return "'" + strings.ReplaceAll(title, "'", "''") + "'"
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// httpHeaders are sent with every request for http:// and https:// sources
+var httpHeaders = http.Header{}
+
+// AddHTTPHeader adds a "Name: value" header to the requests for http:// and
+// https:// sources, such as an Authorization header. $VAR and ${VAR} in the
+// value are expanded from the environment, so secrets can stay out of the
+// shell history.
+func AddHTTPHeader(header string) error {
+	name, value, found := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid header %q: expected Name: value", header)
+	}
+	httpHeaders.Add(textproto.CanonicalMIMEHeaderKey(name), os.ExpandEnv(strings.TrimSpace(value)))
+	return nil
+}
+
+// readHTTP downloads a file published by a web server. Redirects are
+// followed, but not with the headers to another host.
+func readHTTP(location *url.URL) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range httpHeaders {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
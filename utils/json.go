@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultJSONSeparator joins the keys of nested JSON objects into one column name
+const DefaultJSONSeparator = "."
+
+// IsJSON reports whether path is a JSON array or JSON Lines file
+func IsJSON(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// ReadJSONRecords converts an array of JSON objects, or JSON Lines with one
+// object per line, into CSV-style records. Nested objects are flattened by
+// joining their keys with separator ({"address": {"city": "x"}} becomes the
+// column "address.city"), arrays are kept as JSON text and null is empty. The
+// header lists every key in order of first appearance.
+func ReadJSONRecords(content string, separator string) ([][]string, error) {
+	if separator == "" {
+		separator = DefaultJSONSeparator
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var objects []json.RawMessage
+	if strings.HasPrefix(strings.TrimSpace(content), "[") {
+		if err := decoder.Decode(&objects); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %v", err)
+		}
+	} else {
+		for {
+			var object json.RawMessage
+			if err := decoder.Decode(&object); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to parse JSON line %d: %v", len(objects)+1, err)
+			}
+			objects = append(objects, object)
+		}
+	}
+
+	var header []string
+	columns := make(map[string]int)
+	rows := make([]map[string]string, 0, len(objects))
+	for i, object := range objects {
+		row := make(map[string]string)
+		var keys []string
+		if err := flattenJSON(object, "", separator, row, &keys); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON record %d: %v", i+1, err)
+		}
+		for _, key := range keys {
+			if _, exists := columns[key]; !exists {
+				columns[key] = len(header)
+				header = append(header, key)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if len(header) == 0 {
+		return nil, fmt.Errorf("JSON input has no objects")
+	}
+
+	records := make([][]string, 0, len(rows)+1)
+	records = append(records, header)
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for key, value := range row {
+			record[columns[key]] = value
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// flattenJSON adds the fields of a JSON object to row, prefixing nested keys,
+// and appends the keys to keys in document order
+func flattenJSON(object json.RawMessage, prefix, separator string, row map[string]string, keys *[]string) error {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("expected an object, got %s", object)
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := prefix + token.(string)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+
+		switch value[0] {
+		case '{':
+			if err := flattenJSON(value, key+separator, separator, row, keys); err != nil {
+				return err
+			}
+			continue
+		case '[':
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, value); err != nil {
+				return err
+			}
+			row[key] = compacted.String()
+		case 'n':
+			row[key] = ""
+		case '"':
+			var text string
+			if err := json.Unmarshal(value, &text); err != nil {
+				return err
+			}
+			row[key] = text
+		default:
+			// Numbers and booleans keep their JSON spelling
+			row[key] = string(value)
+		}
+		*keys = append(*keys, key)
+	}
+
+	return nil
+}
+
+// IsJSONL reports whether path is a JSON Lines file
+func IsJSONL(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// writeJSONL writes one JSON object per row, keyed by the header names in
+// header order. Values of number, integer and boolean columns in
+// options.ColumnTypes are written as JSON numbers and booleans, or null when
+// empty; everything else is a string.
+func writeJSONL(w io.Writer, header []string, rows [][]string, options WriteOptions) error {
+	lineEnding := "\n"
+	if options.CRLF {
+		lineEnding = "\r\n"
+	}
+
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+	for _, row := range rows {
+		line.Reset()
+		line.WriteByte('{')
+		for i, key := range header {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			if err := encoder.Encode(key); err != nil {
+				return err
+			}
+			line.Truncate(line.Len() - 1) // Encode appends a newline
+			line.WriteByte(':')
+
+			value := ""
+			if i < len(row) {
+				value = row[i]
+			}
+			if raw, ok := typedJSONValue(value, options.ColumnTypes[key]); ok {
+				line.WriteString(raw)
+				continue
+			}
+			if err := encoder.Encode(value); err != nil {
+				return err
+			}
+			line.Truncate(line.Len() - 1)
+		}
+		line.WriteString("}" + lineEnding)
+
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// typedJSONValue returns the JSON literal for value in a typed column, or false
+// when it should be written as a string
+func typedJSONValue(value, columnType string) (string, bool) {
+	switch columnType {
+	case "number", "integer":
+		if value == "" {
+			return "null", true
+		}
+		if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+			return value, true
+		}
+	case "boolean":
+		if value == "" {
+			return "null", true
+		}
+		if b, err := strconv.ParseBool(value); err == nil {
+			return strconv.FormatBool(b), true
+		}
+	}
+	return "", false
+}
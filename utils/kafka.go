@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// kafkaKeySeparator separates the key from the message on kcat's input. The
+// unit separator does not occur in keys taken from CSV values in practice.
+const kafkaKeySeparator = "\x1f"
+
+// ProduceKafka publishes every data row of records to a Kafka topic as one
+// JSON message, through the kcat (formerly kafkacat) client. brokers is a
+// comma-separated host:port list; security settings such as SASL come from
+// kcat's configuration file, ~/.config/kcat.conf or $KCAT_CONFIG. Values of
+// number, integer and boolean columns in columnTypes are JSON literals, as in
+// .jsonl output. With keyColumn, its value keys the message, so rows with the
+// same key keep their order on one partition.
+func ProduceKafka(brokers, topic, keyColumn string, records [][]string, columnTypes map[string]string) error {
+	if len(records) == 0 {
+		return fmt.Errorf("no records to publish")
+	}
+
+	keyIdx := -1
+	if keyColumn != "" {
+		if keyIdx = slices.Index(records[0], keyColumn); keyIdx == -1 {
+			return fmt.Errorf("unknown key column %q", keyColumn)
+		}
+	}
+
+	var input bytes.Buffer
+	options := WriteOptions{ColumnTypes: columnTypes}
+	for rowIdx, row := range records[1:] {
+		if keyIdx >= 0 {
+			key := ""
+			if keyIdx < len(row) {
+				key = row[keyIdx]
+			}
+			if strings.ContainsAny(key, kafkaKeySeparator+"\n") {
+				return fmt.Errorf("row %d: key %q contains a control character", rowIdx+1, key)
+			}
+			input.WriteString(key + kafkaKeySeparator)
+		}
+		// Messages are single lines: JSON escapes line breaks in values
+		if err := writeJSONL(&input, records[0], [][]string{row}, options); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"-P", "-b", brokers, "-t", topic}
+	if keyIdx >= 0 {
+		args = append(args, "-K", kafkaKeySeparator)
+	}
+	cmd := exec.Command("kcat", args...)
+	cmd.Stdin = &input
+	_, err := runClient(cmd)
+	return err
+}
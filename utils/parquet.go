@@ -0,0 +1,572 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	parquetInt32    = 1
+	parquetInt96    = 3
+	parquetFloat    = 4
+	parquetFixedLen = 7
+
+	parquetRequired = 0
+	parquetRepeated = 2
+
+	parquetPlainDictionary = 2
+	parquetRLEDictionary   = 8
+
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+
+	parquetUncompressed = 0
+	parquetSnappy       = 1
+	parquetGzip         = 2
+)
+
+// parquetColumn is a leaf column of a flat Parquet schema
+type parquetColumn struct {
+	Name      string
+	Type      int64
+	Length    int   // byte length of FIXED_LEN_BYTE_ARRAY values
+	MaxDef    int   // 1 for optional columns, 0 for required ones
+	Converted int64 // converted type, -1 when absent
+	Logical   thriftValues
+	Scale     int
+}
+
+// ReadParquet reads a Parquet file with a flat schema into records, with the
+// column names as header. Values are formatted as text: dates as YYYY-MM-DD,
+// timestamps as YYYY-MM-DD HH:MM:SS in UTC, decimals with their scale, and
+// nulls as empty strings. Uncompressed, snappy and gzip files with PLAIN or
+// dictionary encoded pages are supported, which covers the defaults of Spark,
+// pandas and DuckDB.
+func ReadParquet(path string) ([][]string, error) {
+	data, err := readData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		return nil, fmt.Errorf("%s is not a Parquet file", path)
+	}
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerSize > len(data)-12 {
+		return nil, fmt.Errorf("%s has a corrupt footer", path)
+	}
+	reader := &thriftReader{data: data[len(data)-8-footerSize : len(data)-8]}
+	metadata, err := reader.readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet metadata of %s: %v", path, err)
+	}
+
+	columns, err := parquetColumns(metadata.list(2))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	records := [][]string{header}
+
+	for _, item := range metadata.list(4) {
+		rowGroup, _ := item.(thriftValues)
+		numRows := int(rowGroup.int(3))
+		chunks := rowGroup.list(1)
+		if len(chunks) != len(columns) {
+			return nil, fmt.Errorf("%s has a row group with %d columns, expected %d", path, len(chunks), len(columns))
+		}
+
+		rows := make([][]string, numRows)
+		for r := range rows {
+			rows[r] = make([]string, len(columns))
+		}
+		for i, item := range chunks {
+			chunk, _ := item.(thriftValues)
+			values, err := readParquetChunk(data, chunk.strct(3), columns[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to read column %s of %s: %v", columns[i].Name, path, err)
+			}
+			if len(values) != numRows {
+				return nil, fmt.Errorf("column %s of %s has %d values, expected %d", columns[i].Name, path, len(values), numRows)
+			}
+			for r, value := range values {
+				rows[r][i] = value
+			}
+		}
+		records = append(records, rows...)
+	}
+
+	return records, nil
+}
+
+// readParquetHeader reads the column names of a local Parquet file from its
+// footer, without reading the data before it
+func readParquetHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	tail := make([]byte, 8)
+	if size < 12 {
+		return nil, fmt.Errorf("%s is not a Parquet file", path)
+	}
+	if _, err := file.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+	if string(tail[4:]) != "PAR1" {
+		return nil, fmt.Errorf("%s is not a Parquet file", path)
+	}
+	footerSize := int64(binary.LittleEndian.Uint32(tail))
+	if footerSize > size-12 {
+		return nil, fmt.Errorf("%s has a corrupt footer", path)
+	}
+	footer := make([]byte, footerSize)
+	if _, err := file.ReadAt(footer, size-8-footerSize); err != nil {
+		return nil, err
+	}
+	reader := &thriftReader{data: footer}
+	metadata, err := reader.readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet metadata of %s: %v", path, err)
+	}
+
+	columns, err := parquetColumns(metadata.list(2))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	return header, nil
+}
+
+// parquetColumns reads the leaf columns of a schema, which must be flat
+func parquetColumns(schema []any) ([]parquetColumn, error) {
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("empty Parquet schema")
+	}
+
+	var columns []parquetColumn
+	for _, item := range schema[1:] {
+		element, _ := item.(thriftValues)
+		if element.int(5) > 0 || element.int(3) == parquetRepeated {
+			return nil, fmt.Errorf("nested or repeated column %s is not supported", element.str(4))
+		}
+
+		column := parquetColumn{
+			Name:      element.str(4),
+			Type:      element.int(1),
+			Length:    int(element.int(2)),
+			MaxDef:    1,
+			Converted: -1,
+			Logical:   element.strct(10),
+			Scale:     int(element.int(7)),
+		}
+		if element.has(3) && element.int(3) == parquetRequired {
+			column.MaxDef = 0
+		}
+		if element.has(6) {
+			column.Converted = element.int(6)
+		}
+		if decimal := column.Logical.strct(5); decimal != nil {
+			column.Scale = int(decimal.int(1))
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// readParquetChunk decodes all values of one column chunk
+func readParquetChunk(data []byte, meta thriftValues, column parquetColumn) ([]string, error) {
+	start := meta.int(9)
+	if meta.has(11) && meta.int(11) > 0 && meta.int(11) < start {
+		start = meta.int(11)
+	}
+	end := start + meta.int(7)
+	if start < 4 || end > int64(len(data)) {
+		return nil, fmt.Errorf("column chunk is out of bounds")
+	}
+	codec := meta.int(4)
+	numValues := int(meta.int(5))
+
+	var dictionary []string
+	values := make([]string, 0, numValues)
+	reader := &thriftReader{data: data[:end], pos: int(start)}
+	for len(values) < numValues && reader.pos < int(end) {
+		header, err := reader.readStruct()
+		if err != nil {
+			return nil, err
+		}
+		size := int(header.int(3))
+		if reader.pos+size > int(end) {
+			return nil, fmt.Errorf("page is out of bounds")
+		}
+		page := data[reader.pos : reader.pos+size]
+		reader.pos += size
+
+		switch header.int(1) {
+		case parquetDictionaryPage:
+			page, err = decompressParquet(page, codec)
+			if err != nil {
+				return nil, err
+			}
+			count := int(header.strct(7).int(1))
+			if dictionary, err = decodeParquetPlain(page, count, column); err != nil {
+				return nil, err
+			}
+
+		case parquetDataPage:
+			page, err = decompressParquet(page, codec)
+			if err != nil {
+				return nil, err
+			}
+			dataHeader := header.strct(5)
+			count := int(dataHeader.int(1))
+
+			defined := make([]bool, count)
+			if column.MaxDef > 0 {
+				if len(page) < 4 {
+					return nil, fmt.Errorf("page is truncated")
+				}
+				length := int(binary.LittleEndian.Uint32(page))
+				if 4+length > len(page) {
+					return nil, fmt.Errorf("page is truncated")
+				}
+				levels, err := decodeRLE(page[4:4+length], 1, count)
+				if err != nil {
+					return nil, err
+				}
+				for i, level := range levels {
+					defined[i] = level == 1
+				}
+				page = page[4+length:]
+			} else {
+				for i := range defined {
+					defined[i] = true
+				}
+			}
+
+			pageValues, err := decodeParquetValues(page, dataHeader.int(2), defined, dictionary, column)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, pageValues...)
+
+		case parquetDataPageV2:
+			dataHeader := header.strct(8)
+			count := int(dataHeader.int(1))
+			repLength, defLength := int(dataHeader.int(6)), int(dataHeader.int(5))
+			if repLength+defLength > len(page) {
+				return nil, fmt.Errorf("page is truncated")
+			}
+
+			defined := make([]bool, count)
+			if column.MaxDef > 0 {
+				levels, err := decodeRLE(page[repLength:repLength+defLength], 1, count)
+				if err != nil {
+					return nil, err
+				}
+				for i, level := range levels {
+					defined[i] = level == 1
+				}
+			} else {
+				for i := range defined {
+					defined[i] = true
+				}
+			}
+
+			page = page[repLength+defLength:]
+			if compressed, exists := dataHeader.bool(7); !exists || compressed {
+				if page, err = decompressParquet(page, codec); err != nil {
+					return nil, err
+				}
+			}
+
+			pageValues, err := decodeParquetValues(page, dataHeader.int(4), defined, dictionary, column)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, pageValues...)
+		}
+	}
+
+	return values, nil
+}
+
+func decompressParquet(page []byte, codec int64) ([]byte, error) {
+	switch codec {
+	case parquetUncompressed:
+		return page, nil
+	case parquetSnappy:
+		return snappyDecode(page)
+	case parquetGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(page))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	return nil, fmt.Errorf("unsupported Parquet compression codec %d (only snappy and gzip are supported)", codec)
+}
+
+// decodeParquetValues decodes the values of a data page, leaving nulls empty
+func decodeParquetValues(page []byte, encoding int64, defined []bool, dictionary []string, column parquetColumn) ([]string, error) {
+	count := 0
+	for _, d := range defined {
+		if d {
+			count++
+		}
+	}
+
+	var decoded []string
+	switch encoding {
+	case parquetPlain:
+		var err error
+		if decoded, err = decodeParquetPlain(page, count, column); err != nil {
+			return nil, err
+		}
+	case parquetPlainDictionary, parquetRLEDictionary:
+		if len(page) == 0 {
+			if count > 0 {
+				return nil, fmt.Errorf("page is truncated")
+			}
+			break
+		}
+		indexes, err := decodeRLE(page[1:], int(page[0]), count)
+		if err != nil {
+			return nil, err
+		}
+		decoded = make([]string, count)
+		for i, index := range indexes {
+			if index >= len(dictionary) {
+				return nil, fmt.Errorf("dictionary index %d out of range", index)
+			}
+			decoded[i] = dictionary[index]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Parquet encoding %d", encoding)
+	}
+
+	values := make([]string, len(defined))
+	next := 0
+	for i, d := range defined {
+		if d {
+			values[i] = decoded[next]
+			next++
+		}
+	}
+	return values, nil
+}
+
+// decodeParquetPlain decodes count PLAIN values
+func decodeParquetPlain(page []byte, count int, column parquetColumn) ([]string, error) {
+	width := map[int64]int{parquetInt32: 4, parquetInt64: 8, parquetInt96: 12, parquetFloat: 4, parquetDouble: 8}[column.Type]
+	if column.Type == parquetFixedLen {
+		width = column.Length
+	}
+
+	values := make([]string, count)
+	pos := 0
+	for i := range count {
+		var raw []byte
+		switch column.Type {
+		case parquetBoolean:
+			if i/8 >= len(page) {
+				return nil, fmt.Errorf("page is truncated")
+			}
+			values[i] = strconv.FormatBool(page[i/8]&(1<<(i%8)) != 0)
+			continue
+		case parquetByteArray:
+			if pos+4 > len(page) {
+				return nil, fmt.Errorf("page is truncated")
+			}
+			length := int(binary.LittleEndian.Uint32(page[pos:]))
+			pos += 4
+			if pos+length > len(page) {
+				return nil, fmt.Errorf("page is truncated")
+			}
+			raw = page[pos : pos+length]
+			pos += length
+		default:
+			if pos+width > len(page) {
+				return nil, fmt.Errorf("page is truncated")
+			}
+			raw = page[pos : pos+width]
+			pos += width
+		}
+		values[i] = formatParquetValue(raw, column)
+	}
+
+	return values, nil
+}
+
+// Converted types and logical type union fields that change how values are shown
+const (
+	parquetConvertedDecimal         = 5
+	parquetConvertedDate            = 6
+	parquetConvertedTimestampMillis = 9
+	parquetConvertedTimestampMicros = 10
+
+	parquetLogicalDecimal   = 5
+	parquetLogicalDate      = 6
+	parquetLogicalTimestamp = 8
+)
+
+// formatParquetValue formats one PLAIN value as text
+func formatParquetValue(raw []byte, column parquetColumn) string {
+	isDecimal := column.Converted == parquetConvertedDecimal || column.Logical.has(parquetLogicalDecimal)
+	isDate := column.Converted == parquetConvertedDate || column.Logical.has(parquetLogicalDate)
+
+	switch column.Type {
+	case parquetInt32:
+		n := int64(int32(binary.LittleEndian.Uint32(raw)))
+		switch {
+		case isDate:
+			return time.Unix(n*86400, 0).UTC().Format("2006-01-02")
+		case isDecimal:
+			return formatDecimal(big.NewInt(n), column.Scale)
+		}
+		return strconv.FormatInt(n, 10)
+
+	case parquetInt64:
+		n := int64(binary.LittleEndian.Uint64(raw))
+		if unit := parquetTimestampUnit(column); unit != 0 {
+			return time.Unix(0, 0).Add(time.Duration(n) * unit).UTC().Format("2006-01-02 15:04:05.999999999")
+		}
+		if isDecimal {
+			return formatDecimal(big.NewInt(n), column.Scale)
+		}
+		return strconv.FormatInt(n, 10)
+
+	case parquetInt96:
+		// Legacy timestamps: nanoseconds of the day, then the Julian day
+		nanos := int64(binary.LittleEndian.Uint64(raw))
+		day := int64(binary.LittleEndian.Uint32(raw[8:]))
+		const unixEpochJulianDay = 2440588
+		t := time.Unix((day-unixEpochJulianDay)*86400, nanos).UTC()
+		return t.Format("2006-01-02 15:04:05.999999999")
+
+	case parquetFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(raw))), 'f', -1, 32)
+
+	case parquetDouble:
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(raw)), 'f', -1, 64)
+	}
+
+	// Byte arrays are text, or big-endian two's complement decimals
+	if isDecimal {
+		n := new(big.Int).SetBytes(raw)
+		if len(raw) > 0 && raw[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(raw)*8)))
+		}
+		return formatDecimal(n, column.Scale)
+	}
+	return string(raw)
+}
+
+// parquetTimestampUnit returns the unit of INT64 timestamp columns, or 0
+func parquetTimestampUnit(column parquetColumn) time.Duration {
+	switch column.Converted {
+	case parquetConvertedTimestampMillis:
+		return time.Millisecond
+	case parquetConvertedTimestampMicros:
+		return time.Microsecond
+	}
+	if timestamp := column.Logical.strct(parquetLogicalTimestamp); timestamp != nil {
+		unit := timestamp.strct(2)
+		switch {
+		case unit.has(1):
+			return time.Millisecond
+		case unit.has(2):
+			return time.Microsecond
+		case unit.has(3):
+			return time.Nanosecond
+		}
+	}
+	return 0
+}
+
+// formatDecimal formats an unscaled decimal, e.g. 12345 with scale 2 as 123.45
+func formatDecimal(unscaled *big.Int, scale int) string {
+	if scale <= 0 {
+		return unscaled.String()
+	}
+	return new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)).FloatString(scale)
+}
+
+// decodeRLE decodes count values of the RLE/bit-packed hybrid encoding used
+// for definition levels and dictionary indexes
+func decodeRLE(data []byte, bitWidth int, count int) ([]int, error) {
+	if bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width %d", bitWidth)
+	}
+
+	values := make([]int, 0, count)
+	pos := 0
+	for len(values) < count {
+		header, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return nil, fmt.Errorf("truncated RLE data")
+		}
+		pos += n
+
+		if header&1 == 0 {
+			// RLE run: one value repeated
+			run := int(header >> 1)
+			width := (bitWidth + 7) / 8
+			if pos+width > len(data) {
+				return nil, fmt.Errorf("truncated RLE data")
+			}
+			value := 0
+			for i := range width {
+				value |= int(data[pos+i]) << (8 * i)
+			}
+			pos += width
+			for range min(run, count-len(values)) {
+				values = append(values, value)
+			}
+			continue
+		}
+
+		// Bit-packed run: groups of 8 values, least significant bit first
+		size := int(header>>1) * 8
+		if pos+size*bitWidth/8 > len(data) {
+			return nil, fmt.Errorf("truncated RLE data")
+		}
+		for i := range min(size, count-len(values)) {
+			value := 0
+			for b := range bitWidth {
+				bit := i*bitWidth + b
+				if data[pos+bit/8]&(1<<(bit%8)) != 0 {
+					value |= 1 << b
+				}
+			}
+			values = append(values, value)
+		}
+		pos += size * bitWidth / 8
+	}
+
+	return values, nil
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Parquet physical types, encodings and page types
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1
+	parquetUTF8     = 0 // converted type
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// IsParquet reports whether path is a Parquet file
+func IsParquet(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".parquet")
+}
+
+// WriteParquet writes records as an uncompressed Parquet file with a single
+// row group. Column types come from columnTypes: number columns are DOUBLE,
+// integer columns INT64 and boolean columns BOOLEAN, with empty values as
+// null; every other column is a UTF-8 string. A typed value that does not
+// parse is an error.
+func WriteParquet(path string, records [][]string, columnTypes map[string]string) error {
+	if len(records) == 0 {
+		return fmt.Errorf("no records to write")
+	}
+	header, rows := records[0], records[1:]
+
+	var out bytes.Buffer
+	out.WriteString("PAR1")
+
+	schema := []thriftStruct{{
+		{4, "schema"},
+		{5, int32(len(header))},
+	}}
+	var chunks []thriftStruct
+	var totalSize int64
+	for i, name := range header {
+		physical := parquetPhysicalType(columnTypes[name])
+		element := thriftStruct{{1, physical}, {3, int32(parquetOptional)}, {4, name}}
+		if physical == parquetByteArray {
+			element = append(element, thriftField{6, int32(parquetUTF8)})
+		}
+		schema = append(schema, element)
+
+		page, err := parquetDataPageValues(rows, i, name, physical)
+		if err != nil {
+			return err
+		}
+		var pageHeader bytes.Buffer
+		thriftStruct{
+			{1, int32(parquetDataPage)},
+			{2, int32(len(page))},
+			{3, int32(len(page))},
+			{5, thriftStruct{
+				{1, int32(len(rows))},
+				{2, int32(parquetPlain)},
+				{3, int32(parquetRLE)},
+				{4, int32(parquetRLE)},
+			}},
+		}.encode(&pageHeader)
+
+		offset := int64(out.Len())
+		size := int64(pageHeader.Len() + len(page))
+		out.Write(pageHeader.Bytes())
+		out.Write(page)
+		totalSize += size
+
+		chunks = append(chunks, thriftStruct{
+			{2, offset},
+			{3, thriftStruct{
+				{1, physical},
+				{2, []int32{parquetPlain, parquetRLE}},
+				{3, []string{name}},
+				{4, int32(0)}, // uncompressed
+				{5, int64(len(rows))},
+				{6, size},
+				{7, size},
+				{9, offset},
+			}},
+		})
+	}
+
+	var footer bytes.Buffer
+	thriftStruct{
+		{1, int32(1)},
+		{2, schema},
+		{3, int64(len(rows))},
+		{4, []thriftStruct{{
+			{1, chunks},
+			{2, totalSize},
+			{3, int64(len(rows))},
+		}}},
+		{6, "csv-migration-tools"},
+	}.encode(&footer)
+	out.Write(footer.Bytes())
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(footer.Len())))
+	out.WriteString("PAR1")
+
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// parquetPhysicalType maps a schema type to the Parquet type it is written as
+func parquetPhysicalType(columnType string) int32 {
+	switch columnType {
+	case "number":
+		return parquetDouble
+	case "integer":
+		return parquetInt64
+	case "boolean":
+		return parquetBoolean
+	}
+	return parquetByteArray
+}
+
+// parquetDataPageValues encodes column col of rows as the body of a data page:
+// the RLE definition levels followed by the PLAIN non-null values
+func parquetDataPageValues(rows [][]string, col int, name string, physical int32) ([]byte, error) {
+	defined := make([]bool, len(rows))
+	var values bytes.Buffer
+	var bits []bool
+	for r, row := range rows {
+		value := ""
+		if col < len(row) {
+			value = row[col]
+		}
+		if value == "" && physical != parquetByteArray {
+			continue
+		}
+		defined[r] = true
+
+		switch physical {
+		case parquetDouble:
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %s, row %d: %q is not a number", name, r+1, value)
+			}
+			values.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(number)))
+		case parquetInt64:
+			number, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %s, row %d: %q is not an integer", name, r+1, value)
+			}
+			values.Write(binary.LittleEndian.AppendUint64(nil, uint64(number)))
+		case parquetBoolean:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("column %s, row %d: %q is not a boolean", name, r+1, value)
+			}
+			bits = append(bits, b)
+		default:
+			values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value))))
+			values.WriteString(value)
+		}
+	}
+	if physical == parquetBoolean {
+		values.Write(packBits(bits))
+	}
+
+	// Definition levels are bit-packed runs of 1-bit values, prefixed by their length
+	levels := binary.AppendUvarint(nil, uint64((len(defined)+7)/8)<<1|1)
+	levels = append(levels, packBits(defined)...)
+
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, values.Bytes()...), nil
+}
+
+// packBits packs bools into bytes, least significant bit first
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// CopyToPostgres loads records into a Postgres table with COPY, through the
+// psql client so that authentication, TLS and .pgpass work as usual.
+// connection is a libpq connection string or postgres:// URL. The load runs
+// in one transaction; with truncate the table is emptied first. The header of
+// records names the table columns, and empty values are loaded as NULL.
+func CopyToPostgres(connection, table string, records [][]string, truncate bool) error {
+	if len(records) == 0 {
+		return fmt.Errorf("no records to load")
+	}
+
+	columns := make([]string, len(records[0]))
+	for i, column := range records[0] {
+		columns[i] = quotePostgresIdentifier(column)
+	}
+	table = quotePostgresTable(table)
+
+	args := []string{"--no-psqlrc", "--quiet", "--set", "ON_ERROR_STOP=1", "--single-transaction"}
+	if truncate {
+		args = append(args, "--command", "TRUNCATE TABLE "+table)
+	}
+	args = append(args, "--command", fmt.Sprintf(`\copy %s (%s) FROM STDIN WITH (FORMAT csv, HEADER true)`, table, strings.Join(columns, ", ")))
+
+	var input bytes.Buffer
+	if err := csv.NewWriter(&input).WriteAll(records); err != nil {
+		return err
+	}
+
+	cmd := psqlCommand(connection, args...)
+	cmd.Stdin = &input
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("psql failed: %s", msg)
+		}
+		return fmt.Errorf("psql failed: %v", err)
+	}
+
+	return nil
+}
+
+// conninfoPassword matches the password of a keyword/value connection string
+var conninfoPassword = regexp.MustCompile(`(^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// psqlCommand returns the psql command connecting to connection with args.
+// The password of the connection URL or string is passed in PGPASSWORD
+// rather than on the command line, where other users of the machine could
+// read it.
+func psqlCommand(connection string, args ...string) *exec.Cmd {
+	password := ""
+	if parsed, err := url.Parse(connection); err == nil && (parsed.Scheme == "postgres" || parsed.Scheme == "postgresql") {
+		if parsed.User != nil {
+			password, _ = parsed.User.Password()
+			parsed.User = url.User(parsed.User.Username())
+		}
+		if query := parsed.Query(); query.Has("password") {
+			password = query.Get("password")
+			query.Del("password")
+			parsed.RawQuery = query.Encode()
+		}
+		connection = parsed.String()
+	} else if match := conninfoPassword.FindStringSubmatch(connection); match != nil {
+		password = match[2]
+		if strings.HasPrefix(password, "'") {
+			password = strings.NewReplacer(`\'`, "'", `\\`, `\`).Replace(password[1 : len(password)-1])
+		}
+		connection = strings.TrimSpace(conninfoPassword.ReplaceAllString(connection, "$1"))
+	}
+
+	cmd := exec.Command("psql", append([]string{connection}, args...)...)
+	cmd.Env = os.Environ()
+	if password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+password)
+	}
+	return cmd
+}
+
+// quotePostgresIdentifier quotes a column or table name for use in SQL
+func quotePostgresIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quotePostgresTable quotes a possibly schema-qualified table name, e.g.
+// sales.Order Items becomes "sales"."Order Items"
+func quotePostgresTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quotePostgresIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// IsDatabaseURL reports whether path is a postgres:// or mysql:// connection
+// URL, whose query result is read in place of a file
+func IsDatabaseURL(path string) bool {
+	for _, scheme := range []string{"postgres://", "postgresql://", "mysql://"} {
+		if strings.HasPrefix(strings.ToLower(path), scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL hides the password of a database or file server URL, and the
+// query string of a storage URL (e.g. a SAS token), for messages and provenance
+func RedactURL(path string) string {
+	if IsRemote(path) {
+		parsed, err := url.Parse(path)
+		if err != nil {
+			return path
+		}
+		if parsed.RawQuery != "" {
+			parsed.RawQuery = "redacted"
+		}
+		return parsed.Redacted()
+	}
+	if !IsDatabaseURL(path) {
+		return path
+	}
+	if parsed, err := url.Parse(path); err == nil {
+		return parsed.Redacted()
+	}
+	return path
+}
+
+// QueryDatabase runs query against the database at connection and returns
+// its result with the column names as header. Like the loaders it goes
+// through the psql and mysql clients; NULLs become empty values.
+func QueryDatabase(connection, query string) ([][]string, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	if query == "" {
+		return nil, fmt.Errorf("a query is required to read from a database")
+	}
+	if strings.HasPrefix(strings.ToLower(connection), "mysql://") {
+		return queryMySQL(connection, query)
+	}
+	return queryPostgres(connection, query)
+}
+
+// queryPostgres streams the result as CSV with COPY ... TO STDOUT, which
+// needs no privileges beyond SELECT
+func queryPostgres(connection, query string) ([][]string, error) {
+	output, err := runClient(psqlCommand(connection, "--no-psqlrc", "--quiet", "--set", "ON_ERROR_STOP=1",
+		"--command", fmt.Sprintf("COPY (%s) TO STDOUT WITH (FORMAT csv, HEADER true)", query)))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing psql output: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("query returned no columns")
+	}
+	return records, nil
+}
+
+// mysqlResultSet is the --xml output of the mysql client, which unlike its
+// tab-separated output keeps NULL apart from the string "NULL"
+type mysqlResultSet struct {
+	Rows []struct {
+		Fields []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"field"`
+	} `xml:"row"`
+}
+
+func queryMySQL(connection, query string) ([][]string, error) {
+	args, password, err := mysqlArgs(connection)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, "--xml", "--execute", query)
+
+	cmd := exec.Command("mysql", args...)
+	cmd.Env = os.Environ()
+	if password != "" {
+		cmd.Env = append(cmd.Env, "MYSQL_PWD="+password)
+	}
+	output, err := runClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var result mysqlResultSet
+	if err := xml.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("error parsing mysql output: %v", err)
+	}
+	// The XML output names the columns only in the rows
+	if len(result.Rows) == 0 {
+		return nil, fmt.Errorf("query returned no rows")
+	}
+
+	header := make([]string, len(result.Rows[0].Fields))
+	for i, field := range result.Rows[0].Fields {
+		header[i] = field.Name
+	}
+	records := [][]string{header}
+	for _, row := range result.Rows {
+		record := make([]string, len(row.Fields))
+		for i, field := range row.Fields {
+			record[i] = field.Value
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// runClient runs a database client and returns its output, or its error
+// message when it fails
+func runClient(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", cmd.Args[0], msg)
+		}
+		return nil, fmt.Errorf("%s failed: %v", cmd.Args[0], err)
+	}
+	return output, nil
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Quirks describes the junk that report exports wrap around their rows,
+// dropped right after parsing instead of ending up as header or data rows.
+// Patterns are regular expressions matched against a row's fields joined by
+// commas, i.e. roughly the line as exported.
+type Quirks struct {
+	// Preamble matches title and filter rows before the header; leading rows
+	// are dropped while they match or are blank, unless every row would be
+	Preamble []string `json:"preamble,omitempty"`
+	// Footer matches rows of a trailing block such as a copyright notice; the
+	// block is dropped from the blank row before the first match, or else
+	// from the match itself
+	Footer []string `json:"footer,omitempty"`
+	// RepeatedHeaders drops data rows equal to the header, as repeated at
+	// page breaks
+	RepeatedHeaders bool `json:"repeated_headers,omitempty"`
+	// BlankRows drops rows whose fields are all empty
+	BlankRows bool `json:"blank_rows,omitempty"`
+
+	preamble, footer []*regexp.Regexp
+}
+
+// maxFooterRows is how many trailing rows are searched for a footer
+const maxFooterRows = 20
+
+// quirksProfiles are the built-in profiles of LoadQuirks
+var quirksProfiles = map[string]Quirks{
+	// Salesforce report exports may start with the report name and filters,
+	// one field per row, and end with the report name, a copyright and
+	// confidentiality notice, the exporting user and the company name
+	"salesforce": {
+		Preamble: []string{`^[^,]*,*$`},
+		Footer: []string{
+			`(?i)^copyright \(c\) .*salesforce\.com`,
+			`(?i)^confidential information - do not distribute`,
+			`(?i)^generated by:`,
+		},
+		RepeatedHeaders: true,
+		BlankRows:       true,
+	},
+}
+
+// LoadQuirks returns a built-in quirks profile by name ("salesforce"), or
+// loads a profile from a JSON file
+func LoadQuirks(profile string) (*Quirks, error) {
+	quirks, builtIn := quirksProfiles[strings.ToLower(profile)]
+	if !builtIn {
+		if err := LoadJSON(profile, &quirks); err != nil {
+			return nil, fmt.Errorf("%s is neither a built-in profile nor a readable profile file: %v", profile, err)
+		}
+	}
+
+	compile := func(patterns []string) ([]*regexp.Regexp, error) {
+		compiled := make([]*regexp.Regexp, len(patterns))
+		for i, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+			compiled[i] = re
+		}
+		return compiled, nil
+	}
+	var err error
+	if quirks.preamble, err = compile(quirks.Preamble); err != nil {
+		return nil, err
+	}
+	if quirks.footer, err = compile(quirks.Footer); err != nil {
+		return nil, err
+	}
+
+	return &quirks, nil
+}
+
+// apply drops the preamble, footer, repeated headers and blank rows of records
+func (q *Quirks) apply(records [][]string) [][]string {
+	start := 0
+	for start < len(records) && (isBlankRow(records[start]) || matchesRow(q.preamble, records[start])) {
+		start++
+	}
+	if start < len(records) {
+		records = records[start:]
+	}
+
+	if len(q.footer) > 0 {
+		for i := max(1, len(records)-maxFooterRows); i < len(records); i++ {
+			if !matchesRow(q.footer, records[i]) {
+				continue
+			}
+			cut := i
+			for j := i - 1; j > 0 && j >= len(records)-maxFooterRows; j-- {
+				if isBlankRow(records[j]) {
+					cut = j
+					break
+				}
+			}
+			records = records[:cut]
+			break
+		}
+	}
+
+	if len(records) == 0 || (!q.RepeatedHeaders && !q.BlankRows) {
+		return records
+	}
+	kept := [][]string{records[0]}
+	for _, row := range records[1:] {
+		if (q.RepeatedHeaders && slices.Equal(row, records[0])) || (q.BlankRows && isBlankRow(row)) {
+			continue
+		}
+		kept = append(kept, row)
+	}
+	return kept
+}
+
+func matchesRow(patterns []*regexp.Regexp, row []string) bool {
+	line := strings.TrimSpace(strings.Join(row, ","))
+	for _, re := range patterns {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlankRow(row []string) bool {
+	for _, field := range row {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
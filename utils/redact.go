@@ -0,0 +1,28 @@
+package utils
+
+import "regexp"
+
+// redactions replace personal data and secrets with a placeholder naming
+// what was there; card numbers go before the longer digit runs they contain
+var redactions = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`), "$1 [secret]"},
+	{regexp.MustCompile(`(?i)\b(api[_-]?key|token|secret|password|passwd)(["']?\s*[:=]\s*["']?)[^\s"',;]+`), "$1$2[secret]"},
+	{regexp.MustCompile(`://([^/\s:@]+):[^/\s@]+@`), "://$1:[secret]@"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+`), "[email]"},
+	{regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), "[card]"},
+	{regexp.MustCompile(`\+\d[\d ().-]{7,}\d`), "[phone]"},
+	{regexp.MustCompile(`\b\d{9,}\b`), "[number]"},
+}
+
+// RedactText hides the credentials, email addresses, phone and card numbers
+// and other long numbers (e.g. national IDs) of text, for copies of data
+// kept outside the migration such as audit files
+func RedactText(text string) string {
+	for _, r := range redactions {
+		text = r.pattern.ReplaceAllString(text, r.placeholder)
+	}
+	return text
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3PartSize is the size of the parts of multipart uploads. S3 allows 10,000
+// parts, so objects of up to 640 GB can be uploaded, holding one part in memory.
+const s3PartSize = 64 << 20
+
+// openS3 returns the body of an object, read as it downloads
+func openS3(location *url.URL) (io.ReadCloser, error) {
+	resp, err := s3Request(http.MethodGet, location, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func readS3(location *url.URL) ([]byte, error) {
+	body, err := openS3(location)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func writeS3(location *url.URL, data []byte) error {
+	return uploadS3(location, bytes.NewReader(data))
+}
+
+// uploadS3 uploads the object read from body. Objects larger than a part are
+// sent as a multipart upload, one part at a time, which is aborted when a
+// part fails so that no partial object is left behind.
+func uploadS3(location *url.URL, body io.Reader) error {
+	part := make([]byte, s3PartSize)
+	n, err := io.ReadFull(body, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s3Do(http.MethodPut, location, nil, part[:n], nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s3Do(http.MethodPost, location, url.Values{"uploads": {""}}, nil, &initiated); err != nil {
+		return err
+	}
+	upload := url.Values{"uploadId": {initiated.UploadID}}
+	if err := completeS3Upload(location, upload, part[:n], body); err != nil {
+		s3Do(http.MethodDelete, location, upload, nil, nil)
+		return err
+	}
+	return nil
+}
+
+// s3Part is an uploaded part of a multipart upload
+type s3Part struct {
+	PartNumber int
+	ETag       string
+}
+
+// completeS3Upload uploads first and the rest of body as the parts of a
+// multipart upload, then completes it
+func completeS3Upload(location *url.URL, upload url.Values, first []byte, body io.Reader) error {
+	completed := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{}
+	part := first
+	for len(part) > 0 {
+		number := len(completed.Parts) + 1
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": upload["uploadId"]}
+		resp, err := s3Request(http.MethodPut, location, query, part)
+		if err != nil {
+			return fmt.Errorf("part %d: %v", number, err)
+		}
+		resp.Body.Close()
+		completed.Parts = append(completed.Parts, s3Part{number, resp.Header.Get("ETag")})
+
+		n, err := io.ReadFull(body, part[:cap(part)])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		part = part[:n]
+	}
+
+	request, err := xml.Marshal(completed)
+	if err != nil {
+		return err
+	}
+	// A failed completion can still be answered with 200 OK and an error in
+	// the body
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := s3Do(http.MethodPost, location, upload, request, &result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("%s: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// s3Do sends a request and decodes its XML response into result, if not nil
+func s3Do(method string, location *url.URL, query url.Values, body []byte, result any) error {
+	resp, err := s3Request(method, location, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(result)
+}
+
+// s3Client sends the requests to S3. Redirects are not followed, since a
+// redirected request would need signing again: requests to the wrong region
+// are sent again to the region S3 names instead.
+var s3Client = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// s3Request sends a signed request for the object of an s3://bucket/key URL,
+// with the credentials of loadAWSCredentials. Buckets are first addressed in
+// the region of awsRegion; when S3 answers that a bucket is in another
+// region, the request is sent there and the bucket's region remembered.
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL selects an S3-compatible service
+// such as MinIO, addressed path-style.
+func s3Request(method string, location *url.URL, query url.Values, body []byte) (*http.Response, error) {
+	credentials, err := loadAWSCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, key := location.Host, strings.TrimPrefix(location.Path, "/")
+	if key == "" {
+		return nil, fmt.Errorf("s3://%s has no object key", bucket)
+	}
+	awsCache.Lock()
+	region, known := awsCache.regions[bucket]
+	awsCache.Unlock()
+	if !known {
+		region = awsRegion()
+	}
+
+	for redirected := false; ; redirected = true {
+		req, err := http.NewRequest(method, s3Endpoint(bucket, region, key, query), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		signAWSRequest(req, body, credentials, region, "s3", time.Now())
+
+		resp, err := s3Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode/100 == 2 {
+			return resp, nil
+		}
+
+		// Wrong regions are answered with 301 PermanentRedirect, 307 or 400
+		// AuthorizationHeaderMalformed, naming the bucket's region
+		bucketRegion := resp.Header.Get("X-Amz-Bucket-Region")
+		if !redirected && bucketRegion != "" && bucketRegion != region {
+			resp.Body.Close()
+			region = bucketRegion
+			awsCache.Lock()
+			if awsCache.regions == nil {
+				awsCache.regions = make(map[string]string)
+			}
+			awsCache.regions[bucket] = region
+			awsCache.Unlock()
+			continue
+		}
+
+		defer resp.Body.Close()
+		var s3Error struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		msg, _ := io.ReadAll(resp.Body)
+		if xml.Unmarshal(msg, &s3Error) == nil && s3Error.Code != "" {
+			return nil, fmt.Errorf("%s: %s: %s", resp.Status, s3Error.Code, s3Error.Message)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+}
+
+// s3Endpoint returns the URL of an object. Buckets are addressed
+// virtual-hosted, except those with dots in their name, which the wildcard
+// TLS certificate of the virtual-hosted endpoints does not cover, and those
+// of a custom endpoint, which are addressed path-style.
+func s3Endpoint(bucket, region, key string, query url.Values) string {
+	escapedKey := escapeObjectPath(key)
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, escapedKey)
+	if custom := cmp.Or(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); custom != "" {
+		endpoint = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(custom, "/"), bucket, escapedKey)
+	} else if strings.Contains(bucket, ".") {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", region, bucket, escapedKey)
+	}
+	if len(query) > 0 {
+		// Signature Version 4 signs the query sorted and with spaces as %20
+		endpoint += "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+	}
+	return endpoint
+}
+
+// signAWSRequest adds a Signature Version 4 Authorization header, signing the
+// host, the x-amz-* headers and the payload
+func signAWSRequest(req *http.Request, body []byte, credentials awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	payloadHash := sha256.Sum256(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" || name == "content-type" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", day, region, service)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{day, region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// escapeObjectPath percent-encodes every byte of an object key except unreserved
+// characters and slashes, as Signature Version 4 expects
+func escapeObjectPath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+)
+
+// Sample is a random sample of the rows of a file, with the distinct values
+// of its columns over all rows
+type Sample struct {
+	// Records are the header and the sampled rows, in file order
+	Records [][]string
+	// Rows is the number of data rows of the file
+	Rows int
+	// Distinct holds the distinct non-empty values of each column in order of
+	// appearance, or nil for columns with more than MaxDistinct of them
+	Distinct    [][]string
+	MaxDistinct int
+}
+
+// Sampled reports whether rows of the file were left out of the sample
+func (s *Sample) Sampled() bool {
+	return len(s.Records)-1 < s.Rows
+}
+
+// SampleCSVFile reads a random sample of at most rows data rows of a file,
+// all of them when rows is 0, by reservoir sampling, and the first
+// maxDistinct distinct values of each column. Local CSV and TSV files are
+// read one record at a time, so that samples which are themselves large
+// exports need not fit in memory; other sources are read whole.
+func SampleCSVFile(path string, options CSVOptions, rows, maxDistinct int) (*Sample, error) {
+	if !IsStreamable(path, options) {
+		records, _, err := ReadCSVFileWithOptions(path, options)
+		if err != nil {
+			return nil, err
+		}
+		s := newSampler(records[0], rows, maxDistinct)
+		for _, record := range records[1:] {
+			s.add(record)
+		}
+		return s.finish(), nil
+	}
+
+	reader, err := OpenRecordReader(path, options)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read %s: %v", RedactURL(path), err)
+	}
+	s := newSampler(header, rows, maxDistinct)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", RedactURL(path), err)
+		}
+		s.add(record)
+	}
+	if header == nil || s.rows == 0 {
+		return nil, fmt.Errorf("CSV must have at least header and one data row")
+	}
+	return s.finish(), nil
+}
+
+// sampler keeps a uniform random sample of the rows added to it
+type sampler struct {
+	header      []string
+	size        int
+	maxDistinct int
+	// Sampling uses a fixed seed so repeated runs select the same rows
+	rng *rand.Rand
+	// rows is the number of rows added, and kept the sampled rows by their
+	// position among them
+	rows     int
+	kept     []sampledRow
+	seen     []map[string]bool
+	distinct [][]string
+}
+
+type sampledRow struct {
+	index  int
+	record []string
+}
+
+func newSampler(header []string, size, maxDistinct int) *sampler {
+	s := &sampler{
+		header:      header,
+		size:        size,
+		maxDistinct: maxDistinct,
+		rng:         rand.New(rand.NewSource(1)),
+		seen:        make([]map[string]bool, len(header)),
+		distinct:    make([][]string, len(header)),
+	}
+	for i := range s.seen {
+		s.seen[i] = make(map[string]bool)
+	}
+	return s
+}
+
+func (s *sampler) add(record []string) {
+	// Track the distinct values until a column has too many of them
+	for i, value := range record {
+		if i >= len(s.seen) || s.seen[i] == nil || value == "" || s.seen[i][value] {
+			continue
+		}
+		if len(s.seen[i]) == s.maxDistinct {
+			s.seen[i] = nil
+			continue
+		}
+		s.seen[i][value] = true
+		s.distinct[i] = append(s.distinct[i], value)
+	}
+
+	// Keep the first size rows, then replace kept rows with a probability
+	// that leaves every row equally likely to be in the sample
+	s.rows++
+	switch {
+	case s.size <= 0 || len(s.kept) < s.size:
+		s.kept = append(s.kept, sampledRow{s.rows, record})
+	default:
+		if j := s.rng.Intn(s.rows); j < s.size {
+			s.kept[j] = sampledRow{s.rows, record}
+		}
+	}
+}
+
+func (s *sampler) finish() *Sample {
+	sort.Slice(s.kept, func(i, j int) bool { return s.kept[i].index < s.kept[j].index })
+	sample := &Sample{
+		Records:     [][]string{s.header},
+		Rows:        s.rows,
+		Distinct:    make([][]string, len(s.header)),
+		MaxDistinct: s.maxDistinct,
+	}
+	for _, row := range s.kept {
+		sample.Records = append(sample.Records, row.record)
+	}
+	for i := range s.header {
+		if s.seen[i] != nil {
+			sample.Distinct[i] = s.distinct[i]
+			if sample.Distinct[i] == nil {
+				sample.Distinct[i] = []string{}
+			}
+		}
+	}
+	return sample
+}
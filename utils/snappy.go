@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// snappyDecode decompresses a raw snappy block, the compression Spark and
+// most Parquet writers use by default
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > uint64(1<<32) {
+		return nil, fmt.Errorf("snappy: invalid length")
+	}
+	src = src[n:]
+	dst := make([]byte, 0, length)
+
+	for len(src) > 0 {
+		tag := src[0]
+		var size, offset int
+		switch tag & 3 {
+		case 0: // literal
+			size = int(tag>>2) + 1
+			src = src[1:]
+			if size > 60 {
+				extra := size - 60
+				if len(src) < extra {
+					return nil, fmt.Errorf("snappy: corrupt input")
+				}
+				size = 0
+				for i := range extra {
+					size |= int(src[i]) << (8 * i)
+				}
+				size++
+				src = src[extra:]
+			}
+			if len(src) < size {
+				return nil, fmt.Errorf("snappy: corrupt input")
+			}
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+			continue
+		case 1: // copy with a 1-byte offset
+			if len(src) < 2 {
+				return nil, fmt.Errorf("snappy: corrupt input")
+			}
+			size = 4 + int(tag>>2)&7
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case 2: // copy with a 2-byte offset
+			if len(src) < 3 {
+				return nil, fmt.Errorf("snappy: corrupt input")
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3: // copy with a 4-byte offset
+			if len(src) < 5 {
+				return nil, fmt.Errorf("snappy: corrupt input")
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) {
+			return nil, fmt.Errorf("snappy: corrupt input")
+		}
+		// Copies may overlap their own output, so go byte by byte
+		start := len(dst) - offset
+		for i := range size {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if uint64(len(dst)) != length {
+		return nil, fmt.Errorf("snappy: corrupt input")
+	}
+	return dst, nil
+}
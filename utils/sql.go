@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SQLOptions configures the INSERT statements of .sql output
+type SQLOptions struct {
+	// Dialect is postgres, mysql, sqlite or sqlserver
+	Dialect string
+	// Table receives the rows, optionally qualified like schema.table
+	Table string
+	// BatchSize is the number of rows per multi-row INSERT
+	BatchSize int
+	// CommitRows commits after at least this many rows; 0 wraps all rows in
+	// one transaction
+	CommitRows int
+	// Truncate deletes the existing rows first, in the first transaction
+	Truncate bool
+}
+
+type sqlDialect struct {
+	quoteIdentifier func(string) string
+	quoteString     func(string) string
+	preamble        []string
+	begin           string
+	commit          string
+	true, false     string
+	// maxBatch limits the rows of one VALUES list, 0 means no limit
+	maxBatch int
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"postgres": {
+		quoteIdentifier: quotePostgresIdentifier,
+		quoteString:     quoteSQLString,
+		begin:           "BEGIN;",
+		commit:          "COMMIT;",
+		true:            "TRUE",
+		false:           "FALSE",
+	},
+	"mysql": {
+		quoteIdentifier: quoteMySQLIdentifier,
+		quoteString:     quoteMySQLString,
+		// The string escaping relies on backslash escapes
+		preamble: []string{"SET NAMES utf8mb4;", "SET SESSION sql_mode = REPLACE(@@sql_mode, 'NO_BACKSLASH_ESCAPES', '');"},
+		begin:    "START TRANSACTION;",
+		commit:   "COMMIT;",
+		true:     "TRUE",
+		false:    "FALSE",
+	},
+	"sqlite": {
+		quoteIdentifier: quotePostgresIdentifier,
+		quoteString:     quoteSQLString,
+		begin:           "BEGIN TRANSACTION;",
+		commit:          "COMMIT;",
+		true:            "1",
+		false:           "0",
+	},
+	"sqlserver": {
+		quoteIdentifier: func(name string) string { return "[" + strings.ReplaceAll(name, "]", "]]") + "]" },
+		quoteString:     func(value string) string { return "N" + quoteSQLString(value) },
+		begin:           "BEGIN TRANSACTION;",
+		commit:          "COMMIT TRANSACTION;",
+		true:            "1",
+		false:           "0",
+		maxBatch:        1000,
+	},
+}
+
+// ValidateSQLOptions checks the dialect and batch size
+func ValidateSQLOptions(options SQLOptions) error {
+	dialect, exists := sqlDialects[options.Dialect]
+	if !exists {
+		return fmt.Errorf("unknown SQL dialect %q: must be postgres, mysql, sqlite or sqlserver", options.Dialect)
+	}
+	if options.BatchSize <= 0 || options.CommitRows < 0 {
+		return fmt.Errorf("batch size must be positive and commit rows must not be negative")
+	}
+	if dialect.maxBatch > 0 && options.BatchSize > dialect.maxBatch {
+		return fmt.Errorf("%s allows at most %d rows per INSERT", options.Dialect, dialect.maxBatch)
+	}
+	return nil
+}
+
+// IsSQL reports whether path is a SQL script
+func IsSQL(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".sql")
+}
+
+// writeSQLInserts writes the data rows of records as multi-row INSERT
+// statements wrapped in transactions. Empty values are NULL; values of
+// number and integer columns in columnTypes are written unquoted and boolean
+// columns as the dialect's true and false.
+func writeSQLInserts(w io.Writer, records [][]string, options SQLOptions, columnTypes map[string]string, lineEnding string) error {
+	if err := ValidateSQLOptions(options); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	dialect := sqlDialects[options.Dialect]
+
+	parts := strings.Split(options.Table, ".")
+	for i, part := range parts {
+		parts[i] = dialect.quoteIdentifier(part)
+	}
+	table := strings.Join(parts, ".")
+
+	header, rows := records[0], records[1:]
+	columns := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = dialect.quoteIdentifier(column)
+	}
+
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(s)
+		b.WriteString(lineEnding)
+	}
+	flush := func() error {
+		_, err := io.WriteString(w, b.String())
+		b.Reset()
+		return err
+	}
+
+	for _, statement := range dialect.preamble {
+		line(statement)
+	}
+	line(dialect.begin)
+	if options.Truncate {
+		line("DELETE FROM " + table + ";")
+	}
+
+	uncommitted := 0
+	for start := 0; start < len(rows); start += options.BatchSize {
+		batch := rows[start:min(start+options.BatchSize, len(rows))]
+		line(fmt.Sprintf("INSERT INTO %s (%s) VALUES", table, strings.Join(columns, ", ")))
+		for r, row := range batch {
+			values := make([]string, len(header))
+			for i, column := range header {
+				value := ""
+				if i < len(row) {
+					value = row[i]
+				}
+				values[i] = sqlValue(value, columnTypes[column], dialect)
+			}
+			separator := ","
+			if r == len(batch)-1 {
+				separator = ";"
+			}
+			line("(" + strings.Join(values, ", ") + ")" + separator)
+		}
+
+		uncommitted += len(batch)
+		if options.CommitRows > 0 && uncommitted >= options.CommitRows && start+len(batch) < len(rows) {
+			line(dialect.commit)
+			line(dialect.begin)
+			uncommitted = 0
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	line(dialect.commit)
+
+	return flush()
+}
+
+// sqlValue returns the SQL literal of a value in a column of the given type
+func sqlValue(value, columnType string, dialect sqlDialect) string {
+	if value == "" {
+		return "NULL"
+	}
+	switch columnType {
+	case "number", "integer":
+		if _, err := strconv.ParseFloat(value, 64); err == nil && strings.Trim(value, "0123456789.-") == "" {
+			return value
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			if b {
+				return dialect.true
+			}
+			return dialect.false
+		}
+	}
+	return dialect.quoteString(value)
+}
+
+// quoteSQLString quotes a standard SQL string literal, doubling quotes
+func quoteSQLString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// quoteMySQLIdentifier quotes a column or table name with backticks
+func quoteMySQLIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// mysqlEscaper escapes the characters MySQL string literals cannot hold as is
+var mysqlEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+func quoteMySQLString(value string) string {
+	return "'" + mysqlEscaper.Replace(value) + "'"
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// remoteStorage reads and writes whole objects of one kind of URL. Storages
+// that can list a directory also serve as batch directories, and those that
+// can open and upload objects as streams are read and written without
+// holding whole objects in memory.
+type remoteStorage struct {
+	read   func(location *url.URL) ([]byte, error)
+	write  func(location *url.URL, data []byte) error
+	list   func(location *url.URL) ([]string, error)
+	open   func(location *url.URL) (io.ReadCloser, error)
+	upload func(location *url.URL, body io.Reader) error
+}
+
+// remoteStorages are keyed by URL scheme
+var remoteStorages = map[string]remoteStorage{
+	"s3": {read: readS3, write: writeS3, open: openS3, upload: uploadS3},
+	"gs": {read: readGCS, write: writeGCS},
+	"az": {read: readAzureBlob, write: writeAzureBlob},
+	// File servers of vendors
+	"sftp": {read: readSFTP, write: writeSFTP, list: listSFTP},
+	"ftp":  {read: readSFTP, write: writeSFTP, list: listSFTP},
+	"ftps": {read: readSFTP, write: writeSFTP, list: listSFTP},
+	// Plain web servers can only be read from
+	"http":  {read: readHTTP},
+	"https": {read: readHTTP},
+}
+
+// storageFor returns the storage of a remote path
+func storageFor(location string) (remoteStorage, *url.URL, bool) {
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Host == "" {
+		return remoteStorage{}, nil, false
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	if scheme == "https" && strings.HasSuffix(strings.ToLower(parsed.Hostname()), ".blob.core.windows.net") {
+		scheme = "az"
+	}
+	storage, exists := remoteStorages[scheme]
+	return storage, parsed, exists
+}
+
+// IsRemote reports whether path is a storage or web URL rather than a local file
+func IsRemote(path string) bool {
+	_, _, remote := storageFor(path)
+	return remote
+}
+
+// IsWritableRemote reports whether files can be uploaded to path
+func IsWritableRemote(path string) bool {
+	storage, _, remote := storageFor(path)
+	return remote && storage.write != nil
+}
+
+// isStreamableRemote reports whether path is a remote object that can be read
+// as a stream
+func isStreamableRemote(path string) bool {
+	storage, _, remote := storageFor(path)
+	return remote && storage.open != nil
+}
+
+// dataName is the file name of path that tells its format: storage URLs
+// lose their query string, e.g. a download token
+func dataName(path string) string {
+	if _, location, remote := storageFor(path); remote {
+		location.RawQuery, location.Fragment = "", ""
+		return location.String()
+	}
+	return path
+}
+
+// stdinPath stands for the standard input wherever a file path is expected
+const stdinPath = "-"
+
+// IsStdin reports whether path is "-", the data piped into the tool, or a
+// file of a piped zip archive like "-!customers.csv"
+func IsStdin(path string) bool {
+	archive, _, _ := strings.Cut(path, "!")
+	return archive == stdinPath
+}
+
+// stdin is read once, so that a source can be read more than once
+var stdin struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func readStdin() ([]byte, error) {
+	stdin.once.Do(func() {
+		stdin.data, stdin.err = io.ReadAll(os.Stdin)
+		if stdin.err == nil && len(stdin.data) == 0 {
+			stdin.err = fmt.Errorf("no data on stdin")
+		}
+	})
+	return stdin.data, stdin.err
+}
+
+// readData reads a local file, the standard input or a remote object
+func readData(path string) ([]byte, error) {
+	if IsStdin(path) {
+		return readStdin()
+	}
+	storage, location, remote := storageFor(path)
+	if !remote {
+		return os.ReadFile(path)
+	}
+	data, err := storage.read(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", RedactURL(path), err)
+	}
+	return data, nil
+}
+
+// openData opens a local file or a remote object that can be read as a
+// stream
+func openData(path string) (io.ReadCloser, error) {
+	storage, location, remote := storageFor(path)
+	if !remote {
+		return os.Open(path)
+	}
+	if storage.open == nil {
+		return nil, fmt.Errorf("%s cannot be read as a stream", RedactURL(path))
+	}
+	body, err := storage.open(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", RedactURL(path), err)
+	}
+	return body, nil
+}
+
+// ListRemote returns the paths of the files in a remote directory
+func ListRemote(dir string) ([]string, error) {
+	storage, location, remote := storageFor(dir)
+	if !remote || storage.list == nil {
+		return nil, fmt.Errorf("%s is not a directory that can be listed", RedactURL(dir))
+	}
+	names, err := storage.list(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", RedactURL(dir), err)
+	}
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		file := *location
+		file.Path = strings.TrimSuffix(file.Path, "/") + "/" + name
+		file.RawPath = ""
+		paths[i] = file.String()
+	}
+	return paths, nil
+}
+
+// WriteRemote stores data at a remote path
+func WriteRemote(path string, data []byte) error {
+	storage, location, remote := storageFor(path)
+	if !remote || storage.write == nil {
+		return fmt.Errorf("%s is not a writable storage URL", RedactURL(path))
+	}
+	if err := storage.write(location, data); err != nil {
+		return fmt.Errorf("failed to write %s: %v", RedactURL(path), err)
+	}
+	return nil
+}
+
+// WriteData writes a local file, or stores data at a remote path
+func WriteData(path string, data []byte) error {
+	if IsRemote(path) {
+		return WriteRemote(path, data)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// UploadFile copies a local file into the remote directory dir, keeping its
+// base name, and returns the remote path for display
+func UploadFile(file, dir string) (string, error) {
+	// The directory URL may end in a query string, like a SAS token
+	target, err := url.Parse(dir)
+	if err != nil {
+		return "", err
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + path.Base(filepath.ToSlash(file))
+	target.RawPath = ""
+	return RedactURL(target.String()), uploadFile(file, target.String())
+}
+
+// uploadFile copies a local file to a remote path, as a stream when the
+// storage supports it
+func uploadFile(file, target string) error {
+	storage, location, remote := storageFor(target)
+	if !remote || storage.upload == nil {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		return WriteRemote(target, data)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := storage.upload(location, f); err != nil {
+		return fmt.Errorf("failed to write %s: %v", RedactURL(target), err)
+	}
+	return nil
+}
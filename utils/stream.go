@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// streamSampleSize is how much of a streamed file is looked at to detect its
+// encoding and delimiter
+const streamSampleSize = 64 * 1024
+
+// RecordReader reads the records of a CSV or TSV file one at a time, so that
+// files larger than memory can be converted
+type RecordReader struct {
+	reader  *csv.Reader
+	closers []io.Closer
+}
+
+// OpenRecordReader opens a local CSV or TSV file, optionally gzipped, an S3
+// object of one, or the standard input ("-"), for reading one record at a time. Unless given in
+// options, the encoding and delimiter are detected from the first 64 KB.
+// Options that need the whole file (Recover, Quirks, MultiDelimiter) are not
+// supported.
+func OpenRecordReader(path string, options CSVOptions) (*RecordReader, error) {
+	if err := checkStreamable(path, options); err != nil {
+		return nil, err
+	}
+
+	r := &RecordReader{}
+	var source io.Reader = os.Stdin
+	name := path
+	if !IsStdin(path) {
+		file, err := openData(path)
+		if err != nil {
+			return nil, err
+		}
+		r.closers = append(r.closers, file)
+		source = file
+		name = dataName(path)
+	}
+
+	buffered := bufio.NewReaderSize(source, streamSampleSize)
+	magic, _ := buffered.Peek(2)
+	if strings.EqualFold(filepath.Ext(name), ".gz") || (IsStdin(path) && bytes.Equal(magic, []byte("\x1f\x8b"))) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to read %s: %v", RedactURL(path), err)
+		}
+		r.closers = append(r.closers, gz)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		buffered = bufio.NewReaderSize(gz, streamSampleSize)
+	}
+
+	sample, err := buffered.Peek(streamSampleSize)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		r.Close()
+		return nil, fmt.Errorf("failed to read %s: %v", RedactURL(path), err)
+	}
+	if len(sample) == streamSampleSize {
+		// Detect from whole lines, so no character is cut in two
+		if i := bytes.LastIndexByte(sample, '\n'); i > 0 {
+			sample = sample[:i+1]
+		}
+	}
+
+	encoding := options.Encoding
+	if encoding == "" {
+		encoding = DetectEncoding(sample)
+	}
+	enc, err := LookupEncoding(encoding)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	var decoded io.Reader = buffered
+	if enc == unicode.UTF8 {
+		// A byte order mark would otherwise end up in the first header name
+		if bytes.HasPrefix(sample, []byte(utf8BOM)) {
+			buffered.Discard(len(utf8BOM))
+		}
+	} else {
+		decoded = transform.NewReader(buffered, enc.NewDecoder())
+	}
+
+	if options.Delimiter == 0 {
+		options.Delimiter = '\t'
+		if !IsTSV(name) {
+			text, err := DecodeText(sample, encoding)
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			options.Delimiter = DetectDelimiter(strings.TrimPrefix(text, utf8BOM))
+		}
+	}
+
+	r.reader = newCSVReader(decoded, options)
+	return r, nil
+}
+
+// IsStreamable reports whether OpenRecordReader can read path with options
+func IsStreamable(path string, options CSVOptions) bool {
+	return checkStreamable(path, options) == nil
+}
+
+func checkStreamable(path string, options CSVOptions) error {
+	switch {
+	case IsDatabaseURL(path), IsGoogleSheet(path), IsRemote(path) && !isStreamableRemote(path), strings.Contains(path, "!"),
+		IsParquet(dataName(path)), IsXLSX(dataName(path)), IsJSON(strings.TrimSuffix(dataName(path), ".gz")),
+		strings.EqualFold(filepath.Ext(dataName(path)), ".zip"):
+		return fmt.Errorf("%s: only CSV and TSV files, optionally gzipped, stored locally or in S3 can be read as a stream", RedactURL(path))
+	case options.Recover || options.Quirks != nil || options.MultiDelimiter != "":
+		return fmt.Errorf("recovering lines, quirks and multi-character delimiters need the whole file")
+	}
+	return nil
+}
+
+// Read returns the next record, or io.EOF after the last one
+func (r *RecordReader) Read() ([]string, error) {
+	return r.reader.Read()
+}
+
+// Close closes the file
+func (r *RecordReader) Close() error {
+	var err error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if closeErr := r.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// EachRecord calls fn with every record of a source file, the header first,
+// stopping at the first error. CSV and TSV files, local or in S3, are read one
+// record at a time, so files of any size can be checked; other sources are
+// read whole.
+func EachRecord(path string, options CSVOptions, fn func(record []string) error) error {
+	if !IsStreamable(path, options) {
+		records, _, err := ReadCSVFileWithOptions(path, options)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	reader, err := OpenRecordReader(path, options)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", RedactURL(path), err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// errHeaderRead stops EachRecord after the header
+var errHeaderRead = errors.New("header read")
+
+// ReadHeader returns the header of a source file. CSV and TSV files, local or
+// in S3, are read no further than the header, and local Parquet files no
+// further than their footer.
+func ReadHeader(path string, options CSVOptions) ([]string, error) {
+	if IsParquet(path) && !IsRemote(path) && !IsStdin(path) {
+		return readParquetHeader(path)
+	}
+	options.Columns = nil
+	var header []string
+	err := EachRecord(path, options, func(record []string) error {
+		header = slices.Clone(record)
+		return errHeaderRead
+	})
+	if err != nil && err != errHeaderRead {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("%s is empty", RedactURL(path))
+	}
+	return header, nil
+}
+
+// RecordWriter writes records one at a time through a large buffer, flushing
+// them to the file every FlushRows rows, so that output starts right away and
+// memory stays flat. The first record is the header.
+type RecordWriter struct {
+	path     string
+	options  WriteOptions
+	file     *os.File
+	finish   func() error
+	buffered *bufio.Writer
+	encoded  io.WriteCloser
+	csv      *csv.Writer
+	// quoted buffers rows with every field quoted; writeQuoted reuses it
+	quoted *bufio.Writer
+	header []string
+	rows   int
+}
+
+// CreateRecordWriter creates a CSV, TSV or JSON Lines file, optionally
+// compressed, for writing one record at a time with the given options
+func CreateRecordWriter(path string, options WriteOptions) (*RecordWriter, error) {
+	// The format follows from the name inside the compressed file
+	name := strings.TrimSuffix(path, CompressedExtension(options.Compress))
+	if IsXLSX(name) || IsParquet(name) || IsAvro(name) || IsArrow(name) || IsSQL(name) {
+		return nil, fmt.Errorf("%s: only CSV, TSV and JSON Lines files can be written as a stream", path)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &RecordWriter{path: name, options: options, file: file}
+
+	compressed, finish, err := compressWriter(file, name, options.Compress)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.finish = finish
+	w.buffered = bufio.NewWriterSize(compressed, writeBufferSize)
+
+	if options.BOM {
+		w.buffered.WriteString(utf8BOM)
+	}
+	if IsJSONL(name) {
+		return w, nil
+	}
+
+	if w.options.Delimiter == 0 && IsTSV(name) {
+		w.options.Delimiter = '\t'
+	}
+	if w.encoded, err = encodingWriter(w.buffered, options.Encoding); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if options.QuoteAll {
+		w.quoted = bufio.NewWriter(w.encoded)
+	} else {
+		w.csv = newCSVWriter(w.encoded, w.options)
+	}
+	return w, nil
+}
+
+// Write writes one record
+func (w *RecordWriter) Write(record []string) error {
+	var err error
+	switch {
+	case IsJSONL(w.path):
+		// JSON Lines has no header, every row carries its columns
+		if w.header == nil {
+			w.header = record
+			return nil
+		}
+		err = writeJSONL(w.buffered, w.header, [][]string{record}, w.options)
+	case w.csv != nil:
+		err = w.csv.Write(record)
+	default:
+		err = writeQuoted(w.quoted, [][]string{record}, w.options)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", w.path, err)
+	}
+
+	w.rows++
+	if w.rows%w.options.flushRows() == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *RecordWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", w.path, err)
+		}
+	}
+	return w.buffered.Flush()
+}
+
+// Close flushes the remaining records and closes the file
+func (w *RecordWriter) Close() error {
+	defer w.file.Close()
+
+	err := w.flush()
+	if err == nil && w.encoded != nil {
+		if err = w.encoded.Close(); err == nil {
+			err = w.buffered.Flush()
+		}
+	}
+	if err == nil {
+		err = w.finish()
+	}
+	if err == nil {
+		err = w.file.Close()
+	}
+	return err
+}
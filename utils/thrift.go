@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Parquet metadata is serialized with the Thrift compact protocol. Only the
+// parts Parquet needs are implemented: written structs are lists of numbered
+// fields whose values are bool, int32, int64, string, thriftStruct or lists of
+// int32, string or thriftStruct. Any struct can be read, maps are skipped.
+
+type thriftStruct []thriftField
+
+type thriftField struct {
+	ID    int16
+	Value any
+}
+
+const (
+	thriftTrue       = 1
+	thriftFalse      = 2
+	thriftByte       = 3
+	thriftI16        = 4
+	thriftI32        = 5
+	thriftI64        = 6
+	thriftDouble     = 7
+	thriftBinary     = 8
+	thriftList       = 9
+	thriftSet        = 10
+	thriftMap        = 11
+	thriftStructType = 12
+)
+
+func (s thriftStruct) encode(w *bytes.Buffer) {
+	var last int16
+	for _, field := range s {
+		var typ byte
+		switch v := field.Value.(type) {
+		case bool:
+			typ = thriftFalse
+			if v {
+				typ = thriftTrue
+			}
+		case int32:
+			typ = thriftI32
+		case int64:
+			typ = thriftI64
+		case string:
+			typ = thriftBinary
+		case thriftStruct:
+			typ = thriftStructType
+		case []int32, []string, []thriftStruct:
+			typ = thriftList
+		default:
+			panic(fmt.Sprintf("thrift: unsupported field type %T", field.Value))
+		}
+
+		if delta := field.ID - last; delta > 0 && delta <= 15 {
+			w.WriteByte(byte(delta)<<4 | typ)
+		} else {
+			w.WriteByte(typ)
+			writeZigzag(w, int64(field.ID))
+		}
+		last = field.ID
+
+		switch v := field.Value.(type) {
+		case int32:
+			writeZigzag(w, int64(v))
+		case int64:
+			writeZigzag(w, v)
+		case string:
+			writeThriftBinary(w, v)
+		case thriftStruct:
+			v.encode(w)
+		case []int32:
+			writeThriftListHeader(w, len(v), thriftI32)
+			for _, item := range v {
+				writeZigzag(w, int64(item))
+			}
+		case []string:
+			writeThriftListHeader(w, len(v), thriftBinary)
+			for _, item := range v {
+				writeThriftBinary(w, item)
+			}
+		case []thriftStruct:
+			writeThriftListHeader(w, len(v), thriftStructType)
+			for _, item := range v {
+				item.encode(w)
+			}
+		}
+	}
+	w.WriteByte(0)
+}
+
+func writeZigzag(w *bytes.Buffer, v int64) {
+	w.Write(binary.AppendUvarint(nil, uint64(v<<1^v>>63)))
+}
+
+func writeThriftBinary(w *bytes.Buffer, v string) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(v))))
+	w.WriteString(v)
+}
+
+func writeThriftListHeader(w *bytes.Buffer, size int, elemType byte) {
+	if size < 15 {
+		w.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	w.WriteByte(0xF0 | elemType)
+	w.Write(binary.AppendUvarint(nil, uint64(size)))
+}
+
+// thriftValues is a decoded struct: field IDs mapped to bool, int64, float64,
+// []byte, thriftValues or []any values
+type thriftValues map[int16]any
+
+func (v thriftValues) int(id int16) int64 {
+	n, _ := v[id].(int64)
+	return n
+}
+
+func (v thriftValues) has(id int16) bool {
+	_, exists := v[id]
+	return exists
+}
+
+func (v thriftValues) str(id int16) string {
+	b, _ := v[id].([]byte)
+	return string(b)
+}
+
+func (v thriftValues) bool(id int16) (bool, bool) {
+	b, exists := v[id].(bool)
+	return b, exists
+}
+
+func (v thriftValues) strct(id int16) thriftValues {
+	s, _ := v[id].(thriftValues)
+	return s
+}
+
+func (v thriftValues) list(id int16) []any {
+	l, _ := v[id].([]any)
+	return l
+}
+
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("thrift: unexpected end of data")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("thrift: invalid varint")
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// readStruct decodes a struct up to its stop field
+func (r *thriftReader) readStruct() (thriftValues, error) {
+	values := make(thriftValues)
+	var last int16
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return values, nil
+		}
+
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			n, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(n)
+		}
+		last = id
+
+		typ := header & 0x0F
+		switch typ {
+		case thriftTrue:
+			values[id] = true
+		case thriftFalse:
+			values[id] = false
+		default:
+			if values[id], err = r.readValue(typ); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) (any, error) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// Booleans inside lists take a byte
+		b, err := r.byte()
+		return b == thriftTrue, err
+	case thriftByte:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftDouble:
+		if r.pos+8 > len(r.data) {
+			return nil, fmt.Errorf("thrift: unexpected end of data")
+		}
+		bits := binary.LittleEndian.Uint64(r.data[r.pos:])
+		r.pos += 8
+		return math.Float64frombits(bits), nil
+	case thriftBinary:
+		size, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(r.data)-r.pos) < size {
+			return nil, fmt.Errorf("thrift: unexpected end of data")
+		}
+		b := r.data[r.pos : r.pos+int(size)]
+		r.pos += int(size)
+		return b, nil
+	case thriftList, thriftSet:
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = r.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		items := make([]any, 0, min(size, 1024))
+		for range size {
+			item, err := r.readValue(header & 0x0F)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case thriftMap:
+		size, err := r.uvarint()
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		types, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		for range size {
+			if _, err := r.readValue(types >> 4); err != nil {
+				return nil, err
+			}
+			if _, err := r.readValue(types & 0x0F); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStructType:
+		return r.readStruct()
+	}
+	return nil, fmt.Errorf("thrift: unknown type %d", typ)
+}
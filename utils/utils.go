@@ -1,12 +1,21 @@
 package utils
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	types "github.com/ashr-tech/csv-migration-tools/types"
 )
@@ -42,77 +51,608 @@ func ParseAIResponse(resp string) ([]types.ColumnSchema, error) {
 	return schema, nil
 }
 
-func ReadCSVString(csvString string) ([][]string, error) {
-	if len(csvString) == 0 {
-		return nil, fmt.Errorf("no CSV data to convert")
-	}
+// CSVOptions controls how source CSV files are parsed
+type CSVOptions struct {
+	// LazyQuotes accepts quotes inside unquoted fields and stray quotes in quoted ones
+	LazyQuotes bool
+	// FieldsPerRecord is passed to csv.Reader: -1 allows ragged rows, 0 requires
+	// every row to match the header
+	FieldsPerRecord int
+	// Delimiter separates fields; 0 detects it from the content
+	Delimiter rune
+	// MultiDelimiter separates fields with several characters, like "||" or
+	// "~|~", and takes precedence over Delimiter
+	MultiDelimiter string
+	// Encoding is the character encoding of the file; empty detects it
+	Encoding string
+	// Comment, when set, skips lines starting with this character
+	Comment rune
+	// Sheet selects the worksheet of .xlsx files by name or 1-based index
+	Sheet string
+	// HeaderRows combines this many leading .xlsx rows into the header
+	HeaderRows int
+	// Recover re-parses a malformed record line by line with lazy quotes
+	// instead of failing the whole file
+	Recover bool
+	// JSONSeparator joins nested keys of .json and .jsonl files; empty uses "."
+	JSONSeparator string
+	// Query is the SQL query run when the path is a database URL
+	Query string
+	// Quirks, when set, strips the preamble, footer and repeated headers of
+	// report exports
+	Quirks *Quirks
+	// Columns, when set, keeps only the fields of these header columns
+	// (matched after trimming spaces) as CSV and TSV records are parsed, so
+	// unused fields of wide files are never held. Every row is as wide as the
+	// kept header, missing fields being empty.
+	Columns []string
+	// Header, when set with Columns, is called with the whole header row of
+	// CSV and TSV files before its fields are kept
+	Header func(header []string)
+	// Parser selects the CSV parser: ParserFast, or encoding/csv when empty or
+	// ParserStandard. Recover always uses encoding/csv.
+	Parser string
+}
+
+// DefaultCSVOptions is the lenient parsing used when no options are given
+var DefaultCSVOptions = CSVOptions{LazyQuotes: true, FieldsPerRecord: -1}
 
-	reader := csv.NewReader(strings.NewReader(csvString))
-	records, err := reader.ReadAll()
+// ReadCSVFile reads all rows of a source file, the header first, with the
+// default parsing options
+func ReadCSVFile(path string) ([][]string, error) {
+	records, _, err := ReadCSVFileWithOptions(path, DefaultCSVOptions)
+	return records, err
+}
+
+// ReadCSVFileWithOptions reads a CSV file like ReadCSVFile with the given
+// parsing options. It also returns the line numbers recovered in Recover mode.
+func ReadCSVFileWithOptions(path string, options CSVOptions) ([][]string, []int, error) {
+	records, recovered, err := readRecords(path, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse CSV: %v", err)
+		return nil, nil, err
 	}
 
-	if len(records) < 1 {
-		return nil, fmt.Errorf("CSV has no data")
+	if len(records) < 2 {
+		return nil, nil, fmt.Errorf("CSV must have at least header and one data row")
 	}
 
-	return records, nil
+	return records, recovered, nil
+}
+
+// FormatCSV writes records as CSV text, e.g. for an AI prompt
+func FormatCSV(records [][]string) string {
+	var csvBuffer bytes.Buffer
+	csvWriter := csv.NewWriter(&csvBuffer)
+	csvWriter.WriteAll(records)
+	return csvBuffer.String()
+}
+
+// readRecords reads all rows of a CSV, TSV or .xlsx file
+func readRecords(path string, options CSVOptions) ([][]string, []int, error) {
+	records, recovered, err := readSourceRecords(path, options)
+	if err != nil || options.Quirks == nil {
+		return records, recovered, err
+	}
+	return options.Quirks.apply(records), recovered, nil
 }
 
-func ReadCSVFile(path string) (*string, error) {
-	file, err := os.Open(path)
+func readSourceRecords(path string, options CSVOptions) ([][]string, []int, error) {
+	if IsDatabaseURL(path) {
+		records, err := QueryDatabase(path, options.Query)
+		return records, nil, err
+	}
+	if IsGoogleSheet(path) {
+		records, err := ReadGoogleSheet(path)
+		return records, nil, err
+	}
+	if IsParquet(dataName(path)) {
+		records, err := ReadParquet(path)
+		return records, nil, err
+	}
+	if IsXLSX(dataName(path)) {
+		records, err := ReadXLSX(path, options.Sheet, options.HeaderRows)
+		return records, nil, err
+	}
+
+	raw, name, err := ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	reader.LazyQuotes = true       // Allow lazy quotes
+	content, err := DecodeText(raw, options.Encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A byte order mark would otherwise end up in the first header name
+	content = strings.TrimPrefix(content, utf8BOM)
+
+	if IsJSON(name) {
+		records, err := ReadJSONRecords(content, options.JSONSeparator)
+		return records, nil, err
+	}
+
+	if options.Delimiter == 0 && options.MultiDelimiter == "" {
+		switch {
+		case IsTSV(name):
+			options.Delimiter = '\t'
+		default:
+			if options.MultiDelimiter = DetectMultiDelimiter(content); options.MultiDelimiter == "" {
+				options.Delimiter = DetectDelimiter(content)
+			}
+		}
+	}
+	if options.MultiDelimiter != "" {
+		if content, options.Delimiter, err = splitMultiDelimiter(content, options.MultiDelimiter); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return parseCSV(content, options)
+}
+
+func newCSVReader(r io.Reader, options CSVOptions) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.LazyQuotes = options.LazyQuotes
 	reader.TrimLeadingSpace = true // Trim spaces after delimiters
+	reader.FieldsPerRecord = options.FieldsPerRecord
+	reader.Comment = options.Comment
+	if options.Delimiter != 0 {
+		reader.Comma = options.Delimiter
+	}
+	return reader
+}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+// parseCSV reads all records of content. In Recover mode, the first line of a
+// record that fails to parse is parsed again on its own with lazy quotes and
+// strict parsing resumes on the next line, so a stray or unterminated quote
+// does not swallow the rest of the file.
+func parseCSV(content string, options CSVOptions) ([][]string, []int, error) {
+	project := projectColumns(options.Columns, options.Header)
+	if options.Parser == ParserFast && !options.Recover {
+		records, err := parseFast(content, options, project)
+		return records, nil, err
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV must have at least header and one data row")
+	reader := newCSVReader(strings.NewReader(content), options)
+	if !options.Recover {
+		if project == nil {
+			records, err := reader.ReadAll()
+			return records, nil, err
+		}
+
+		// Projected fields are copied, so the parsed record can be reused
+		reader.ReuseRecord = true
+		var records [][]string
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return records, nil, nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			records = append(records, project(record))
+		}
+	}
+	if project == nil {
+		project = func(record []string) []string { return record }
 	}
 
-	headers := records[0]
-	dataRows := records[1:]
+	lines := strings.SplitAfter(content, "\n")
+	lenient := options
+	lenient.LazyQuotes = true
 
-	var csvBuffer bytes.Buffer
-	csvWriter := csv.NewWriter(&csvBuffer)
+	var records [][]string
+	var recovered []int
+	offset := 0 // lines consumed before the current reader started
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err != csv.ErrFieldCount {
+			line := offset + parseErr.StartLine
+			lineRecords, err := newCSVReader(strings.NewReader(lines[line-1]), lenient).ReadAll()
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			for _, record := range lineRecords {
+				records = append(records, project(record))
+			}
+			recovered = append(recovered, line)
+
+			offset = line
+			reader = newCSVReader(strings.NewReader(strings.Join(lines[offset:], "")), options)
+			continue
+		}
+		if err != nil && record == nil {
+			return nil, nil, err
+		}
+
+		records = append(records, project(record))
+	}
+
+	return records, recovered, nil
+}
+
+// projectColumns returns a function keeping the fields of columns in each
+// record, taking the first record as the header, which it passes to header
+// when set, or nil to keep all fields. Kept fields are copied so the rest of
+// the parsed record can be freed.
+func projectColumns(columns []string, header func([]string)) func(record []string) []string {
+	if len(columns) == 0 {
+		return nil
+	}
 
-	csvWriter.Write(headers)
+	var keep []int
+	return func(record []string) []string {
+		if keep == nil {
+			if header != nil {
+				names := make([]string, len(record))
+				for i, name := range record {
+					names[i] = strings.Clone(name)
+				}
+				header(names)
+			}
+			keep = []int{}
+			for i, name := range record {
+				if slices.Contains(columns, strings.TrimSpace(name)) {
+					keep = append(keep, i)
+				}
+			}
+		}
 
-	for i := range len(dataRows) {
-		csvWriter.Write(dataRows[i])
+		projected := make([]string, len(keep))
+		for j, i := range keep {
+			if i < len(record) {
+				projected[j] = strings.Clone(record[i])
+			}
+		}
+		return projected
 	}
+}
+
+// WriteOptions controls how output CSV files are written
+type WriteOptions struct {
+	// Delimiter separates fields; 0 means a comma
+	Delimiter rune
+	// Encoding is the character encoding to write; empty means UTF-8
+	Encoding string
+	// QuoteAll quotes every field instead of only those that need it
+	QuoteAll bool
+	// CRLF ends lines with \r\n instead of \n
+	CRLF bool
+	// Compress writes new files as "gzip" or "zip"; the path should end in
+	// CompressedExtension
+	Compress string
+	// BOM starts new UTF-8 files with a byte order mark, which Excel needs
+	// to recognize them as UTF-8
+	BOM bool
+	// ColumnTypes maps header names to schema types; .jsonl, .parquet, .avro
+	// and .arrow files write number, integer and boolean columns as numbers
+	// and booleans
+	ColumnTypes map[string]string
+	// SQL configures the INSERT statements of .sql files
+	SQL SQLOptions
+	// FlushRows is how many rows of CSV, TSV and JSON Lines files are
+	// written between flushes to the file; 0 means DefaultFlushRows
+	FlushRows int
+}
 
-	csvWriter.Flush()
+// DefaultFlushRows is how many rows are written between flushes by default
+const DefaultFlushRows = 10000
 
-	csv := csvBuffer.String()
+// writeBufferSize is the buffer that rows are written through
+const writeBufferSize = 256 * 1024
 
-	return &csv, nil
+func (o WriteOptions) flushRows() int {
+	if o.FlushRows > 0 {
+		return o.FlushRows
+	}
+	return DefaultFlushRows
 }
 
+// utf8BOM is the byte order mark as it appears in decoded text
+const utf8BOM = "\uFEFF"
+
 func WriteCSV(path string, records [][]string) error {
+	return WriteCSVWithOptions(path, records, WriteOptions{})
+}
+
+// WriteCSVWithOptions writes records like WriteCSV with the given options.
+// Paths ending in .xlsx are written as a workbook with a single sheet.
+func WriteCSVWithOptions(path string, records [][]string, options WriteOptions) error {
+	if IsXLSX(path) {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		return WriteXLSX(path, []Sheet{{Name: name, Records: records}})
+	}
+	if IsParquet(path) {
+		return WriteParquet(path, records, options.ColumnTypes)
+	}
+	if IsAvro(path) {
+		return WriteAvro(path, records, options.ColumnTypes)
+	}
+	if IsArrow(path) {
+		return WriteArrow(path, records, options.ColumnTypes)
+	}
+
 	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	// The format follows from the name inside the compressed file
+	name := strings.TrimSuffix(path, CompressedExtension(options.Compress))
+	w, finish, err := compressWriter(file, name, options.Compress)
+	if err != nil {
+		return err
+	}
+
+	if options.BOM {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	if err := writeRecords(w, name, records, options); err != nil {
+		return err
+	}
+
+	return finish()
+}
+
+// writeRecords writes records to file in the configured delimiter and
+// encoding. Rows go through a large buffer in chunks of FlushRows, each
+// flushed to the file before the next, so that writing starts right away.
+func writeRecords(file io.Writer, path string, records [][]string, options WriteOptions) error {
+	buffered := bufio.NewWriterSize(file, writeBufferSize)
+
+	if IsJSONL(path) {
+		if len(records) == 0 {
+			return nil
+		}
+		err := writeChunks(buffered, records[1:], options.flushRows(), func(rows [][]string) error {
+			return writeJSONL(buffered, records[0], rows, options)
+		})
+		if err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}
+	if IsSQL(path) {
+		lineEnding := "\n"
+		if options.CRLF {
+			lineEnding = "\r\n"
+		}
+		if err := writeSQLInserts(buffered, records, options.SQL, options.ColumnTypes, lineEnding); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}
+
+	if options.Delimiter == 0 && IsTSV(path) {
+		options.Delimiter = '\t'
+	}
+
+	encoded, err := encodingWriter(buffered, options.Encoding)
+	if err != nil {
+		return err
+	}
+
+	writer := newCSVWriter(encoded, options)
+	err = writeChunks(buffered, records, options.flushRows(), func(rows [][]string) error {
+		if options.QuoteAll {
+			return writeQuoted(encoded, rows, options)
+		}
+		return writer.WriteAll(rows)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	if err := encoded.Close(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// writeChunks writes rows in chunks of size with write, flushing buffered to
+// the file after each chunk
+func writeChunks(buffered *bufio.Writer, rows [][]string, size int, write func(rows [][]string) error) error {
+	for len(rows) > 0 {
+		chunk := rows[:min(size, len(rows))]
+		if err := write(chunk); err != nil {
+			return err
+		}
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+		rows = rows[len(chunk):]
+	}
+	return nil
+}
+
+// IsTSV reports whether path has a .tsv extension, which implies tab-separated fields
+func IsTSV(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".tsv")
+}
+
+func newCSVWriter(w io.Writer, options WriteOptions) *csv.Writer {
+	writer := csv.NewWriter(w)
+	if options.Delimiter != 0 {
+		writer.Comma = options.Delimiter
+	}
+	writer.UseCRLF = options.CRLF
+	return writer
+}
+
+// writeQuoted writes records with every field quoted, which csv.Writer
+// cannot do
+func writeQuoted(w io.Writer, records [][]string, options WriteOptions) error {
+	delimiter, lineEnd := ",", "\n"
+	if options.Delimiter != 0 {
+		delimiter = string(options.Delimiter)
+	}
+	if options.CRLF {
+		lineEnd = "\r\n"
+	}
+
+	buffered := bufio.NewWriter(w)
+	for _, record := range records {
+		for i, field := range record {
+			if i > 0 {
+				buffered.WriteString(delimiter)
+			}
+			if options.CRLF {
+				// Match csv.Writer, which writes embedded newlines as \r\n too
+				field = strings.ReplaceAll(strings.ReplaceAll(field, "\r\n", "\n"), "\n", "\r\n")
+			}
+			buffered.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`)
+		}
+		buffered.WriteString(lineEnd)
+	}
+
+	return buffered.Flush()
+}
+
+// WriteCSVParts writes records into files of at most rowsPerPart data rows
+// each, repeating the header row (records[0]) in every part. The part path is
+// built by formatting pathFormat with the 1-based part number. Up to workers
+// parts are written at once.
+func WriteCSVParts(pathFormat string, records [][]string, rowsPerPart, workers int, options WriteOptions) ([]string, error) {
+	if len(records) == 0 || rowsPerPart <= 0 {
+		return nil, fmt.Errorf("nothing to split")
+	}
+
+	var parts [][][]string
+	header, rows := records[0], records[1:]
+	for len(parts) == 0 || len(rows) > 0 {
+		size := min(rowsPerPart, len(rows))
+		parts = append(parts, append([][]string{header}, rows[:size]...))
+		rows = rows[size:]
+	}
+
+	return WriteCSVFiles(pathFormat, parts, workers, options)
+}
+
+// WriteCSVFiles writes every record set of parts to its own file, named by
+// formatting pathFormat with the 1-based part number. Up to workers files are
+// written at once, each by its own goroutine. Once a part fails no further
+// parts are started, and the parts already written are removed so that a
+// failed run leaves no partial set of files behind.
+func WriteCSVFiles(pathFormat string, parts [][][]string, workers int, options WriteOptions) ([]string, error) {
+	errs := make([]error, len(parts))
+	started := make([]bool, len(parts))
+	slots := make(chan struct{}, max(1, workers))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i, records := range parts {
+		slots <- struct{}{}
+		if failed.Load() {
+			<-slots
+			break
+		}
+		started[i] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = WriteCSVWithOptions(fmt.Sprintf(pathFormat, i+1), records, options); errs[i] != nil {
+				failed.Store(true)
+			}
+			<-slots
+		}()
+	}
+	wg.Wait()
+
+	paths := make([]string, 0, len(parts))
+	for i := range parts {
+		paths = append(paths, fmt.Sprintf(pathFormat, i+1))
+	}
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		for j, path := range paths {
+			if started[j] {
+				os.Remove(path)
+			}
+		}
+		return nil, fmt.Errorf("part %d: %v", i+1, err)
+	}
+
+	return paths, nil
+}
+
+// AppendCSV appends records to an existing CSV file, skipping the header row
+// (records[0]) after checking it matches the file's header. Missing or empty
+// files are written in full.
+func AppendCSV(path string, records [][]string, options WriteOptions) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.Size() == 0) {
+		return WriteCSVWithOptions(path, records, options)
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// JSON Lines and SQL scripts have no header, every row carries its columns
+	if IsSQL(path) {
+		return writeRecords(file, path, records, options)
+	}
+	if IsJSONL(path) {
+		return writeRecords(file, path, records, options)
+	}
+
+	// Compare headers the way the file was written; the header is well within
+	// the first 64 KB
+	content, err := io.ReadAll(io.LimitReader(file, 64*1024))
+	if err != nil {
+		return err
+	}
+	text, err := DecodeText(content, options.Encoding)
+	if err != nil {
+		return err
+	}
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(text, utf8BOM)))
+	if options.Delimiter != 0 {
+		reader.Comma = options.Delimiter
+	} else if IsTSV(path) {
+		reader.Comma = '\t'
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header of %s: %v", path, err)
+	}
+	if len(records) > 0 && !slices.Equal(header, records[0]) {
+		return fmt.Errorf("header of %s does not match the output columns", path)
+	}
+
+	if len(records) > 1 {
+		return writeRecords(file, path, records[1:], options)
+	}
 
-	return writer.WriteAll(records)
+	return nil
 }
 
+// SaveJSON writes data as indented JSON to a local file or a remote path
 func SaveJSON(path string, data interface{}) error {
+	if IsRemote(path) {
+		encoded, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		return WriteRemote(path, append(encoded, '\n'))
+	}
+
 	file, err := os.Create(path)
 	if err != nil {
 		return err
@@ -124,18 +664,75 @@ func SaveJSON(path string, data interface{}) error {
 	return encoder.Encode(data)
 }
 
+// schemaCache holds the schemas read by LoadSchemaJSON
+var schemaCache FileCache[[]types.ColumnSchema]
+
+// LoadSchemaJSON reads a schema file. Local files are parsed once and every
+// call returns its own copy, which the caller may change.
 func LoadSchemaJSON(path string) ([]types.ColumnSchema, error) {
-	file, err := os.Open(path)
+	schema, err := schemaCache.Get(path, "", func() ([]types.ColumnSchema, error) {
+		var schema []types.ColumnSchema
+		if err := LoadJSON(path, &schema); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	return cloneSchema(schema), nil
+}
+
+// cloneSchema copies schema down to the values and lookups that conversions
+// fill in or extend. Values mappings are not copied, as With leaves them
+// unchanged.
+func cloneSchema(schema []types.ColumnSchema) []types.ColumnSchema {
+	if schema == nil {
+		return nil
+	}
+	clone := slices.Clone(schema)
+	for i := range clone {
+		col := &clone[i]
+		col.Values = slices.Clone(col.Values)
+		if col.Lookup != nil {
+			lookup := *col.Lookup
+			col.Lookup = &lookup
+		}
+	}
+	return clone
+}
+
+func LoadJSON(path string, v interface{}) error {
+	data, err := readData(path)
+	if err != nil {
+		return err
+	}
+
+	return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 digest of a file's content
+func FileSHA256(path string) (string, error) {
+	if IsRemote(path) && !isStreamableRemote(path) {
+		data, err := readData(path)
+		if err != nil {
+			return "", err
+		}
+		hash := sha256.Sum256(data)
+		return hex.EncodeToString(hash[:]), nil
+	}
+
+	file, err := openData(path)
+	if err != nil {
+		return "", err
+	}
 	defer file.Close()
 
-	var schema []types.ColumnSchema
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&schema); err != nil {
-		return nil, err
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
 	}
 
-	return schema, nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
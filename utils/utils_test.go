@@ -0,0 +1,48 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ashr-tech/csv-migration-tools/fixture"
+	"github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// fixtureDir keeps the fixtures between runs, shared with the converter
+// benchmarks
+var fixtureDir = filepath.Join(os.TempDir(), "csv-migration-tools-fixtures")
+
+func BenchmarkReadNarrow(b *testing.B) {
+	benchmarkRead(b, fixture.Narrow, utils.ParserStandard)
+}
+
+func BenchmarkReadNarrowFastParser(b *testing.B) {
+	benchmarkRead(b, fixture.Narrow, utils.ParserFast)
+}
+
+func BenchmarkReadWide(b *testing.B) {
+	benchmarkRead(b, fixture.Wide, utils.ParserStandard)
+}
+
+func BenchmarkReadWideFastParser(b *testing.B) {
+	benchmarkRead(b, fixture.Wide, utils.ParserFast)
+}
+
+// benchmarkRead reads and parses a whole fixture
+func benchmarkRead(b *testing.B, spec fixture.Spec, parser string) {
+	files, err := fixture.Write(fixtureDir, spec)
+	if err != nil {
+		b.Fatal(err)
+	}
+	options := utils.CSVOptions{LazyQuotes: true, FieldsPerRecord: -1, Parser: parser}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := utils.ReadCSVFileWithOptions(files.Data, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(spec.Rows)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+}
@@ -0,0 +1,359 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IsXLSX reports whether path names an Excel workbook, optionally followed by
+// "!<sheet>"
+func IsXLSX(path string) bool {
+	file, _, _ := strings.Cut(path, "!")
+	return strings.EqualFold(filepath.Ext(file), ".xlsx")
+}
+
+// ReadXLSX reads the rows of one worksheet of an .xlsx workbook. The sheet is
+// selected by name or 1-based index, from "book.xlsx!<sheet>" or else from
+// sheet; the first sheet is read by default. Merged cells repeat their value
+// in every cell of the range, and the first headerRows rows (when more than
+// one) are combined into a single header, so "Q1" over "Sales" becomes
+// "Q1 Sales". Empty rows are dropped. Values are the cell text as stored:
+// numbers and dates keep Excel's raw representation.
+func ReadXLSX(file string, sheet string, headerRows int) ([][]string, error) {
+	file, selected, found := strings.Cut(file, "!")
+	if found {
+		sheet = selected
+	}
+
+	data, err := readData(file)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := findSheet(files, sheet)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	var sharedStrings []string
+	if f, exists := files["xl/sharedStrings.xml"]; exists {
+		if sharedStrings, err = readSharedStrings(f); err != nil {
+			return nil, fmt.Errorf("%s: failed to read shared strings: %v", file, err)
+		}
+	}
+
+	f, exists := files[sheetPath]
+	if !exists {
+		return nil, fmt.Errorf("%s: missing worksheet %s", file, sheetPath)
+	}
+	rows, err := readWorksheet(f, sharedStrings)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read worksheet: %v", file, err)
+	}
+
+	return combineHeaderRows(rows, headerRows), nil
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// findSheet returns the path inside the archive of the selected worksheet
+func findSheet(files map[string]*zip.File, sheet string) (string, error) {
+	var workbook xlsxWorkbook
+	if err := decodeXMLFile(files["xl/workbook.xml"], &workbook); err != nil {
+		return "", fmt.Errorf("failed to read workbook: %v", err)
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheets")
+	}
+
+	index := -1
+	if sheet == "" {
+		index = 0
+	}
+	for i, s := range workbook.Sheets {
+		if s.Name == sheet {
+			index = i
+			break
+		}
+	}
+	if n, err := strconv.Atoi(sheet); index == -1 && err == nil && n >= 1 && n <= len(workbook.Sheets) {
+		index = n - 1
+	}
+	if index == -1 {
+		names := make([]string, len(workbook.Sheets))
+		for i, s := range workbook.Sheets {
+			names[i] = s.Name
+		}
+		return "", fmt.Errorf("no sheet %q (sheets: %s)", sheet, strings.Join(names, ", "))
+	}
+
+	var rels xlsxRelationships
+	if err := decodeXMLFile(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return "", fmt.Errorf("failed to read workbook relationships: %v", err)
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[index].RID {
+			// Targets are relative to xl/ unless absolute
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+
+	return "", fmt.Errorf("sheet %q has no worksheet part", workbook.Sheets[index].Name)
+}
+
+func decodeXMLFile(f *zip.File, v any) error {
+	if f == nil {
+		return fmt.Errorf("file not found")
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// xlsxText is a string item with either plain text or rich text runs
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if err := decodeXMLFile(f, &sst); err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		strs[i] = item.String()
+	}
+	return strs, nil
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// readWorksheet streams the rows of a worksheet and fills merged ranges
+func readWorksheet(f *zip.File, sharedStrings []string) ([][]string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var rows [][]string
+	var merges []string
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "row":
+			var row struct {
+				Ref   int        `xml:"r,attr"`
+				Cells []xlsxCell `xml:"c"`
+			}
+			if err := decoder.DecodeElement(&row, &start); err != nil {
+				return nil, err
+			}
+
+			rowIdx := len(rows)
+			if row.Ref > 0 {
+				rowIdx = row.Ref - 1
+			}
+			for len(rows) <= rowIdx {
+				rows = append(rows, nil)
+			}
+
+			for i, cell := range row.Cells {
+				col := i
+				if cell.Ref != "" {
+					if c, _, err := parseCellRef(cell.Ref); err == nil {
+						col = c
+					}
+				}
+				value, err := cellValue(cell, sharedStrings)
+				if err != nil {
+					return nil, fmt.Errorf("cell %s: %v", cell.Ref, err)
+				}
+				rows[rowIdx] = setCell(rows[rowIdx], col, value)
+			}
+
+		case "mergeCell":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "ref" {
+					merges = append(merges, attr.Value)
+				}
+			}
+		}
+	}
+
+	// Repeat the top-left value of each merged range in all of its cells
+	for _, ref := range merges {
+		from, to, _ := strings.Cut(ref, ":")
+		firstCol, firstRow, err1 := parseCellRef(from)
+		lastCol, lastRow, err2 := parseCellRef(to)
+		if err1 != nil || err2 != nil || firstRow >= len(rows) {
+			continue
+		}
+
+		value := ""
+		if firstCol < len(rows[firstRow]) {
+			value = rows[firstRow][firstCol]
+		}
+		for row := firstRow; row <= lastRow && row < len(rows); row++ {
+			for col := firstCol; col <= lastCol; col++ {
+				rows[row] = setCell(rows[row], col, value)
+			}
+		}
+	}
+
+	// Drop empty rows
+	kept := rows[:0]
+	for _, row := range rows {
+		if strings.Join(row, "") != "" {
+			kept = append(kept, row)
+		}
+	}
+
+	return kept, nil
+}
+
+func cellValue(cell xlsxCell, sharedStrings []string) (string, error) {
+	switch cell.Type {
+	case "s":
+		idx, err := strconv.Atoi(cell.Value)
+		if err != nil || idx < 0 || idx >= len(sharedStrings) {
+			return "", fmt.Errorf("invalid shared string %q", cell.Value)
+		}
+		return sharedStrings[idx], nil
+	case "inlineStr":
+		return cell.Inline.String(), nil
+	case "b":
+		if cell.Value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+
+	return cell.Value, nil
+}
+
+func setCell(row []string, col int, value string) []string {
+	for len(row) <= col {
+		row = append(row, "")
+	}
+	row[col] = value
+	return row
+}
+
+// parseCellRef converts a reference like "B3" to 0-based column and row
+func parseCellRef(ref string) (int, int, error) {
+	i := 0
+	col := 0
+	for i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z' {
+		col = col*26 + int(ref[i]-'A'+1)
+		i++
+	}
+	row, err := strconv.Atoi(ref[i:])
+	if i == 0 || err != nil || row < 1 {
+		return 0, 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+
+	return col - 1, row - 1, nil
+}
+
+// combineHeaderRows joins the first headerRows rows column by column into a
+// single header row, skipping empty and repeated parts
+func combineHeaderRows(rows [][]string, headerRows int) [][]string {
+	if headerRows <= 1 || len(rows) < headerRows {
+		return rows
+	}
+
+	width := 0
+	for _, row := range rows[:headerRows] {
+		width = max(width, len(row))
+	}
+
+	header := make([]string, width)
+	for col := range header {
+		var parts []string
+		for _, row := range rows[:headerRows] {
+			if col < len(row) {
+				part := strings.TrimSpace(row[col])
+				if part != "" && (len(parts) == 0 || parts[len(parts)-1] != part) {
+					parts = append(parts, part)
+				}
+			}
+		}
+		header[col] = strings.Join(parts, " ")
+	}
+
+	return append([][]string{header}, rows[headerRows:]...)
+}
@@ -0,0 +1,161 @@
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// Compare diffs converted records cell by cell against the records of a
+// known-good target sample, both starting with their header. Columns are
+// matched by name, so their order does not matter. Rows are matched by
+// position, or by the values of the key columns when given, so the sample may
+// hold its rows in another order.
+func Compare(actual, expected [][]string, key []string, examples int) (*types.EquivalenceReport, error) {
+	if len(actual) == 0 || len(expected) == 0 {
+		return nil, fmt.Errorf("no header to compare")
+	}
+
+	report := &types.EquivalenceReport{Key: key, Rows: len(expected) - 1, Columns: []types.ColumnEquivalence{}}
+	actualHeader, expectedHeader := trimHeader(actual[0]), trimHeader(expected[0])
+	for _, name := range actualHeader {
+		if !slices.Contains(expectedHeader, name) {
+			report.ExtraColumns = append(report.ExtraColumns, name)
+		}
+	}
+
+	// The columns to compare, at their index in both headers
+	var columns []*types.ColumnEquivalence
+	var actualIdx, expectedIdx []int
+	for i, name := range expectedHeader {
+		j := slices.Index(actualHeader, name)
+		if j < 0 {
+			report.MissingColumns = append(report.MissingColumns, name)
+			continue
+		}
+		report.Columns = append(report.Columns, types.ColumnEquivalence{Column: name})
+		actualIdx, expectedIdx = append(actualIdx, j), append(expectedIdx, i)
+	}
+	for i := range report.Columns {
+		columns = append(columns, &report.Columns[i])
+	}
+
+	compareRows := func(actualRow, expectedRow []string, rowNumber int, rowKey string) {
+		for c, col := range columns {
+			got, want := field(actualRow, actualIdx[c]), field(expectedRow, expectedIdx[c])
+			report.Cells++
+			if got == want {
+				continue
+			}
+			col.Mismatches++
+			if len(col.Examples) < examples {
+				col.Examples = append(col.Examples, types.CellMismatch{Row: rowNumber, Key: rowKey, Expected: want, Actual: got})
+			}
+		}
+	}
+
+	if len(key) == 0 {
+		rows := min(len(actual), len(expected))
+		for r := 1; r < rows; r++ {
+			compareRows(actual[r], expected[r], r+1, "")
+		}
+		report.MissingRows = max(0, len(expected)-len(actual))
+		report.ExtraRows = max(0, len(actual)-len(expected))
+	} else {
+		actualKey, err := keyIndexes(actualHeader, key)
+		if err != nil {
+			return nil, fmt.Errorf("converted rows: %v", err)
+		}
+		expectedKey, err := keyIndexes(expectedHeader, key)
+		if err != nil {
+			return nil, fmt.Errorf("expected sample: %v", err)
+		}
+
+		byKey := make(map[string]int, len(actual)-1)
+		for r, row := range actual[1:] {
+			k := keyOf(row, actualKey)
+			if _, duplicate := byKey[k]; duplicate {
+				return nil, fmt.Errorf("key %s is not unique in the converted rows", displayKey(k))
+			}
+			byKey[k] = r + 1
+		}
+		matched := make(map[string]bool, len(expected)-1)
+		for r, row := range expected[1:] {
+			k := keyOf(row, expectedKey)
+			if matched[k] {
+				return nil, fmt.Errorf("key %s is not unique in the expected sample", displayKey(k))
+			}
+			matched[k] = true
+			a, found := byKey[k]
+			if !found {
+				report.MissingRows++
+				if len(report.MissingKeys) < examples {
+					report.MissingKeys = append(report.MissingKeys, displayKey(k))
+				}
+				continue
+			}
+			compareRows(actual[a], row, r+2, displayKey(k))
+		}
+		for _, row := range actual[1:] {
+			if k := keyOf(row, actualKey); !matched[k] {
+				report.ExtraRows++
+				if len(report.ExtraKeys) < examples {
+					report.ExtraKeys = append(report.ExtraKeys, displayKey(k))
+				}
+			}
+		}
+	}
+
+	report.Passed = len(report.MissingColumns) == 0 && len(report.ExtraColumns) == 0 &&
+		report.MissingRows == 0 && report.ExtraRows == 0
+	for _, col := range report.Columns {
+		if col.Mismatches > 0 {
+			report.Passed = false
+		}
+	}
+	return report, nil
+}
+
+func trimHeader(header []string) []string {
+	names := make([]string, len(header))
+	for i, name := range header {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names
+}
+
+func field(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+func keyIndexes(header, key []string) ([]int, error) {
+	indexes := make([]int, len(key))
+	for i, name := range key {
+		if indexes[i] = slices.Index(header, name); indexes[i] < 0 {
+			return nil, fmt.Errorf("key column %q not found", name)
+		}
+	}
+	return indexes, nil
+}
+
+// keyOf joins the key values of a row with the unit separator, which does
+// not occur in text data, so that different keys never join alike
+func keyOf(row []string, indexes []int) string {
+	values := make([]string, len(indexes))
+	for i, index := range indexes {
+		values[i] = field(row, index)
+	}
+	return strings.Join(values, keySeparator)
+}
+
+const keySeparator = "\x1f"
+
+// displayKey returns a key of keyOf as the report shows it
+func displayKey(key string) string {
+	return strings.ReplaceAll(key, keySeparator, "|")
+}
@@ -0,0 +1,126 @@
+package validate
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	types "github.com/ashr-tech/csv-migration-tools/types"
+)
+
+// renameSimilarity is the least similarity of the names of a missing column
+// and an extra header column for them to be reported as a likely rename
+const renameSimilarity = 0.6
+
+// Header compares the header of a source file with the columns of its
+// source schema, matched by name or alias after trimming spaces. Missing
+// columns are paired with the most similar extra header columns as likely
+// renames.
+func Header(header []string, schema []types.ColumnSchema) *types.HeaderReport {
+	report := &types.HeaderReport{}
+	names := trimHeader(header)
+	used := make(map[string]bool, len(names))
+
+	var columns []types.ColumnSchema
+	seen := make(map[string]bool)
+	for _, col := range schema {
+		if col.Column == "" || seen[col.Column] {
+			continue
+		}
+		seen[col.Column] = true
+		columns = append(columns, col)
+	}
+
+	for _, col := range columns {
+		if slices.Contains(names, col.Column) {
+			used[col.Column] = true
+			continue
+		}
+		alias := ""
+		for _, name := range col.Aliases {
+			if slices.Contains(names, strings.TrimSpace(name)) {
+				alias = strings.TrimSpace(name)
+				break
+			}
+		}
+		if alias == "" {
+			report.Missing = append(report.Missing, col.Column)
+			continue
+		}
+		used[alias] = true
+		report.Aliased = append(report.Aliased, types.HeaderMatch{Column: col.Column, Header: alias})
+	}
+
+	for _, name := range names {
+		if name != "" && !used[name] && !slices.Contains(report.Extra, name) {
+			report.Extra = append(report.Extra, name)
+		}
+	}
+
+	// Pair each missing column with the most similar extra column left
+	paired := make(map[string]bool)
+	for _, column := range report.Missing {
+		best, bestScore := "", renameSimilarity
+		for _, name := range report.Extra {
+			if score := similarity(column, name); !paired[name] && score >= bestScore {
+				best, bestScore = name, score
+			}
+		}
+		if best != "" {
+			paired[best] = true
+			report.Renames = append(report.Renames, types.HeaderMatch{Column: column, Header: best})
+		}
+	}
+
+	report.Compatible = len(report.Missing) == 0
+	return report
+}
+
+// similarity scores how alike two column names are, from 0 to 1, ignoring
+// case, spaces and punctuation
+func similarity(a, b string) float64 {
+	a, b = foldName(a), foldName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	if len(a) >= 3 && len(b) >= 3 && (strings.Contains(a, b) || strings.Contains(b, a)) {
+		return 0.8
+	}
+	longest := max(len([]rune(a)), len([]rune(b)))
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+func foldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// editDistance counts the characters inserted, deleted or replaced to turn
+// a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,691 @@
+// Package validate checks a converted file against its target schema, before
+// it is handed to the target's importer, or a source file against the rules
+// of its source schema.
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ashr-tech/csv-migration-tools/transform"
+	types "github.com/ashr-tech/csv-migration-tools/types"
+	utils "github.com/ashr-tech/csv-migration-tools/utils"
+)
+
+// DefaultExamples is how many failing values are kept of each check
+const DefaultExamples = 5
+
+// Options tune a validation
+type Options struct {
+	// Examples is how many failing values are kept of each check
+	Examples int
+	// Source checks a source file against the rules of its source schema,
+	// instead of a converted file against its target schema
+	Source bool
+	// DayFirst prefers DD/MM over MM/DD when detecting the format of dates
+	// checked against a date range
+	DayFirst bool
+}
+
+// DefaultOptions are the options of the validate command
+var DefaultOptions = Options{Examples: DefaultExamples, DayFirst: true}
+
+// File validates every row of a file against the schema at schemaPath.
+// Local CSV and TSV files are read one record at a time.
+func File(path, schemaPath string, csvOptions utils.CSVOptions, options Options) (*types.ValidationReport, error) {
+	schema, err := utils.LoadSchemaJSON(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading schema: %v", err)
+	}
+	if err := CheckSchema(schema); err != nil {
+		return nil, err
+	}
+
+	var v *Validator
+	err = utils.EachRecord(path, csvOptions, func(record []string) error {
+		if v == nil {
+			v = New(record, schema, options)
+		} else {
+			v.Add(record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%s is empty", utils.RedactURL(path))
+	}
+
+	report := v.Report()
+	report.File, report.Schema = utils.RedactURL(path), schemaPath
+	return report, nil
+}
+
+// Validator checks rows added one at a time against a schema
+type Validator struct {
+	width        int
+	headerErrors []string
+	columns      []*column
+	keys         []*uniqueKey
+	rows         int
+	ragged       int
+}
+
+// New returns a validator of the rows under header. The header of a converted
+// file must hold the target columns in schema order, each once and nothing
+// else; that of a source file must hold the source columns of the schema.
+func New(header []string, schema []types.ColumnSchema, options Options) *Validator {
+	v := &Validator{width: len(header)}
+
+	names := make([]string, len(header))
+	positions := make(map[string]int)
+	for i, name := range header {
+		names[i] = strings.TrimSpace(name)
+		if _, seen := positions[names[i]]; !seen {
+			positions[names[i]] = i
+		}
+	}
+	if options.Source {
+		return v.source(schema, positions, options)
+	}
+	expected := make(map[string]bool)
+	for _, col := range schema {
+		expected[col.Column] = true
+	}
+
+	// The columns found must come in schema order, whatever is missing or
+	// added around them
+	var found []string
+	counted := make(map[string]bool)
+	for i, name := range names {
+		switch {
+		case !expected[name]:
+			v.headerErrors = append(v.headerErrors, fmt.Sprintf("unexpected column %q", name))
+		case positions[name] != i:
+			if !counted[name] {
+				v.headerErrors = append(v.headerErrors, fmt.Sprintf("column %q appears %d times", name, count(names, name)))
+				counted[name] = true
+			}
+		default:
+			found = append(found, name)
+		}
+	}
+	misplaced := false
+	for _, col := range schema {
+		if _, present := positions[col.Column]; !present {
+			v.headerErrors = append(v.headerErrors, fmt.Sprintf("missing column %q", col.Column))
+			continue
+		}
+		if len(found) > 0 {
+			misplaced = misplaced || found[0] != col.Column
+			found = found[1:]
+		}
+	}
+	if misplaced {
+		order := make([]string, len(schema))
+		for i, col := range schema {
+			order[i] = col.Column
+		}
+		v.headerErrors = append(v.headerErrors, "columns out of order, expected: "+strings.Join(order, ", "))
+	}
+
+	for _, col := range schema {
+		index, found := positions[col.Column]
+		if !found {
+			index = -1
+		}
+		v.columns = append(v.columns, newColumn(col, index, options))
+	}
+
+	for _, columns := range uniqueKeys(schema) {
+		if key := newUniqueKey(columns, positions, options.Examples); key != nil {
+			v.keys = append(v.keys, key)
+		}
+	}
+	return v
+}
+
+// source prepares the checks of the source columns of a source schema, which
+// the file may hold in any order among others. Only required columns and
+// rules are checked, as types and values describe the target.
+func (v *Validator) source(schema []types.ColumnSchema, positions map[string]int, options Options) *Validator {
+	added := make(map[string]bool)
+	for _, col := range schema {
+		if col.Column == "" || added[col.Column] {
+			continue
+		}
+		added[col.Column] = true
+
+		index, found := positions[col.Column]
+		for _, alias := range col.Aliases {
+			if !found {
+				index, found = positions[strings.TrimSpace(alias)]
+			}
+		}
+		if !found {
+			v.headerErrors = append(v.headerErrors, fmt.Sprintf("missing column %q", col.Column))
+			index = -1
+		}
+		c := &column{schema: col, index: index, examples: options.Examples}
+		c.addRules(options.DayFirst)
+		v.columns = append(v.columns, c)
+	}
+	return v
+}
+
+func count(names []string, name string) int {
+	n := 0
+	for _, other := range names {
+		if other == name {
+			n++
+		}
+	}
+	return n
+}
+
+// Add checks a data row. Missing fields are checked as empty values.
+func (v *Validator) Add(row []string) {
+	v.rows++
+	if len(row) != v.width {
+		v.ragged++
+	}
+	for _, col := range v.columns {
+		if col.index < 0 {
+			continue
+		}
+		value := ""
+		if col.index < len(row) {
+			value = row[col.index]
+		}
+		col.check(value, v.rows+1)
+	}
+	for _, key := range v.keys {
+		key.check(row, v.rows+1)
+	}
+}
+
+// Report returns the result of the checks on the rows added
+func (v *Validator) Report() *types.ValidationReport {
+	report := &types.ValidationReport{
+		Rows:         v.rows,
+		HeaderErrors: v.headerErrors,
+		RaggedRows:   v.ragged,
+		Columns:      []types.ColumnValidation{},
+	}
+	report.Passed = len(v.headerErrors) == 0 && v.ragged == 0
+	for _, col := range v.columns {
+		validation := types.ColumnValidation{Column: col.schema.Column, Checks: []string{}}
+		if col.index >= 0 {
+			validation.Checks = col.checks()
+		}
+		for k, failure := range col.failures {
+			if failure.Count > 0 {
+				failure.Reason = col.reason(k)
+				validation.Failures = append(validation.Failures, failure)
+				report.Passed = false
+			}
+		}
+		for k, suspect := range col.suspects {
+			if suspect.Count > 0 {
+				suspect.Reason = suspectReasons[k]
+				validation.Suspicious = append(validation.Suspicious, suspect)
+			}
+		}
+		report.Columns = append(report.Columns, validation)
+	}
+	for _, key := range v.keys {
+		report.Keys = append(report.Keys, key.validation())
+		if key.duplicates > 0 {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+// uniqueKeys lists the unique columns of a schema and its composite keys: a
+// column with unique_with is unique together with those columns. Masked
+// columns are left out unless hashed, as other masks may make values repeat.
+func uniqueKeys(schema []types.ColumnSchema) [][]string {
+	masked := make(map[string]bool)
+	for _, col := range schema {
+		masked[col.Column] = col.Mask != nil && col.Mask.Method != "hash"
+	}
+
+	var keys [][]string
+	for _, col := range schema {
+		if col.Unique {
+			keys = append(keys, []string{col.Column})
+		}
+		if len(col.UniqueWith) > 0 {
+			keys = append(keys, append([]string{col.Column}, col.UniqueWith...))
+		}
+	}
+	return slices.DeleteFunc(keys, func(key []string) bool {
+		return slices.ContainsFunc(key, func(name string) bool { return masked[name] })
+	})
+}
+
+// CheckSchema makes sure the keys and rules of a schema can be checked
+func CheckSchema(schema []types.ColumnSchema) error {
+	if err := checkKeys(schema); err != nil {
+		return err
+	}
+	return checkRules(schema)
+}
+
+// checkKeys makes sure the composite keys of a schema name its columns
+func checkKeys(schema []types.ColumnSchema) error {
+	for _, col := range schema {
+		for _, name := range col.UniqueWith {
+			if !slices.ContainsFunc(schema, func(other types.ColumnSchema) bool { return other.Column == name }) {
+				return fmt.Errorf("unique_with of column %q names %q, which is not a target column", col.Column, name)
+			}
+		}
+	}
+	return nil
+}
+
+// uniqueKey finds the rows repeating the values of its columns. Rows with an
+// empty key value are not checked, like NULLs in a database's unique index;
+// required columns catch those.
+type uniqueKey struct {
+	columns  []string
+	indexes  []int
+	examples int
+	// first holds the row of every key seen
+	first      map[string]int
+	duplicates int
+	found      []types.DuplicateKey
+	// repeated indexes found by key
+	repeated map[string]int
+}
+
+// newUniqueKey returns the check of a key, or nil when one of its columns is
+// missing from the header
+func newUniqueKey(columns []string, positions map[string]int, examples int) *uniqueKey {
+	k := &uniqueKey{columns: columns, examples: examples, first: make(map[string]int), repeated: make(map[string]int)}
+	for _, name := range columns {
+		index, found := positions[name]
+		if !found {
+			return nil
+		}
+		k.indexes = append(k.indexes, index)
+	}
+	return k
+}
+
+func (k *uniqueKey) check(row []string, rowNumber int) {
+	for _, index := range k.indexes {
+		if field(row, index) == "" {
+			return
+		}
+	}
+	key := keyOf(row, k.indexes)
+	first, seen := k.first[key]
+	if !seen {
+		// Records may share the memory of the file read, and a key of one
+		// column is its value
+		k.first[strings.Clone(key)] = rowNumber
+		return
+	}
+
+	k.duplicates++
+	if i, found := k.repeated[key]; found {
+		if rows := &k.found[i].Rows; len(*rows) < k.examples {
+			*rows = append(*rows, rowNumber)
+		}
+		return
+	}
+	if len(k.found) < k.examples {
+		key = strings.Clone(key)
+		k.repeated[key] = len(k.found)
+		k.found = append(k.found, types.DuplicateKey{Key: displayKey(key), Rows: []int{first, rowNumber}})
+	}
+}
+
+func (k *uniqueKey) validation() types.KeyValidation {
+	return types.KeyValidation{Columns: k.columns, Duplicates: k.duplicates, Examples: k.found}
+}
+
+// The checks made on each value
+const (
+	checkRequired = iota
+	checkValues
+	checkType
+	checkPattern
+	checkLength
+	checkRange
+	checkDates
+	checkCount
+)
+
+// Suspicious values are reported without failing validation
+const (
+	suspectDate = iota
+	suspectNegative
+	suspectCount
+)
+
+var suspectReasons = [suspectCount]string{
+	"placeholder date (year 1900 or before, or 2099 or after)",
+	"negative quantity",
+}
+
+type column struct {
+	schema   types.ColumnSchema
+	index    int
+	examples int
+	// allowed are the categorical target values, if any
+	allowed map[string]bool
+	// valid checks the type of a value; nil when the type is not checked
+	valid    func(value string) bool
+	typeName string
+	// The rules of the column, if any
+	pattern *regexp.Regexp
+	// dateLayout parses values checked against the date range; "" detects
+	// the layout of each value
+	dateLayout string
+	dayFirst   bool
+	failures   [checkCount]types.Anomaly
+	// typeLayout parses the values of date and datetime columns; quantity
+	// tells a numeric column counting things
+	typeLayout string
+	quantity   bool
+	suspects   [suspectCount]types.Anomaly
+}
+
+// newColumn prepares the checks of a target column found at index of the
+// header. Masked values are no longer of the column's type, values or rules,
+// so only their presence is checked.
+func newColumn(schema types.ColumnSchema, index int, options Options) *column {
+	c := &column{schema: schema, index: index, examples: options.Examples}
+	if schema.Mask != nil {
+		return c
+	}
+	defer c.addRules(options.DayFirst)
+
+	if len(schema.Values) > 0 {
+		c.allowed = make(map[string]bool, len(schema.Values))
+		for _, value := range schema.Values {
+			c.allowed[value] = true
+		}
+	}
+
+	switch schema.Type {
+	case "date", "datetime":
+		format := schema.Format
+		if format == "" {
+			format = transform.DefaultDateFormat
+			if schema.Type == "datetime" {
+				format = transform.DefaultDateTimeFormat
+			}
+		}
+		layout := transform.DateLayout(format)
+		c.typeLayout = layout
+		c.typeName = schema.Type + " " + format
+		c.valid = func(value string) bool {
+			_, err := time.Parse(layout, value)
+			return err == nil
+		}
+	case "number":
+		c.quantity = transform.QuantityColumn(schema.Column)
+		c.typeName = "number"
+		decimals := -1
+		if schema.Decimals != nil {
+			decimals = *schema.Decimals
+			c.typeName = fmt.Sprintf("number with %d decimals", decimals)
+		}
+		c.valid = func(value string) bool { return isNumber(value, decimals) }
+	case "integer":
+		c.quantity = transform.QuantityColumn(schema.Column)
+		c.typeName = "integer"
+		c.valid = isInteger
+	case "boolean":
+		c.typeName = "boolean"
+		c.valid = func(value string) bool {
+			_, err := strconv.ParseBool(value)
+			return err == nil
+		}
+	case "phone":
+		c.typeName = "phone"
+		c.valid = isPhone
+	}
+	return c
+}
+
+// addRules prepares the checks of the column's rules. Dates are read in the
+// format of date and datetime target columns and in the format of source
+// columns when it is given, or else in the format detected from each value.
+func (c *column) addRules(dayFirst bool) {
+	rules := c.schema.Rules
+	if rules == nil {
+		return
+	}
+	if rules.Pattern != "" {
+		// checkRules made sure the pattern compiles
+		c.pattern = regexp.MustCompile(`^(?:` + rules.Pattern + `)$`)
+	}
+	if rules.MinDate != "" || rules.MaxDate != "" {
+		c.dayFirst = dayFirst
+		switch {
+		case c.schema.Type == "date" || c.schema.Type == "datetime":
+			format := c.schema.Format
+			if format == "" {
+				format = transform.DefaultDateFormat
+				if c.schema.Type == "datetime" {
+					format = transform.DefaultDateTimeFormat
+				}
+			}
+			c.dateLayout = transform.DateLayout(format)
+		case c.schema.Format != "":
+			// The source format of a source column
+			c.dateLayout = transform.DateLayout(c.schema.Format)
+		}
+	}
+}
+
+// checkRules makes sure the rules of a schema can be checked
+func checkRules(schema []types.ColumnSchema) error {
+	for _, col := range schema {
+		rules := col.Rules
+		if rules == nil {
+			continue
+		}
+		if _, err := regexp.Compile(rules.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern of column %q: %v", col.Column, err)
+		}
+		for _, date := range []string{rules.MinDate, rules.MaxDate} {
+			if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+				return fmt.Errorf("invalid date range of column %q: %q is not YYYY-MM-DD", col.Column, date)
+			}
+		}
+		if rules.MaxLength < 0 {
+			return fmt.Errorf("invalid max_length of column %q: must not be negative", col.Column)
+		}
+	}
+	return nil
+}
+
+func (c *column) checks() []string {
+	checks := []string{}
+	if c.schema.Required {
+		checks = append(checks, "required")
+	}
+	if c.allowed != nil {
+		checks = append(checks, "values")
+	}
+	if c.valid != nil {
+		checks = append(checks, c.typeName)
+	}
+	if rules := c.schema.Rules; rules != nil && c.schema.Mask == nil {
+		if rules.Pattern != "" {
+			checks = append(checks, "pattern")
+		}
+		if rules.MaxLength > 0 {
+			checks = append(checks, fmt.Sprintf("max length %d", rules.MaxLength))
+		}
+		if rules.Min != nil || rules.Max != nil {
+			checks = append(checks, "range "+c.numberRange())
+		}
+		if rules.MinDate != "" || rules.MaxDate != "" {
+			checks = append(checks, "dates "+c.dateRange())
+		}
+	}
+	return checks
+}
+
+func (c *column) reason(check int) string {
+	switch check {
+	case checkRequired:
+		return "required value is empty"
+	case checkValues:
+		return "not one of the target values"
+	case checkPattern:
+		return fmt.Sprintf("does not match pattern %q", c.schema.Rules.Pattern)
+	case checkLength:
+		return fmt.Sprintf("longer than %d characters", c.schema.Rules.MaxLength)
+	case checkRange:
+		return "not a number in range " + c.numberRange()
+	case checkDates:
+		return "not a date in range " + c.dateRange()
+	default:
+		return "not a valid " + c.typeName
+	}
+}
+
+// numberRange writes the bounds of numbers, e.g. "0..100" or "0.."
+func (c *column) numberRange() string {
+	var min, max string
+	if c.schema.Rules.Min != nil {
+		min = strconv.FormatFloat(*c.schema.Rules.Min, 'f', -1, 64)
+	}
+	if c.schema.Rules.Max != nil {
+		max = strconv.FormatFloat(*c.schema.Rules.Max, 'f', -1, 64)
+	}
+	return min + ".." + max
+}
+
+// dateRange writes the bounds of dates, e.g. "2020-01-01..2024-12-31"
+func (c *column) dateRange() string {
+	return c.schema.Rules.MinDate + ".." + c.schema.Rules.MaxDate
+}
+
+func (c *column) check(value string, rowNumber int) {
+	if value == "" {
+		if c.schema.Required {
+			c.fail(checkRequired, rowNumber, value)
+		}
+		return
+	}
+	if c.allowed != nil && !c.allowed[value] {
+		c.fail(checkValues, rowNumber, value)
+	}
+	if c.valid != nil && !c.valid(value) {
+		c.fail(checkType, rowNumber, value)
+		// A value of the wrong type is not compared with the rules as well
+		return
+	}
+	c.suspect(value, rowNumber)
+
+	rules := c.schema.Rules
+	if rules == nil || c.schema.Mask != nil {
+		return
+	}
+	if c.pattern != nil && !c.pattern.MatchString(value) {
+		c.fail(checkPattern, rowNumber, value)
+	}
+	if rules.MaxLength > 0 && utf8.RuneCountInString(value) > rules.MaxLength {
+		c.fail(checkLength, rowNumber, value)
+	}
+	if (rules.Min != nil || rules.Max != nil) && !c.inNumberRange(value) {
+		c.fail(checkRange, rowNumber, value)
+	}
+	if (rules.MinDate != "" || rules.MaxDate != "") && !c.inDateRange(value) {
+		c.fail(checkDates, rowNumber, value)
+	}
+}
+
+// inNumberRange reads value as the converter reads numbers, so source values
+// such as "Rp 1.250.000" are compared as well
+func (c *column) inNumberRange(value string) bool {
+	cleaned, err := transform.CleanNumber(value, c.schema.DecimalSeparator)
+	if err != nil {
+		return false
+	}
+	number, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return false
+	}
+	rules := c.schema.Rules
+	return (rules.Min == nil || number >= *rules.Min) && (rules.Max == nil || number <= *rules.Max)
+}
+
+// inDateRange compares the day of value with the date range
+func (c *column) inDateRange(value string) bool {
+	layout := c.dateLayout
+	if layout == "" {
+		var found bool
+		if layout, found = transform.DetectDateLayout([]string{value}, c.dayFirst); !found {
+			return false
+		}
+	}
+	day, err := transform.ConvertDate(value, layout, time.DateOnly)
+	if err != nil {
+		return false
+	}
+	rules := c.schema.Rules
+	return (rules.MinDate == "" || day >= rules.MinDate) && (rules.MaxDate == "" || day <= rules.MaxDate)
+}
+
+func (c *column) fail(check int, rowNumber int, value string) {
+	record(&c.failures[check], rowNumber, value, c.examples)
+}
+
+// suspect notes valid values that are likely wrong: placeholder dates of
+// date columns and negative numbers of quantity columns
+func (c *column) suspect(value string, rowNumber int) {
+	if c.typeLayout != "" {
+		if date, err := time.Parse(c.typeLayout, value); err == nil && transform.SentinelDate(date) {
+			record(&c.suspects[suspectDate], rowNumber, value, c.examples)
+		}
+	}
+	if number, err := strconv.ParseFloat(value, 64); c.quantity && err == nil && number < 0 {
+		record(&c.suspects[suspectNegative], rowNumber, value, c.examples)
+	}
+}
+
+func record(anomaly *types.Anomaly, rowNumber int, value string, examples int) {
+	anomaly.Count++
+	if len(anomaly.Examples) < examples {
+		anomaly.Examples = append(anomaly.Examples, types.AnomalyExample{Row: rowNumber, Value: strings.Clone(value)})
+	}
+}
+
+// isInteger accepts the integers CleanInteger writes: digits with an
+// optional minus sign
+func isInteger(value string) bool {
+	digits := strings.TrimPrefix(value, "-")
+	return digits != "" && strings.Trim(digits, "0123456789") == ""
+}
+
+// isNumber accepts the plain numbers CleanNumber writes, with exactly
+// decimals places unless decimals is negative
+func isNumber(value string, decimals int) bool {
+	whole, fraction, found := strings.Cut(value, ".")
+	if !isInteger(whole) || (found && (fraction == "" || strings.Trim(fraction, "0123456789") != "")) {
+		return false
+	}
+	return decimals < 0 || len(fraction) == decimals
+}
+
+// isPhone accepts E.164 numbers as NormalizePhone writes them
+func isPhone(value string) bool {
+	digits, found := strings.CutPrefix(value, "+")
+	return found && len(digits) >= 8 && len(digits) <= 15 && strings.Trim(digits, "0123456789") == ""
+}